@@ -0,0 +1,265 @@
+// Package certs creates and loads the certificates used by kaller servers and calls over TLS
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Validity is how long generated certificates are valid for
+var Validity = 365 * 24 * time.Hour
+
+// Files written by LoadOrCreateAuthority
+const (
+	CAFile        = "ca.pem"
+	CAKeyFile     = "ca-key.pem"
+	ClientFile    = "client.pem"
+	ClientKeyFile = "client-key.pem"
+)
+
+// Authority is a self-signed certificate authority, meant for local runs where no real
+// certificates are available
+type Authority struct {
+	Certificate *x509.Certificate
+	key         *ecdsa.PrivateKey
+}
+
+// NewAuthority creates a new self-signed certificate authority
+func NewAuthority() (*Authority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template, err := newTemplate("kaller ca")
+	if err != nil {
+		return nil, err
+	}
+	template.IsCA = true
+	template.BasicConstraintsValid = true
+	template.KeyUsage = x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Authority{Certificate: cert, key: key}, nil
+}
+
+// LoadOrCreateAuthority loads the authority kept in dir, creating it if there is none yet. This
+// allows several local kaller servers to share the same authority and trust each other. New
+// authorities are written to dir along with a client certificate they issued, which can be
+// used by calls to servers requiring client certificates (mutual TLS)
+//
+// Servers can start concurrently: creating the authority is serialized with a lock file in dir,
+// and servers that did not get the lock wait for the authority to be written and load it
+func LoadOrCreateAuthority(dir string) (*Authority, error) {
+	lockPath := filepath.Join(dir, lockFile)
+	for {
+		authority, err := loadAuthority(dir)
+		if err == nil || !errors.Is(err, os.ErrNotExist) {
+			return authority, err
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, err
+		}
+		lock, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			lock.Close()
+			defer os.Remove(lockPath)
+			return createAuthority(dir)
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to lock %s: %w", dir, err)
+		}
+		// another server is creating the authority. Locks older than lockTimeout were left
+		// behind by servers that stopped while creating it
+		if info, err := os.Stat(lockPath); err == nil && time.Since(info.ModTime()) > lockTimeout {
+			os.Remove(lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// lockFile serializes the creation of authorities in a directory
+const lockFile = "ca.lock"
+
+// lockTimeout is how long creating an authority can take before its lock is considered stale
+var lockTimeout = 10 * time.Second
+
+func loadAuthority(dir string) (*Authority, error) {
+	caPath, keyPath := filepath.Join(dir, CAFile), filepath.Join(dir, CAKeyFile)
+	cert, err := tls.LoadX509KeyPair(caPath, keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authority from %s: %w", dir, err)
+	}
+	key, ok := cert.PrivateKey.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported authority key type %T in %s", cert.PrivateKey, keyPath)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, err
+	}
+	return &Authority{Certificate: parsed, key: key}, nil
+}
+
+// createAuthority creates a new authority and its client certificate in dir. It must only be
+// called while holding the dir lock
+func createAuthority(dir string) (*Authority, error) {
+	// another server may have created the authority after it was last checked for
+	if authority, err := loadAuthority(dir); !errors.Is(err, os.ErrNotExist) {
+		return authority, err
+	}
+	authority, err := NewAuthority()
+	if err != nil {
+		return nil, err
+	}
+	client, err := authority.Issue("kaller-client")
+	if err != nil {
+		return nil, err
+	}
+	// the authority files are written last, as they are the ones checked for existence
+	if err := WriteCertificate(client, filepath.Join(dir, ClientFile), filepath.Join(dir, ClientKeyFile)); err != nil {
+		return nil, err
+	}
+	if err := WriteCertificate(authority.TLSCertificate(), filepath.Join(dir, CAFile), filepath.Join(dir, CAKeyFile)); err != nil {
+		return nil, err
+	}
+	return authority, nil
+}
+
+// TLSCertificate returns the authority certificate and key
+func (a *Authority) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{a.Certificate.Raw},
+		PrivateKey:  a.key,
+		Leaf:        a.Certificate,
+	}
+}
+
+// Pool returns a certificate pool trusting the authority
+func (a *Authority) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.Certificate)
+	return pool
+}
+
+// Issue issues a certificate for the given hosts, which can be host names or ip addresses. The
+// certificate can be used both by servers and by clients. The first host is used as the
+// certificate common name
+func (a *Authority) Issue(hosts ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	commonName := ""
+	if len(hosts) > 0 {
+		commonName = hosts[0]
+	}
+	template, err := newTemplate(commonName)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.Certificate, &key.PublicKey, a.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func newTemplate(commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"kaller"}},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(Validity),
+	}, nil
+}
+
+// WriteCertificate writes the certificate chain and its key as PEM files. The key file is only
+// readable by its owner. Each file is written atomically, the key first, so whoever finds the
+// certificate file also finds its key
+func WriteCertificate(cert tls.Certificate, certPath string, keyPath string) error {
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := writeFile(keyPath, keyPEM, 0o600); err != nil {
+		return err
+	}
+	return writeFile(certPath, certPEM, 0o644)
+}
+
+// writeFile writes a file atomically, by writing a temporary file and renaming it into place
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if _, err := file.Write(data); err != nil {
+		return err
+	}
+	if err := file.Chmod(perm); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// LoadPool loads a certificate pool from a PEM bundle file
+func LoadPool(path string) (*x509.CertPool, error) {
+	bundle, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
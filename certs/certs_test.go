@@ -0,0 +1,95 @@
+package certs
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssue(t *testing.T) {
+	authority, err := NewAuthority()
+	require.NoError(t, err)
+	cert, err := authority.Issue("orders", "127.0.0.1")
+	require.NoError(t, err)
+	assert.Equal(t, "orders", cert.Leaf.Subject.CommonName)
+
+	for _, host := range []string{"orders", "127.0.0.1"} {
+		_, err = cert.Leaf.Verify(x509.VerifyOptions{
+			DNSName:   host,
+			Roots:     authority.Pool(),
+			KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		})
+		assert.NoError(t, err, host)
+	}
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{DNSName: "payments", Roots: authority.Pool()})
+	assert.Error(t, err)
+
+	other, err := NewAuthority()
+	require.NoError(t, err)
+	_, err = cert.Leaf.Verify(x509.VerifyOptions{DNSName: "orders", Roots: other.Pool()})
+	assert.Error(t, err)
+}
+
+func TestLoadOrCreateAuthority(t *testing.T) {
+	dir := t.TempDir()
+	created, err := LoadOrCreateAuthority(dir)
+	require.NoError(t, err)
+	loaded, err := LoadOrCreateAuthority(dir)
+	require.NoError(t, err)
+	assert.True(t, created.Certificate.Equal(loaded.Certificate))
+
+	pool, err := LoadPool(filepath.Join(dir, CAFile))
+	require.NoError(t, err)
+	client, err := LoadPool(filepath.Join(dir, ClientFile))
+	require.NoError(t, err)
+	assert.False(t, pool.Equal(client))
+
+	_, err = LoadPool(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+}
+
+func TestLoadOrCreateAuthorityConcurrently(t *testing.T) {
+	dir := t.TempDir()
+	authorities := make([]*Authority, 8)
+	errs := make([]error, len(authorities))
+	wg := sync.WaitGroup{}
+	for idx := range authorities {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			authorities[idx], errs[idx] = LoadOrCreateAuthority(dir)
+		}(idx)
+	}
+	wg.Wait()
+
+	loaded, err := LoadOrCreateAuthority(dir)
+	require.NoError(t, err)
+	for idx, authority := range authorities {
+		require.NoError(t, errs[idx])
+		assert.True(t, loaded.Certificate.Equal(authority.Certificate), idx)
+	}
+
+	// only the authority and client files are left behind
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.ElementsMatch(t, []string{CAFile, CAKeyFile, ClientFile, ClientKeyFile}, names)
+}
+
+func TestLoadOrCreateAuthorityStaleLock(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, lockFile), nil, 0o600))
+	stale := time.Now().Add(-2 * lockTimeout)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, lockFile), stale, stale))
+	_, err := LoadOrCreateAuthority(dir)
+	assert.NoError(t, err)
+}
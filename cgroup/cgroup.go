@@ -0,0 +1,140 @@
+// Package cgroup detects the cpu quota imposed on the current process by linux control groups,
+// like the ones created by container runtimes for kubernetes cpu limits
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Root is where the proc and sys filesystems are looked up. Only meant to be changed in tests
+var Root = "/"
+
+// ErrNoQuota is returned when no cpu quota is set for the process
+var ErrNoQuota = errors.New("no cgroup cpu quota")
+
+// CPUQuota returns how many cpus the process is allowed to use according to its cgroup (v1 or
+// v2) cpu quota. For instance a kubernetes cpu limit of 500m results in a quota of 0.5.
+// Returns ErrNoQuota if there is no quota set or cgroups are not available
+func CPUQuota() (float64, error) {
+	paths, err := cgroupPaths()
+	if err != nil {
+		return 0, err
+	}
+	// cgroup v2 (unified hierarchy)
+	if path, ok := paths[""]; ok {
+		quota, err := lookup(quotaV2, filepath.Join(Root, "sys/fs/cgroup"), path)
+		if !errors.Is(err, os.ErrNotExist) {
+			return quota, err
+		}
+	}
+	// cgroup v1
+	for controllers, path := range paths {
+		for _, controller := range strings.Split(controllers, ",") {
+			if controller == "cpu" {
+				quota, err := lookup(quotaV1, filepath.Join(Root, "sys/fs/cgroup", controllers), path)
+				if errors.Is(err, os.ErrNotExist) {
+					return 0, ErrNoQuota
+				}
+				return quota, err
+			}
+		}
+	}
+	return 0, ErrNoQuota
+}
+
+// lookup reads the quota of the cgroup path in the given hierarchy mount. Inside containers the
+// process cgroup is usually mounted as the hierarchy root, so the root is tried as well
+func lookup(read func(dir string) (float64, error), mount string, path string) (float64, error) {
+	quota, err := read(filepath.Join(mount, path))
+	if errors.Is(err, os.ErrNotExist) && path != "/" {
+		return read(mount)
+	}
+	return quota, err
+}
+
+// EffectiveCPU returns how many cpus the process can effectively use: the cgroup cpu quota
+// when set, capped at the number of cpus of the machine
+func EffectiveCPU() float64 {
+	cpus := float64(runtime.NumCPU())
+	if quota, err := CPUQuota(); err == nil && quota < cpus {
+		return quota
+	}
+	return cpus
+}
+
+// cgroupPaths reads the cgroup of the process for each controller hierarchy, by controller
+// list. The cgroup v2 hierarchy has an empty controller list
+func cgroupPaths() (map[string]string, error) {
+	file, err := os.Open(filepath.Join(Root, "proc/self/cgroup"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNoQuota
+		}
+		return nil, err
+	}
+	defer file.Close()
+	paths := map[string]string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// format is hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(scanner.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		paths[parts[1]] = parts[2]
+	}
+	return paths, scanner.Err()
+}
+
+// quotaV2 reads the cpu.max file, which contains the quota and the period, eg: "50000 100000".
+// A quota of "max" means no limit
+func quotaV2(dir string) (float64, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "cpu.max"))
+	if err != nil {
+		return 0, fmt.Errorf("cannot read cgroup v2 cpu quota: %w", err)
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("invalid cgroup v2 cpu.max content %q", content)
+	}
+	if fields[0] == "max" {
+		return 0, ErrNoQuota
+	}
+	return ratio(fields[0], fields[1])
+}
+
+// quotaV1 reads the cpu.cfs_quota_us and cpu.cfs_period_us files. A quota of -1 means no limit
+func quotaV1(dir string) (float64, error) {
+	quota, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0, fmt.Errorf("cannot read cgroup v1 cpu quota: %w", err)
+	}
+	period, err := os.ReadFile(filepath.Join(dir, "cpu.cfs_period_us"))
+	if err != nil {
+		return 0, fmt.Errorf("cannot read cgroup v1 cpu period: %w", err)
+	}
+	if strings.TrimSpace(string(quota)) == "-1" {
+		return 0, ErrNoQuota
+	}
+	return ratio(string(quota), string(period))
+}
+
+func ratio(quotaStr string, periodStr string) (float64, error) {
+	quota, err := strconv.ParseFloat(strings.TrimSpace(quotaStr), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cgroup cpu quota %q: %w", quotaStr, err)
+	}
+	period, err := strconv.ParseFloat(strings.TrimSpace(periodStr), 64)
+	if err != nil || period <= 0 || math.IsInf(period, 0) {
+		return 0, fmt.Errorf("invalid cgroup cpu period %q", periodStr)
+	}
+	return quota / period, nil
+}
@@ -0,0 +1,102 @@
+package cgroup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeRoot(t *testing.T, files map[string]string) {
+	root := t.TempDir()
+	for path, content := range files {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	previous := Root
+	Root = root
+	t.Cleanup(func() { Root = previous })
+}
+
+func TestCPUQuota(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected float64
+		err      error
+	}{
+		{
+			name: "v2",
+			files: map[string]string{
+				"proc/self/cgroup":                    "0::/kubepods/pod1\n",
+				"sys/fs/cgroup/kubepods/pod1/cpu.max": "50000 100000\n",
+			},
+			expected: 0.5,
+		},
+		{
+			name: "v2 no limit",
+			files: map[string]string{
+				"proc/self/cgroup":      "0::/\n",
+				"sys/fs/cgroup/cpu.max": "max 100000\n",
+			},
+			err: ErrNoQuota,
+		},
+		{
+			name: "v2 namespaced",
+			files: map[string]string{
+				"proc/self/cgroup":      "0::/kubepods/pod1\n",
+				"sys/fs/cgroup/cpu.max": "250000 100000\n",
+			},
+			expected: 2.5,
+		},
+		{
+			name: "v1",
+			files: map[string]string{
+				"proc/self/cgroup": "" +
+					"4:memory:/docker/abc\n" +
+					"3:cpu,cpuacct:/docker/abc\n" +
+					"0::/\n",
+				"sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_quota_us":  "150000\n",
+				"sys/fs/cgroup/cpu,cpuacct/docker/abc/cpu.cfs_period_us": "100000\n",
+			},
+			expected: 1.5,
+		},
+		{
+			name: "v1 no limit",
+			files: map[string]string{
+				"proc/self/cgroup":                    "1:cpu:/\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_quota_us":  "-1\n",
+				"sys/fs/cgroup/cpu/cpu.cfs_period_us": "100000\n",
+			},
+			err: ErrNoQuota,
+		},
+		{
+			name:  "no cgroups",
+			files: map[string]string{},
+			err:   ErrNoQuota,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeRoot(t, test.files)
+			quota, err := CPUQuota()
+			if test.err != nil {
+				assert.ErrorIs(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, quota)
+		})
+	}
+}
+
+func TestEffectiveCPU(t *testing.T) {
+	fakeRoot(t, map[string]string{
+		"proc/self/cgroup":      "0::/\n",
+		"sys/fs/cgroup/cpu.max": "10000 100000\n",
+	})
+	assert.Equal(t, 0.1, EffectiveCPU())
+}
@@ -3,16 +3,13 @@ package main
 import (
 	"context"
 	"fmt"
-	"io"
 	"net/http"
-	"os"
 	"time"
 
 	"github.com/alexflint/go-arg"
 
 	"github.com/bcap/kaller/cmd"
 	"github.com/bcap/kaller/handler"
-	"github.com/bcap/kaller/plan"
 	srv "github.com/bcap/kaller/server"
 )
 
@@ -20,6 +17,7 @@ type Args struct {
 	Plan    string `arg:"positional,required" help:"The plan yaml file to use. Use \"-\" to read the plan from stdin"`
 	Port    int    `arg:"-p,--port" help:"control the tcp port for the localhost server that is used to execute the plan" default:"0"`
 	Profile string `arg:"--profile" help:"Enables profiling for the given mode. Available modes at cmd/profile.go"`
+	Seed    int64  `arg:"--seed" help:"Seed for all random decisions in the plan, overriding the plan seed. Runs with the same seed are reproducible"`
 }
 
 func main() {
@@ -38,14 +36,18 @@ func main() {
 	addr, err := server.Listen(ctx, fmt.Sprintf(":%d", args.Port))
 	cmd.PanicOnErr(err)
 
+	h := handler.New(ctx)
 	go func() {
-		err := server.Serve(handler.New(ctx))
+		err := server.Serve(h)
 		if !srv.IsClosedError(err) {
 			cmd.PanicOnErr(err)
 		}
 	}()
 
-	plan := readPlan(args.Plan)
+	plan := cmd.ReadPlan(args.Plan)
+	if args.Seed != 0 {
+		plan.Seed = args.Seed
+	}
 
 	localRunURL := fmt.Sprintf("http://%s/run-plan", addr.AddrPort())
 	req, err := http.NewRequestWithContext(ctx, "POST", localRunURL, nil)
@@ -54,8 +56,7 @@ func main() {
 
 	http.DefaultClient.Do(req)
 
-	handler := server.Handler().(*handler.Handler)
-	for handler.Outstanding() > 0 {
+	for h.Outstanding() > 0 {
 		time.Sleep(10 * time.Millisecond)
 	}
 }
@@ -65,17 +66,3 @@ func parseArgs() Args {
 	arg.MustParse(&args)
 	return args
 }
-
-func readPlan(location string) plan.Plan {
-	var input io.Reader = os.Stdin
-	if location != "-" {
-		var err error
-		input, err = os.OpenFile(location, os.O_RDONLY, 0)
-		cmd.PanicOnErr(err)
-	}
-	data, err := io.ReadAll(input)
-	cmd.PanicOnErr(err)
-	plan, err := plan.FromYAML(data)
-	cmd.PanicOnErr(err)
-	return plan
-}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"os"
+
+	"github.com/bcap/kaller/cmd"
+	"github.com/bcap/kaller/deploy"
+)
+
+type ComposeCmd struct {
+	Plan         string  `arg:"positional,required" help:"The plan yaml file to scan for services. Use \"-\" to read the plan from stdin"`
+	Image        string  `arg:"--image" default:"bcap/kaller" help:"Container image for services and client"`
+	MinCPU       float64 `arg:"--min-cpu" default:"0.1" help:"Minimum cpu limit per service"`
+	BaseMemoryMB int     `arg:"--base-memory-mb" default:"64" help:"Memory limit per service on top of the simulated memory peak"`
+	Client       bool    `arg:"--client" help:"Also emit a client service that runs the plan file, mounted from the host"`
+}
+
+func (c *ComposeCmd) Run() error {
+	plan := cmd.ReadPlan(c.Plan)
+	options := deploy.ComposeOptions{
+		Image:        c.Image,
+		MinCPU:       c.MinCPU,
+		BaseMemoryMB: c.BaseMemoryMB,
+	}
+	if c.Client && c.Plan != "-" {
+		options.PlanFile = c.Plan
+	}
+	return deploy.Compose(os.Stdout, plan, options)
+}
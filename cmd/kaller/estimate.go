@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/bcap/kaller/cmd"
+)
+
+type EstimateCmd struct {
+	Plan string `arg:"positional,required" help:"The plan yaml file to analyze. Use \"-\" to read the plan from stdin"`
+}
+
+func (c *EstimateCmd) Run() error {
+	plan := cmd.ReadPlan(c.Plan)
+	estimate := plan.Estimate()
+
+	w := os.Stdout
+	fmt.Fprintf(w, "latency:     %s\n", estimate.Latency)
+	fmt.Fprintf(w, "completion:  %s\n", estimate.Completion)
+	fmt.Fprintf(w, "cpu seconds: %s\n", estimate.CPUSeconds)
+
+	fmt.Fprintln(w, "requests:")
+	hosts := make([]string, 0, len(estimate.Requests))
+	for host := range estimate.Requests {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+	for _, host := range hosts {
+		fmt.Fprintf(w, "  %-30s %d\n", host, estimate.Requests[host])
+	}
+
+	fmt.Fprintln(w, "critical path:")
+	for _, step := range estimate.CriticalPath {
+		fmt.Fprintf(w, "  %s\n", step)
+	}
+	return nil
+}
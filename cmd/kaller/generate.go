@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/bcap/kaller/generate"
+	ptype "github.com/bcap/kaller/plan"
+)
+
+type GenerateCmd struct {
+	Seed       *int64        `arg:"--seed" help:"Random seed, also set as the seed of the generated plan. Defaults to the current time. A seed of 0 still generates a fixed plan, but makes its runs pick random seeds"`
+	Services   int           `arg:"--services" default:"5" help:"Amount of distinct services"`
+	HostFormat string        `arg:"--host-format" default:"svc%d" help:"Format for service hosts, receiving the service number"`
+	Depth      int           `arg:"--depth" default:"3" help:"Maximum amount of hops from the entrypoint service"`
+	FanOutMin  int           `arg:"--fan-out-min" default:"1" help:"Minimum amount of calls a service makes to other services"`
+	FanOutMax  int           `arg:"--fan-out-max" default:"3" help:"Maximum amount of calls a service makes to other services"`
+	Parallel   float64       `arg:"--parallel" default:"0.3" help:"Probability of a service calling its dependencies in parallel"`
+	Async      float64       `arg:"--async" default:"0.1" help:"Probability of each call being async"`
+	ComputeMin time.Duration `arg:"--compute-min" default:"5ms" help:"Minimum compute time per call"`
+	ComputeMax time.Duration `arg:"--compute-max" default:"50ms" help:"Maximum compute time per call"`
+	CPUMin     float64       `arg:"--cpu-min" default:"0" help:"Minimum cpu load per call compute"`
+	CPUMax     float64       `arg:"--cpu-max" default:"0.5" help:"Maximum cpu load per call compute"`
+	BodyMin    int           `arg:"--body-min" default:"100" help:"Minimum response body size"`
+	BodyMax    int           `arg:"--body-max" default:"10240" help:"Maximum response body size"`
+	FanOut     string        `arg:"--fan-out" help:"Distribution to pick the amount of calls each service makes from, eg pareto(1, 1.5). Like all distribution flags, values are picked when generating the plan and bounded by their min and max flags"`
+	Compute    string        `arg:"--compute" help:"Distribution to pick the compute range of each call from, eg lognormal(20ms, 0.5). Two values are picked per call, the lower being the min and the higher the max"`
+	Body       string        `arg:"--body" help:"Distribution to pick the response body size of each call from, eg lognormal(2kb, 1)"`
+}
+
+func (c *GenerateCmd) Run() error {
+	seed := time.Now().UnixNano()
+	if c.Seed != nil {
+		seed = *c.Seed
+	}
+	fanOut, err := parseDistribution("fan-out", c.FanOut)
+	if err != nil {
+		return err
+	}
+	compute, err := parseDistribution("compute", c.Compute)
+	if err != nil {
+		return err
+	}
+	body, err := parseDistribution("body", c.Body)
+	if err != nil {
+		return err
+	}
+	plan, err := generate.Plan(generate.Params{
+		Seed:                seed,
+		Services:            c.Services,
+		HostFormat:          c.HostFormat,
+		Depth:               c.Depth,
+		FanOutMin:           c.FanOutMin,
+		FanOutMax:           c.FanOutMax,
+		FanOut:              fanOut,
+		ParallelProbability: c.Parallel,
+		AsyncProbability:    c.Async,
+		ComputeMin:          c.ComputeMin,
+		ComputeMax:          c.ComputeMax,
+		Compute:             compute,
+		CPUMin:              c.CPUMin,
+		CPUMax:              c.CPUMax,
+		BodySizeMin:         c.BodyMin,
+		BodySizeMax:         c.BodyMax,
+		BodySize:            body,
+	})
+	if err != nil {
+		return err
+	}
+	encoded, err := plan.ToYAML()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(encoded)
+	return err
+}
+
+// parseDistribution parses the distribution passed to the given flag, if any
+func parseDistribution(flag string, value string) (*ptype.Distribution, error) {
+	if value == "" {
+		return nil, nil
+	}
+	distribution, err := ptype.ParseDistribution(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --%s: %w", flag, err)
+	}
+	return distribution, nil
+}
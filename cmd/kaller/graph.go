@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bcap/kaller/cmd"
+	"github.com/bcap/kaller/render"
+)
+
+type GraphCmd struct {
+	Plan   string `arg:"positional,required" help:"The plan yaml file to render. Use \"-\" to read the plan from stdin"`
+	Format string `arg:"-f,--format" default:"dot" help:"Output format: dot, mermaid (a flowchart) or mermaid-sequence (a sequence diagram)"`
+}
+
+func (c *GraphCmd) Run() error {
+	plan := cmd.ReadPlan(c.Plan)
+	switch c.Format {
+	case "dot":
+		return render.DOT(os.Stdout, plan)
+	case "mermaid":
+		return render.Mermaid(os.Stdout, plan)
+	case "mermaid-sequence":
+		return render.MermaidSequence(os.Stdout, plan)
+	default:
+		return fmt.Errorf("unknown graph format %q", c.Format)
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/bcap/kaller/trace"
+)
+
+type ImportCmd struct {
+	Trace  string `arg:"positional,required" help:"The JSON trace file to import. Use \"-\" to read the trace from stdin"`
+	Format string `arg:"-f,--format" default:"jaeger" help:"Trace format: jaeger or zipkin"`
+}
+
+func (c *ImportCmd) Run() error {
+	var input io.Reader = os.Stdin
+	if c.Trace != "-" {
+		file, err := os.Open(c.Trace)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return err
+	}
+
+	var spans []trace.Span
+	switch c.Format {
+	case "jaeger":
+		spans, err = trace.FromJaeger(data)
+	case "zipkin":
+		spans, err = trace.FromZipkin(data)
+	default:
+		return fmt.Errorf("unknown trace format %q", c.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	plan, err := trace.ToPlan(spans)
+	if err != nil {
+		return err
+	}
+	encoded, err := plan.ToYAML()
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(encoded)
+	return err
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"os"
+
+	"github.com/bcap/kaller/cmd"
+	"github.com/bcap/kaller/deploy"
+)
+
+type KubernetesCmd struct {
+	Plan         string  `arg:"positional,required" help:"The plan yaml file to scan for services. Use \"-\" to read the plan from stdin"`
+	Image        string  `arg:"--image" default:"bcap/kaller" help:"Container image for services and client"`
+	Replicas     int     `arg:"--replicas" default:"2" help:"Replicas per service deployment"`
+	MinCPU       float64 `arg:"--min-cpu" default:"0.1" help:"Minimum cpu limit per service"`
+	BaseMemoryMB int     `arg:"--base-memory-mb" default:"64" help:"Memory limit per service on top of the simulated memory peak"`
+	ClientJob    bool    `arg:"--client-job" help:"Also emit a Job that runs the kaller client with the plan"`
+}
+
+func (c *KubernetesCmd) Run() error {
+	plan := cmd.ReadPlan(c.Plan)
+	return deploy.Kubernetes(os.Stdout, plan, deploy.KubernetesOptions{
+		Image:        c.Image,
+		Replicas:     c.Replicas,
+		MinCPU:       c.MinCPU,
+		BaseMemoryMB: c.BaseMemoryMB,
+		ClientJob:    c.ClientJob,
+	})
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"github.com/alexflint/go-arg"
+
+	"github.com/bcap/kaller/cmd"
+)
+
+// Args holds the kaller tooling subcommands. Each subcommand is implemented in its own file
+type Args struct {
+	Graph    *GraphCmd      `arg:"subcommand:graph" help:"render a plan as a Graphviz DOT or Mermaid diagram"`
+	Estimate *EstimateCmd   `arg:"subcommand:estimate" help:"statically estimate latency, cpu usage and requests of a plan"`
+	Import   *ImportCmd     `arg:"subcommand:import" help:"build a plan from a Jaeger or Zipkin JSON trace"`
+	Generate *GenerateCmd   `arg:"subcommand:generate" help:"generate a random plan topology"`
+	K8s      *KubernetesCmd `arg:"subcommand:k8s" help:"generate kubernetes manifests for the services in a plan"`
+	Compose  *ComposeCmd    `arg:"subcommand:compose" help:"generate a docker-compose file for the services in a plan"`
+	Mesh     *MeshCmd       `arg:"subcommand:mesh" help:"run a plan's services locally in a single process"`
+}
+
+func main() {
+	cmd.ConfigureLogging()
+
+	var args Args
+	parser := arg.MustParse(&args)
+
+	switch {
+	case args.Graph != nil:
+		cmd.PanicOnErr(args.Graph.Run())
+	case args.Estimate != nil:
+		cmd.PanicOnErr(args.Estimate.Run())
+	case args.Import != nil:
+		cmd.PanicOnErr(args.Import.Run())
+	case args.Generate != nil:
+		cmd.PanicOnErr(args.Generate.Run())
+	case args.K8s != nil:
+		cmd.PanicOnErr(args.K8s.Run())
+	case args.Compose != nil:
+		cmd.PanicOnErr(args.Compose.Run())
+	case args.Mesh != nil:
+		cmd.PanicOnErr(args.Mesh.Run())
+	default:
+		parser.Fail("missing subcommand")
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/bcap/kaller/cmd"
+	"github.com/bcap/kaller/mesh"
+)
+
+type MeshCmd struct {
+	Up *MeshUpCmd `arg:"subcommand:up" help:"run all services of a plan in this process, on loopback ports"`
+}
+
+func (c *MeshCmd) Run() error {
+	if c.Up != nil {
+		return c.Up.Run()
+	}
+	return errors.New("missing mesh subcommand")
+}
+
+type MeshUpCmd struct {
+	Plan      string `arg:"positional,required" help:"The plan yaml file to run. Use \"-\" to read the plan from stdin"`
+	Once      bool   `arg:"--once" help:"Execute the plan once and exit instead of serving until interrupted"`
+	WritePlan string `arg:"--write-plan" help:"Write the plan with its hosts rewritten to the local servers to this file, for use with the kaller client"`
+}
+
+func (c *MeshUpCmd) Run() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	plan := cmd.ReadPlan(c.Plan)
+	m, err := mesh.Up(ctx, plan)
+	if err != nil {
+		return err
+	}
+	defer m.Shutdown(1 * time.Second)
+
+	for _, host := range m.Hosts() {
+		log.Printf("Mesh service %s listening on %s", host, m.Services[host].Server.AddressString())
+	}
+
+	if c.WritePlan != "" {
+		encoded, err := m.Plan.ToYAML()
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(c.WritePlan, encoded, 0644); err != nil {
+			return err
+		}
+	}
+
+	if c.Once {
+		start := time.Now()
+		if err := m.Run(ctx); err != nil {
+			return err
+		}
+		log.Printf("Mesh plan executed in %v", time.Since(start))
+		return nil
+	}
+
+	cmd.InstallSignalHandler(
+		func(signal os.Signal) {
+			log.Println("Mesh interrupted, shutting down")
+			cancel()
+		},
+		os.Interrupt,
+	)
+	<-ctx.Done()
+	return nil
+}
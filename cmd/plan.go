@@ -0,0 +1,23 @@
+package cmd
+
+import (
+	"io"
+	"os"
+
+	"github.com/bcap/kaller/plan"
+)
+
+// ReadPlan reads a yaml plan from the given file location. Use "-" to read it from stdin
+func ReadPlan(location string) plan.Plan {
+	var input io.Reader = os.Stdin
+	if location != "-" {
+		var err error
+		input, err = os.OpenFile(location, os.O_RDONLY, 0)
+		PanicOnErr(err)
+	}
+	data, err := io.ReadAll(input)
+	PanicOnErr(err)
+	plan, err := plan.FromYAML(data)
+	PanicOnErr(err)
+	return plan
+}
@@ -2,19 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"runtime"
 	"time"
 
 	"github.com/alexflint/go-arg"
 
+	"github.com/bcap/kaller/cgroup"
 	"github.com/bcap/kaller/cmd"
 	"github.com/bcap/kaller/handler"
+	"github.com/bcap/kaller/plan"
 	srv "github.com/bcap/kaller/server"
 )
 
 type Args struct {
 	ListenAddress string `arg:"-l,--listen,env:LISTEN_ADDRESS" default:":8080" help:"Which address to listen to"`
+	TCPAddress    string `arg:"--tcp-listen,env:TCP_LISTEN_ADDRESS" help:"Which address to listen to for tcp calls, eg :9000. Disabled by default"`
+	UDPAddress    string `arg:"--udp-listen,env:UDP_LISTEN_ADDRESS" help:"Which address to listen to for udp calls, eg :9000. Disabled by default"`
+	GRPCAddress   string `arg:"--grpc-listen,env:GRPC_LISTEN_ADDRESS" help:"Which address to listen to for grpc calls, eg :9090. Disabled by default"`
+	ServiceName   string `arg:"-n,--service-name,env:SERVICE_NAME" help:"Which service of the plan this server plays. Used for plan service overrides and reported in logs, responses and request traces"`
+	CapCPU        bool   `arg:"--cap-cpu,env:CAP_CPU" help:"Cap the cpu load of computes to the cpus available to the process according to its cgroup cpu quota, avoiding throttling"`
+	ScratchDir    string `arg:"--scratch-dir,env:SCRATCH_DIR" help:"Directory where io steps read and write files, which they cannot leave. Defaults to the system temporary directory"`
+	TLSCert       string `arg:"--tls-cert,env:TLS_CERT" help:"PEM file with the certificate to serve https with. Requires --tls-key"`
+	TLSKey        string `arg:"--tls-key,env:TLS_KEY" help:"PEM file with the key of the --tls-cert certificate"`
+	TLSSelfSigned string `arg:"--tls-self-signed,env:TLS_SELF_SIGNED" help:"Serve https with a certificate issued by a self-signed authority kept in this directory, which is created if needed. Servers sharing the directory trust each other. Meant for local runs"`
+	TLSClientCA   string `arg:"--tls-client-ca,env:TLS_CLIENT_CA" help:"PEM bundle with the authorities client certificates must be signed by. Enables mutual tls"`
 }
 
 func main() {
@@ -29,7 +44,56 @@ func main() {
 	addr, err := server.Listen(ctx, args.ListenAddress)
 	cmd.PanicOnErr(err)
 
-	log.Printf("Caller server running with pid %v and listening on %v", os.Getpid(), addr.AddrPort())
+	log.Printf("Caller server %q running with pid %v and listening on %v", args.ServiceName, os.Getpid(), addr.AddrPort())
+
+	if args.TLSCert != "" || args.TLSKey != "" {
+		server.TLSConfig, err = srv.TLSConfig(args.TLSCert, args.TLSKey, args.TLSClientCA)
+		cmd.PanicOnErr(err)
+	} else if args.TLSSelfSigned != "" {
+		hosts := []string{}
+		if args.ServiceName != "" {
+			hosts = append(hosts, args.ServiceName)
+		}
+		server.TLSConfig, err = srv.SelfSignedTLSConfig(args.TLSSelfSigned, args.TLSClientCA, hosts...)
+		cmd.PanicOnErr(err)
+		log.Printf("Using self-signed certificates from %s", args.TLSSelfSigned)
+	} else if args.TLSClientCA != "" {
+		cmd.PanicOnErr(errors.New("--tls-client-ca requires either --tls-cert or --tls-self-signed"))
+	}
+	if server.TLSConfig != nil {
+		mtls := ""
+		if args.TLSClientCA != "" {
+			mtls = ", requiring client certificates"
+		}
+		log.Printf("Serving https%s", mtls)
+	}
+
+	if args.TCPAddress != "" {
+		tcpAddr, err := server.ListenTCP(ctx, args.TCPAddress)
+		cmd.PanicOnErr(err)
+		log.Printf("Listening for tcp calls on %v", tcpAddr.AddrPort())
+	}
+	if args.UDPAddress != "" {
+		udpAddr, err := server.ListenUDP(ctx, args.UDPAddress)
+		cmd.PanicOnErr(err)
+		log.Printf("Listening for udp calls on %v", udpAddr.AddrPort())
+	}
+	if args.GRPCAddress != "" {
+		grpcAddr, err := server.ListenGRPC(ctx, args.GRPCAddress)
+		cmd.PanicOnErr(err)
+		log.Printf("Listening for grpc calls on %v", grpcAddr.AddrPort())
+	}
+
+	quota, err := cgroup.CPUQuota()
+	if err == nil {
+		log.Printf("Detected cgroup cpu quota of %.2f cpus (%d cores available)", quota, runtime.NumCPU())
+	} else if err != cgroup.ErrNoQuota {
+		log.Printf("Failed to detect cgroup cpu quota: %v", err)
+	}
+	if args.CapCPU {
+		plan.MaxCPU = cgroup.EffectiveCPU()
+		log.Printf("Capping compute cpu load to %.2f cpus", plan.MaxCPU)
+	}
 
 	cmd.InstallSignalHandler(
 		func(signal os.Signal) {
@@ -40,13 +104,32 @@ func main() {
 		os.Interrupt,
 	)
 
-	err = server.Serve(handler.New(ctx))
+	h := handler.New(ctx)
+	h.ServiceName = args.ServiceName
+	h.ScratchDir = args.ScratchDir
+	if args.TCPAddress != "" {
+		go serve(server.ServeTCP, h)
+	}
+	if args.UDPAddress != "" {
+		go serve(server.ServeUDP, h)
+	}
+	if args.GRPCAddress != "" {
+		go serve(server.ServeGRPC, h)
+	}
+	err = server.Serve(h)
 	if !srv.IsClosedError(err) {
 		cmd.PanicOnErr(err)
 	}
 	log.Println("Caller server succesfully shutdown")
 }
 
+func serve(serve func(http.Handler) error, handler http.Handler) {
+	err := serve(handler)
+	if !srv.IsClosedError(err) {
+		cmd.PanicOnErr(err)
+	}
+}
+
 func parseArgs() Args {
 	var args Args
 	arg.MustParse(&args)
@@ -0,0 +1,102 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"text/template"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// ComposeOptions controls how docker-compose files are generated
+type ComposeOptions struct {
+	Image string
+
+	// MinCPU and BaseMemoryMB work the same as in KubernetesOptions
+	MinCPU       float64
+	BaseMemoryMB int
+
+	// PlanFile, when set, adds a client service that runs the kaller client with the
+	// plan file mounted from the host. The path is relative to the compose file
+	PlanFile string
+}
+
+func DefaultComposeOptions() ComposeOptions {
+	return ComposeOptions{
+		Image:        "bcap/kaller",
+		MinCPU:       0.1,
+		BaseMemoryMB: 64,
+	}
+}
+
+// Compose writes a docker-compose file with a service for each distinct service in the
+// plan. Each service listens on the port used in the plan urls (80 by default, or 443 for
+// https urls) and is reachable in the compose network by the plan host name. Services only
+// listen in plaintext, so plans with calls made over TLS are rejected
+func Compose(w io.Writer, plan ptype.Plan, options ComposeOptions) error {
+	if call := plan.TLSCall(); call != nil {
+		return fmt.Errorf("cannot generate compose services for call %s: tls is not supported, as no certificates are mounted", call.String())
+	}
+	services := []map[string]any{}
+	names := []string{}
+	for _, service := range Services(plan) {
+		port := service.HTTPPort
+		if port == 0 {
+			port = 80
+		}
+		services = append(services, map[string]any{
+			"Name":     service.Name,
+			"Hostname": service.Hostname(),
+			"Port":     port,
+			"CPU":      fmt.Sprintf("%.2f", math.Max(service.PeakCPU, options.MinCPU)),
+			"Memory":   fmt.Sprintf("%dm", options.BaseMemoryMB+int(math.Ceil(float64(service.PeakMemoryKB)/1024))),
+			"TCPPort":  service.TCPPort,
+			"UDPPort":  service.UDPPort,
+			"GRPCPort": service.GRPCPort,
+		})
+		names = append(names, service.Name)
+	}
+	return composeTemplate.Execute(w, map[string]any{
+		"Image":    options.Image,
+		"Services": services,
+		"Names":    names,
+		"PlanFile": options.PlanFile,
+	})
+}
+
+var composeTemplate = template.Must(template.New("compose").Parse(`services:
+{{- range .Services}}
+  {{.Name}}:
+    image: {{$.Image}}
+    environment:
+      LISTEN_ADDRESS: ":{{.Port}}"
+      SERVICE_NAME: {{.Name}}
+      {{- if .TCPPort}}
+      TCP_LISTEN_ADDRESS: ":{{.TCPPort}}"
+      {{- end}}
+      {{- if .UDPPort}}
+      UDP_LISTEN_ADDRESS: ":{{.UDPPort}}"
+      {{- end}}
+      {{- if .GRPCPort}}
+      GRPC_LISTEN_ADDRESS: ":{{.GRPCPort}}"
+      {{- end}}
+    cpus: "{{.CPU}}"
+    mem_limit: {{.Memory}}
+    networks:
+      default:
+        aliases:
+        - {{.Hostname}}
+{{- end}}
+{{- if .PlanFile}}
+  client:
+    image: {{.Image}}
+    entrypoint: ["/app/client", "/plan/plan.yaml"]
+    volumes:
+    - {{.PlanFile}}:/plan/plan.yaml:ro
+    depends_on:
+{{- range .Names}}
+    - {{.}}
+{{- end}}
+{{- end}}
+`))
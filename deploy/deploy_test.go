@@ -0,0 +1,243 @@
+package deploy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+var plan1 = `
+execution:
+- loop:
+  times: 100
+  concurrency: 4
+  execution:
+  - call:
+    http: GET localhost:8080/run 200
+    execution:
+    - call:
+      http: GET svc1/listing 200
+      compute: 10ms 0.5 cpu +1mb
+      execution:
+      - compute: 10ms +2mb
+      - parallel:
+        concurrency: 2
+        execution:
+        - call:
+          http: GET svc2:8080/product?id=1 200
+          compute: 10ms 1.5 cpu
+        - call:
+          http: GET svc2:8080/product?id=2 200
+          compute: 10ms 0.2 cpu +10mb
+        - call:
+          http: GET Svc_3/profile 200
+`
+
+func TestServices(t *testing.T) {
+	services := Services(load(t, plan1))
+	assert.Equal(t,
+		[]Service{
+			{Name: "svc1", Host: "svc1", PeakCPU: 0.5 * 4, PeakMemoryKB: 3 * 1024 * 4, HTTPPort: 80},
+			{Name: "svc2", Host: "svc2:8080", PeakCPU: 1.5 * 4 * 2, PeakMemoryKB: 10 * 1024 * 4 * 2, HTTPPort: 8080},
+			{Name: "svc-3", Host: "Svc_3", PeakCPU: 0, PeakMemoryKB: 0, HTTPPort: 80},
+		},
+		services,
+	)
+}
+
+var planSockets = `
+execution:
+- call:
+  http: GET svc1/listing 200
+  execution:
+  - call:
+    tcp: svc1:7000/reserve 200
+  - call:
+    udp: svc2/stats 200
+  - call:
+    grpc: svc2/lookup 200
+`
+
+func TestServicesSockets(t *testing.T) {
+	services := Services(load(t, planSockets))
+	assert.Equal(t,
+		[]Service{
+			{Name: "svc1", Host: "svc1", HTTPPort: 80, TCPPort: 7000},
+			{Name: "svc2", Host: "svc2", UDPPort: 9000, GRPCPort: 9090},
+		},
+		services,
+	)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, Kubernetes(&buf, load(t, planSockets), DefaultKubernetesOptions()))
+	assert.Contains(t, buf.String(), "  - name: tcp\n    port: 7000\n    targetPort: 9000\n    protocol: TCP\n")
+	assert.Contains(t, buf.String(), "  - name: udp\n    port: 9000\n    targetPort: 9000\n    protocol: UDP\n")
+	assert.Contains(t, buf.String(), "  - name: grpc\n    port: 9090\n    targetPort: 9090\n    appProtocol: grpc\n")
+	assert.Contains(t, buf.String(), "        - name: TCP_LISTEN_ADDRESS\n          value: \":9000\"\n")
+	assert.Contains(t, buf.String(), "        - name: GRPC_LISTEN_ADDRESS\n          value: \":9090\"\n")
+}
+
+var planHTTPS = `
+execution:
+- call:
+  http: GET https://secure/orders 200
+  execution:
+  - call:
+    http: GET https://other:8443/x 200
+`
+
+func TestServicesHTTPS(t *testing.T) {
+	services := Services(load(t, planHTTPS))
+	assert.Equal(t,
+		[]Service{
+			{Name: "secure", Host: "secure", HTTPPort: 443},
+			{Name: "other", Host: "other:8443", HTTPPort: 8443},
+		},
+		services,
+	)
+
+	plan := load(t, planHTTPS)
+	buf := bytes.Buffer{}
+	require.NoError(t, Kubernetes(&buf, plan, DefaultKubernetesOptions()))
+	assert.Contains(t, buf.String(), "  - name: http\n    port: 443\n    targetPort: 8080\n")
+
+	// compose does not mount certificates, so services cannot serve tls
+	err := Compose(&bytes.Buffer{}, plan, DefaultComposeOptions())
+	assert.ErrorContains(t, err, "call GET https://secure/orders 200: tls is not supported")
+
+	plan = load(t, "execution:\n- call:\n  http: {method: GET, url: svc1/a, tls: {skip-verify: true}}\n")
+	assert.Error(t, Compose(&bytes.Buffer{}, plan, DefaultComposeOptions()))
+}
+
+var planLoop = `
+execution:
+- call:
+  http: GET svc1/listing 200
+  execution:
+  - loop:
+    times: 10
+    concurrency: 2
+    compute: 1ms +1mb
+    execution:
+    - compute: 10ms 0.5 cpu +2mb
+`
+
+func TestServicesLoop(t *testing.T) {
+	services := Services(load(t, planLoop))
+	assert.Equal(t,
+		[]Service{{Name: "svc1", Host: "svc1", PeakCPU: 0.5 * 2, PeakMemoryKB: 3 * 1024 * 10, HTTPPort: 80}},
+		services,
+	)
+}
+
+var planLeak = `
+execution:
+- call:
+  http: GET svc1/listing 200
+  compute: 10ms 0.5 cpu +1mb
+  execution:
+  - compute:
+      min: 1ms
+      memory-delta-kb: 2048
+      memory-scope: process
+      memory-cap-kb: 102400
+  - compute:
+      min: 1ms
+      memory-delta-kb: 512
+      memory-scope: process
+      memory-backing: mmap
+service-overrides:
+  svc1:
+    cpu-multiplier: 3
+`
+
+func TestServicesProcessMemory(t *testing.T) {
+	services := Services(load(t, planLeak))
+	assert.Equal(t,
+		[]Service{{
+			Name:            "svc1",
+			Host:            "svc1",
+			PeakCPU:         0.5 * 3,
+			PeakMemoryKB:    1024 + 102400 + 512,
+			ProcessMemoryKB: 102400 + 512,
+			HTTPPort:        80,
+		}},
+		services,
+	)
+
+	buf := bytes.Buffer{}
+	require.NoError(t, Kubernetes(&buf, load(t, planLeak), DefaultKubernetesOptions()))
+	assert.Contains(t, buf.String(), "            cpu: 1500m\n            memory: 166Mi\n")
+}
+
+func TestKubernetes(t *testing.T) {
+	options := DefaultKubernetesOptions()
+	options.ClientJob = true
+	buf := bytes.Buffer{}
+	require.NoError(t, Kubernetes(&buf, load(t, strings.ReplaceAll(plan1, "Svc_3", "Svc3")), options))
+	out := buf.String()
+
+	assert.Equal(t, 7, strings.Count(out, "\n---\n"))
+	assert.Contains(t, out, "kind: Deployment\nmetadata:\n  name: svc2\n")
+	assert.Contains(t, out, "        - name: SERVICE_NAME\n          value: svc2\n")
+	assert.Contains(t, out, "            cpu: 12000m\n            memory: 144Mi\n")
+	assert.Contains(t, out, "            cpu: 100m\n            memory: 64Mi\n")
+	assert.Contains(t, out, "kind: Service\nmetadata:\n  name: svc3\n")
+	assert.Contains(t, out, "  name: svc2\nspec:\n  type: LoadBalancer\n  selector:\n    app: svc2\n  ports:\n  - name: http\n    port: 8080\n    targetPort: 8080\n")
+	assert.Contains(t, out, "  - name: http\n    port: 80\n    targetPort: 8080\n")
+	assert.Contains(t, out, "  plan.yaml: |\n    execution:\n")
+	assert.Contains(t, out, "kind: Job\n")
+
+	err := Kubernetes(&bytes.Buffer{}, load(t, plan1), options)
+	assert.ErrorContains(t, err, `host "Svc_3" cannot be reached as a kubernetes service`)
+}
+
+func load(t *testing.T, yaml string) ptype.Plan {
+	plan, err := ptype.FromYAML([]byte(strings.TrimSpace(yaml)))
+	require.NoError(t, err)
+	return plan
+}
+
+func TestCompose(t *testing.T) {
+	options := DefaultComposeOptions()
+	options.PlanFile = "./plan.yaml"
+	buf := bytes.Buffer{}
+	require.NoError(t, Compose(&buf, load(t, plan1), options))
+
+	var compose struct {
+		Services map[string]struct {
+			Image       string            `yaml:"image"`
+			Environment map[string]string `yaml:"environment"`
+			CPUs        string            `yaml:"cpus"`
+			MemLimit    string            `yaml:"mem_limit"`
+			Networks    map[string]struct {
+				Aliases []string `yaml:"aliases"`
+			} `yaml:"networks"`
+			Volumes   []string `yaml:"volumes"`
+			DependsOn []string `yaml:"depends_on"`
+		} `yaml:"services"`
+	}
+	require.NoError(t, yaml.Unmarshal(buf.Bytes(), &compose))
+	require.Equal(t, 4, len(compose.Services))
+
+	svc2 := compose.Services["svc2"]
+	assert.Equal(t, "bcap/kaller", svc2.Image)
+	assert.Equal(t, ":8080", svc2.Environment["LISTEN_ADDRESS"])
+	assert.Equal(t, "svc2", svc2.Environment["SERVICE_NAME"])
+	assert.Equal(t, "12.00", svc2.CPUs)
+	assert.Equal(t, "144m", svc2.MemLimit)
+
+	svc3 := compose.Services["svc-3"]
+	assert.Equal(t, ":80", svc3.Environment["LISTEN_ADDRESS"])
+	assert.Equal(t, []string{"Svc_3"}, svc3.Networks["default"].Aliases)
+
+	client := compose.Services["client"]
+	assert.Equal(t, []string{"./plan.yaml:/plan/plan.yaml:ro"}, client.Volumes)
+	assert.Equal(t, []string{"svc1", "svc2", "svc-3"}, client.DependsOn)
+}
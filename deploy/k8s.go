@@ -0,0 +1,255 @@
+package deploy
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"text/template"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// KubernetesOptions controls how kubernetes manifests are generated
+type KubernetesOptions struct {
+	Image    string
+	Replicas int
+
+	// MinCPU and BaseMemoryMB are the resource limits given to services that do not
+	// simulate any cpu load or memory usage. Simulated peaks are added on top of them
+	MinCPU       float64
+	BaseMemoryMB int
+
+	// ClientJob also emits a ConfigMap holding the plan and a Job running the kaller
+	// client against it
+	ClientJob bool
+}
+
+func DefaultKubernetesOptions() KubernetesOptions {
+	return KubernetesOptions{
+		Image:        "bcap/kaller",
+		Replicas:     2,
+		MinCPU:       0.1,
+		BaseMemoryMB: 64,
+	}
+}
+
+// Kubernetes writes a Deployment and a Service for each distinct service in the plan, with
+// resource limits derived from the service peak cpu and memory usage. See Services
+//
+// Each Service is named after the plan host and exposes the ports used in the plan urls, so
+// plan calls reach it through cluster DNS unchanged. Plan host names must therefore be valid
+// DNS-1123 labels (eg: no underscores or dots), otherwise an error is returned
+func Kubernetes(w io.Writer, plan ptype.Plan, options KubernetesOptions) error {
+	documents := []string{}
+	for _, service := range Services(plan) {
+		if !dnsLabel.MatchString(service.Name) || service.Name != strings.ToLower(service.Hostname()) {
+			return fmt.Errorf(
+				"host %q cannot be reached as a kubernetes service, its name must be a DNS-1123 label",
+				service.Host,
+			)
+		}
+		httpPort := service.HTTPPort
+		if httpPort == 0 {
+			httpPort = 80
+		}
+		data := map[string]any{
+			"Name":     service.Name,
+			"HTTPPort": httpPort,
+			"Image":    options.Image,
+			"Replicas": options.Replicas,
+			"CPU":      cpuLimit(service.PeakCPU, options.MinCPU),
+			"Memory":   memoryLimit(service.PeakMemoryKB, options.BaseMemoryMB),
+			"TCPPort":  service.TCPPort,
+			"UDPPort":  service.UDPPort,
+			"GRPCPort": service.GRPCPort,
+		}
+		doc, err := execTemplate(serviceTemplate, data)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, doc)
+	}
+
+	if options.ClientJob {
+		encodedPlan, err := plan.ToYAML()
+		if err != nil {
+			return err
+		}
+		data := map[string]any{
+			"Image": options.Image,
+			"Plan":  indent(string(encodedPlan), "    "),
+		}
+		doc, err := execTemplate(clientTemplate, data)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, doc)
+	}
+
+	_, err := io.WriteString(w, strings.Join(documents, "\n---\n\n"))
+	return err
+}
+
+// dnsLabel matches DNS-1123 labels, which kubernetes requires for service names
+var dnsLabel = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+
+func cpuLimit(peak float64, min float64) string {
+	return fmt.Sprintf("%dm", int(math.Ceil(math.Max(peak, min)*1000)))
+}
+
+func memoryLimit(peakKB int, baseMB int) string {
+	return fmt.Sprintf("%dMi", baseMB+int(math.Ceil(float64(peakKB)/1024)))
+}
+
+func execTemplate(tmpl *template.Template, data any) (string, error) {
+	buf := strings.Builder{}
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func indent(s string, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for idx := range lines {
+		lines[idx] = prefix + lines[idx]
+	}
+	return strings.Join(lines, "\n")
+}
+
+var serviceTemplate = template.Must(template.New("service").Parse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+  labels:
+    app: {{.Name}}
+spec:
+  replicas: {{.Replicas}}
+  selector:
+    matchLabels:
+      app: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app: {{.Name}}
+    spec:
+      containers:
+      - name: {{.Name}}
+        image: {{.Image}}
+        env:
+        - name: SERVICE_NAME
+          value: {{.Name}}
+        {{- if .TCPPort}}
+        - name: TCP_LISTEN_ADDRESS
+          value: ":9000"
+        {{- end}}
+        {{- if .UDPPort}}
+        - name: UDP_LISTEN_ADDRESS
+          value: ":9000"
+        {{- end}}
+        {{- if .GRPCPort}}
+        - name: GRPC_LISTEN_ADDRESS
+          value: ":9090"
+        {{- end}}
+        ports:
+        - containerPort: 8080
+        {{- if .TCPPort}}
+        - containerPort: 9000
+          protocol: TCP
+        {{- end}}
+        {{- if .UDPPort}}
+        - containerPort: 9000
+          protocol: UDP
+        {{- end}}
+        {{- if .GRPCPort}}
+        - containerPort: 9090
+        {{- end}}
+        resources:
+          limits:
+            cpu: {{.CPU}}
+            memory: {{.Memory}}
+
+        # necessary, check notes at
+        # https://kind.sigs.k8s.io/docs/user/quick-start/#loading-an-image-into-your-cluster
+        imagePullPolicy: IfNotPresent
+
+---
+
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{.Name}}
+spec:
+  type: LoadBalancer
+  selector:
+    app: {{.Name}}
+  ports:
+  - name: http
+    port: {{.HTTPPort}}
+    targetPort: 8080
+  {{- if .TCPPort}}
+  - name: tcp
+    port: {{.TCPPort}}
+    targetPort: 9000
+    protocol: TCP
+  {{- end}}
+  {{- if .UDPPort}}
+  - name: udp
+    port: {{.UDPPort}}
+    targetPort: 9000
+    protocol: UDP
+  {{- end}}
+  {{- if .GRPCPort}}
+  - name: grpc
+    port: {{.GRPCPort}}
+    targetPort: 9090
+    appProtocol: grpc
+  {{- end}}
+`))
+
+var clientTemplate = template.Must(template.New("client").Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: client-plan
+data:
+  plan.yaml: |
+{{.Plan}}
+
+---
+
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: client
+spec:
+  completions: 1
+  backoffLimit: 0
+  template:
+    metadata:
+      annotations:
+        sidecar.istio.io/inject: "false"
+    spec:
+      restartPolicy: Never
+      containers:
+      - name: client
+        image: {{.Image}}
+        command: ["/app/client", "/plan/plan.yaml"]
+        ports:
+        - containerPort: 8080
+        resources:
+          limits:
+            cpu: 500m
+            memory: 200Mi
+        volumeMounts:
+        - name: plan
+          mountPath: /plan
+        # necessary, check notes at
+        # https://kind.sigs.k8s.io/docs/user/quick-start/#loading-an-image-into-your-cluster
+        imagePullPolicy: IfNotPresent
+      volumes:
+      - name: plan
+        configMap:
+          name: client-plan
+`))
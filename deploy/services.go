@@ -0,0 +1,252 @@
+package deploy
+
+import (
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// Service is a distinct host found in a plan, together with the peak resources it is
+// expected to use
+type Service struct {
+	// Name is the host name sanitized to be used as a resource name (eg: kubernetes
+	// deployments and services)
+	Name string
+	// Host is the host as found in the plan urls, possibly including a port
+	Host string
+	// PeakCPU is the highest cpu load expected in the service, scaled by the service cpu
+	// multiplier override, if any
+	PeakCPU float64
+	// PeakMemoryKB is the highest amount of memory expected to be held by simulated
+	// computations in the service, including ProcessMemoryKB
+	PeakMemoryKB int
+	// ProcessMemoryKB is the memory held across requests by computes with the process memory
+	// scope (leaks). Capped leaks count with their cap. Uncapped leaks grow with every request
+	// and cannot be bounded, so only the growth of a single request to each call is counted
+	ProcessMemoryKB int
+	// HTTPPort is the port http calls to the service target, or 0 if the service gets no such
+	// calls
+	HTTPPort int
+	// TCPPort, UDPPort and GRPCPort are the ports tcp, udp and grpc calls to the service target,
+	// or 0 if the service gets no such calls
+	TCPPort  int
+	UDPPort  int
+	GRPCPort int
+}
+
+// Services scans the plan for distinct hosts, in order of appearance, and estimates their
+// peak resource usage.
+//
+// Peaks are estimated per call and multiplied by how many of those calls can be in flight
+// at the same time, given the concurrency of the parallel blocks and loops that lead to
+// the call. When a service is called from multiple places, the highest peak is used.
+// Memory held by the process across requests is added on top of that. Service overrides
+// are matched by service name. Hosts pointing to localhost are skipped
+func Services(plan ptype.Plan) []Service {
+	s := scanner{byHost: map[string]*Service{}, process: map[string]*processMemory{}}
+	s.execution(plan.Execution, 1)
+	result := make([]Service, len(s.order))
+	for idx, host := range s.order {
+		service := *s.byHost[host]
+		if multiplier := plan.ServiceOverrides[service.Name].CPUMultiplier; multiplier > 0 {
+			service.PeakCPU *= multiplier
+		}
+		if process := s.process[host]; process != nil {
+			service.ProcessMemoryKB = process.total()
+			service.PeakMemoryKB += service.ProcessMemoryKB
+		}
+		result[idx] = service
+	}
+	return result
+}
+
+type scanner struct {
+	order   []string
+	byHost  map[string]*Service
+	process map[string]*processMemory
+}
+
+// processMemory tracks the memory held by the process fills of a service, one per backing
+type processMemory struct {
+	// highest cap of the capped process computes
+	cap map[ptype.MemoryBacking]int
+	// growth of the uncapped process computes
+	growth map[ptype.MemoryBacking]int
+}
+
+func (p *processMemory) total() int {
+	total := 0
+	for _, cap := range p.cap {
+		total += cap
+	}
+	for _, growth := range p.growth {
+		total += growth
+	}
+	return total
+}
+
+func (s *scanner) execution(execution ptype.Execution, concurrency int) {
+	for _, step := range execution {
+		s.step(step, concurrency)
+	}
+}
+
+func (s *scanner) step(step ptype.Step, concurrency int) {
+	switch v := step.(type) {
+	case *ptype.Call:
+		s.call(v, concurrency)
+	case *ptype.Parallel:
+		s.execution(v.Execution, concurrency*parallelConcurrency(v))
+	case *ptype.Loop:
+		s.execution(v.Execution, concurrency*loopConcurrency(v))
+	case *ptype.Contend:
+		s.execution(v.Execution, concurrency)
+	}
+}
+
+// parallelConcurrency returns how many steps of the parallel block run at the same time
+func parallelConcurrency(parallel *ptype.Parallel) int {
+	if parallel.Concurrency <= 0 || parallel.Concurrency > len(parallel.Execution) {
+		return len(parallel.Execution)
+	}
+	return parallel.Concurrency
+}
+
+// loopConcurrency returns how many iterations of the loop run at the same time
+func loopConcurrency(loop *ptype.Loop) int {
+	concurrency := loop.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if concurrency > loop.Times {
+		concurrency = loop.Times
+	}
+	return concurrency
+}
+
+func (s *scanner) call(call *ptype.Call, concurrency int) {
+	host := call.Host()
+	if !isLocal(host) {
+		service := s.service(call)
+		process := s.process[service.Host]
+		if process == nil {
+			process = &processMemory{cap: map[ptype.MemoryBacking]int{}, growth: map[ptype.MemoryBacking]int{}}
+			s.process[service.Host] = process
+		}
+		cpu, memory := callPeaks(call, process)
+		service.PeakCPU = math.Max(service.PeakCPU, cpu*float64(concurrency))
+		if peak := memory * concurrency; peak > service.PeakMemoryKB {
+			service.PeakMemoryKB = peak
+		}
+	}
+	s.execution(call.Execution, concurrency)
+	s.execution(call.PostExecution, concurrency)
+}
+
+// service returns the service the call targets. Tcp, udp and grpc calls are matched to services
+// by host name only, as their ports are not the port the service listens to for http calls
+func (s *scanner) service(call *ptype.Call) *Service {
+	host := call.Host()
+	socket := call.Socket()
+	if socket != nil {
+		host = socket.URL.Hostname()
+		for _, existing := range s.order {
+			if s.byHost[existing].Name == resourceName(host) {
+				host = existing
+				break
+			}
+		}
+	}
+	service, ok := s.byHost[host]
+	if !ok {
+		service = &Service{Name: resourceName(host), Host: host}
+		s.byHost[host] = service
+		s.order = append(s.order, host)
+	}
+	_, portStr, _ := net.SplitHostPort(call.Address())
+	port, _ := strconv.Atoi(portStr)
+	switch call.Kind() {
+	case ptype.CallKindTCP:
+		service.TCPPort = port
+	case ptype.CallKindUDP:
+		service.UDPPort = port
+	case ptype.CallKindGRPC:
+		service.GRPCPort = port
+	default:
+		service.HTTPPort = port
+	}
+	return service
+}
+
+// callPeaks returns the highest cpu load and the sum of request memory growth of the computes
+// that run while handling a single call. Loops add up the memory growth of each of their
+// iterations, while computes running at the same time in parallel blocks and loops add up
+// their cpu load. Memory held by process scoped computes is tracked in process instead
+func callPeaks(call *ptype.Call, process *processMemory) (float64, int) {
+	cpu := 0.0
+	memory := 0
+	add := func(compute ptype.Compute, times int, concurrency int) {
+		cpu = math.Max(cpu, compute.CPU*float64(concurrency))
+		if compute.MemoryDeltaKB <= 0 {
+			return
+		}
+		if compute.MemoryScope != ptype.MemoryScopeProcess {
+			memory += compute.MemoryDeltaKB * times
+			return
+		}
+		backing := compute.MemoryBacking
+		if backing == "" {
+			backing = ptype.MemoryBackingHeap
+		}
+		if compute.MemoryCapKB > 0 {
+			if compute.MemoryCapKB > process.cap[backing] {
+				process.cap[backing] = compute.MemoryCapKB
+			}
+		} else {
+			process.growth[backing] += compute.MemoryDeltaKB * times
+		}
+	}
+	var visit func(execution ptype.Execution, times int, concurrency int)
+	visit = func(execution ptype.Execution, times int, concurrency int) {
+		for _, step := range execution {
+			switch v := step.(type) {
+			case *ptype.Compute:
+				add(*v, times, concurrency)
+			case *ptype.Parallel:
+				visit(v.Execution, times, concurrency*parallelConcurrency(v))
+			case *ptype.Loop:
+				iterations, concurrent := times*v.Times, concurrency*loopConcurrency(v)
+				add(v.Compute, iterations, concurrent)
+				visit(v.Execution, iterations, concurrent)
+			case *ptype.Contend:
+				visit(v.Execution, times, concurrency)
+			}
+		}
+	}
+	add(call.Compute, 1, 1)
+	visit(call.Execution, 1, 1)
+	visit(call.PostExecution, 1, 1)
+	return cpu, memory
+}
+
+// Hostname returns the service host without its port
+func (s Service) Hostname() string {
+	return strings.Split(s.Host, ":")[0]
+}
+
+func isLocal(host string) bool {
+	hostname := strings.Split(host, ":")[0]
+	return hostname == "" || hostname == "localhost" || strings.HasPrefix(hostname, "127.")
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+func resourceName(host string) string {
+	hostname := strings.Split(host, ":")[0]
+	name := invalidNameChars.ReplaceAllString(strings.ToLower(hostname), "-")
+	return strings.Trim(name, "-")
+}
@@ -0,0 +1,7 @@
+package disk
+
+import "syscall"
+
+const directSupported = true
+
+const directFlag = syscall.O_DIRECT
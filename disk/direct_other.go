@@ -0,0 +1,7 @@
+//go:build !linux
+
+package disk
+
+const directSupported = false
+
+const directFlag = 0
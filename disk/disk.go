@@ -0,0 +1,287 @@
+// Package disk generates disk-bound load by reading and writing files in a scratch directory
+package disk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+// DefaultBlockSize is the size of each read or write when no block size is given
+const DefaultBlockSize = 4096
+
+// directAlignment is the alignment required for buffers, offsets and sizes of direct I/O
+const directAlignment = 4096
+
+type Op string
+
+const (
+	Read  Op = "read"
+	Write Op = "write"
+)
+
+type Pattern string
+
+const (
+	Sequential Pattern = "sequential"
+	Random     Pattern = "random"
+)
+
+// Fsync is when written data is flushed to the storage device
+type Fsync string
+
+const (
+	// FsyncNever leaves flushing the written data to the operating system
+	FsyncNever Fsync = "never"
+	// FsyncEnd flushes once all data is written
+	FsyncEnd Fsync = "end"
+	// FsyncAlways flushes after every block written
+	FsyncAlways Fsync = "always"
+)
+
+// Options describes the I/O to run
+type Options struct {
+	Op Op
+	// Size is how many bytes are read or written in total
+	Size int64
+	// BlockSize is how many bytes are read or written at a time. Defaults to DefaultBlockSize
+	BlockSize int
+	Pattern   Pattern
+	Fsync     Fsync
+	// Rate caps the throughput in bytes per second. Zero means no cap
+	Rate int64
+	// Direct bypasses the page cache, so reads actually hit the storage device. Requires
+	// BlockSize to be a multiple of 4kb and is only supported on linux
+	Direct bool
+	// Dir is the scratch directory files are created in. Defaults to os.TempDir()
+	Dir string
+}
+
+// Result is what an I/O run did
+type Result struct {
+	Bytes    int64
+	Blocks   int
+	Duration time.Duration
+}
+
+// Run reads or writes Size bytes in blocks of BlockSize bytes, following the given pattern and
+// fsync policy, until done or the context is done
+//
+// Writes go to a new file that is removed once done. Reads come from the first Size bytes of a
+// single file per directory, which is created on first use and then reused by all reads, the
+// same way services keep reading their data files. The file only grows when a read needs more
+// data than it has, so it is as large as the largest read. Unless Direct is set, reads are then
+// likely served from the page cache
+func Run(ctx context.Context, opts Options, rnd *rand.Rand) (Result, error) {
+	if err := opts.Validate(); err != nil {
+		return Result{}, err
+	}
+	if opts.BlockSize == 0 {
+		opts.BlockSize = DefaultBlockSize
+	}
+	if opts.Dir == "" {
+		opts.Dir = os.TempDir()
+	}
+	if opts.Size == 0 {
+		return Result{}, nil
+	}
+
+	var file *os.File
+	var err error
+	if opts.Op == Read {
+		file, err = openReadFile(opts)
+	} else {
+		file, err = createWriteFile(opts)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	defer file.Close()
+
+	buf := alignedBuffer(opts.BlockSize)
+	if opts.Op == Write {
+		rnd.Read(buf)
+	}
+	blocks := int((opts.Size + int64(opts.BlockSize) - 1) / int64(opts.BlockSize))
+	result := Result{}
+	start := time.Now()
+	for block := 0; block < blocks; block++ {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		idx := block
+		if opts.Pattern == Random {
+			idx = rnd.Intn(blocks)
+		}
+		offset := int64(idx) * int64(opts.BlockSize)
+		length := int64(opts.BlockSize)
+		if remaining := opts.Size - offset; remaining < length && !opts.Direct {
+			length = remaining
+		}
+		var n int
+		if opts.Op == Read {
+			n, err = file.ReadAt(buf[:length], offset)
+		} else {
+			n, err = file.WriteAt(buf[:length], offset)
+			if err == nil && opts.Fsync == FsyncAlways {
+				err = file.Sync()
+			}
+		}
+		result.Bytes += int64(n)
+		result.Blocks++
+		if err != nil {
+			return result, fmt.Errorf("failed to %s %s at offset %d: %w", opts.Op, file.Name(), offset, err)
+		}
+		throttle(ctx, start, result.Bytes, opts.Rate)
+	}
+	if opts.Op == Write && opts.Fsync == FsyncEnd {
+		if err := file.Sync(); err != nil {
+			return result, fmt.Errorf("failed to sync %s: %w", file.Name(), err)
+		}
+	}
+	result.Duration = time.Since(start)
+	return result, nil
+}
+
+// Validate checks whether the options are valid, without touching the file system
+func (o Options) Validate() error {
+	switch o.Op {
+	case Read, Write:
+	default:
+		return fmt.Errorf("unknown operation %q", o.Op)
+	}
+	switch o.Pattern {
+	case "", Sequential, Random:
+	default:
+		return fmt.Errorf("unknown pattern %q", o.Pattern)
+	}
+	switch o.Fsync {
+	case "", FsyncNever, FsyncEnd, FsyncAlways:
+	default:
+		return fmt.Errorf("unknown fsync policy %q", o.Fsync)
+	}
+	if o.Size < 0 || o.BlockSize < 0 || o.Rate < 0 {
+		return fmt.Errorf("size, block size and/or rate are negative (size: %d, block size: %d, rate: %d)", o.Size, o.BlockSize, o.Rate)
+	}
+	if o.Direct {
+		if !directSupported {
+			return errors.New("direct I/O is not supported on this platform")
+		}
+		if o.BlockSize%directAlignment != 0 {
+			return fmt.Errorf("direct I/O requires the block size to be a multiple of %d (block size: %d)", directAlignment, o.BlockSize)
+		}
+	}
+	return nil
+}
+
+// throttle sleeps long enough for the throughput to not go above the rate
+func throttle(ctx context.Context, start time.Time, bytes int64, rate int64) {
+	if rate <= 0 {
+		return
+	}
+	target := time.Duration(float64(bytes) / float64(rate) * float64(time.Second))
+	wait := target - time.Since(start)
+	if wait <= 0 {
+		return
+	}
+	select {
+	case <-time.After(wait):
+	case <-ctx.Done():
+	}
+}
+
+func createWriteFile(opts Options) (*os.File, error) {
+	file, err := os.CreateTemp(opts.Dir, "kaller-write-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch file: %w", err)
+	}
+	// the file is unlinked right away, its space being freed once closed
+	os.Remove(file.Name())
+	if !opts.Direct {
+		return file, nil
+	}
+	// direct I/O needs its flag set at open time, so the file is reopened through its
+	// descriptor, which still works after it is unlinked
+	defer file.Close()
+	direct, err := os.OpenFile(fmt.Sprintf("/proc/self/fd/%d", file.Fd()), os.O_RDWR|directFlag, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scratch file for direct I/O: %w", err)
+	}
+	return direct, nil
+}
+
+// readFiles serializes the creation of read files
+var readFiles sync.Mutex
+
+func openReadFile(opts Options) (*os.File, error) {
+	size := opts.Size
+	if opts.Direct {
+		// direct reads always read whole blocks
+		size = (size + int64(opts.BlockSize) - 1) / int64(opts.BlockSize) * int64(opts.BlockSize)
+	}
+	path := filepath.Join(opts.Dir, "kaller-read")
+
+	readFiles.Lock()
+	if info, err := os.Stat(path); err != nil || info.Size() < size {
+		if err := createReadFile(path, size); err != nil {
+			readFiles.Unlock()
+			return nil, fmt.Errorf("failed to create scratch file: %w", err)
+		}
+	}
+	readFiles.Unlock()
+
+	flag := os.O_RDONLY
+	if opts.Direct {
+		flag |= directFlag
+	}
+	file, err := os.OpenFile(path, flag, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open scratch file: %w", err)
+	}
+	return file, nil
+}
+
+// createReadFile writes a file filled with random data, atomically replacing any existing one.
+// Reads still running keep reading the file they opened
+func createReadFile(path string, size int64) error {
+	file, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	rnd := rand.New(rand.NewSource(1))
+	buf := make([]byte, 1024*1024)
+	for written := int64(0); written < size; {
+		chunk := buf
+		if remaining := size - written; remaining < int64(len(chunk)) {
+			chunk = chunk[:remaining]
+		}
+		rnd.Read(chunk)
+		n, err := file.Write(chunk)
+		written += int64(n)
+		if err != nil {
+			return err
+		}
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+// alignedBuffer returns a buffer of the given size whose address is aligned for direct I/O
+func alignedBuffer(size int) []byte {
+	buf := make([]byte, size+directAlignment)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % directAlignment); rem != 0 {
+		offset = directAlignment - rem
+	}
+	return buf[offset : offset+size : offset+size]
+}
@@ -0,0 +1,90 @@
+package disk
+
+import (
+	"context"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunWrite(t *testing.T) {
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+	result, err := Run(context.Background(), Options{Op: Write, Size: 1000 * 1000, BlockSize: 64 * 1024, Fsync: FsyncEnd, Dir: dir}, rnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000*1000), result.Bytes)
+	assert.Equal(t, 16, result.Blocks)
+
+	result, err = Run(context.Background(), Options{Op: Write, Size: 64 * 1024, Pattern: Random, Fsync: FsyncAlways, Dir: dir}, rnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(64*1024), result.Bytes)
+	assert.Equal(t, 16, result.Blocks)
+
+	// written files are removed once done
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestRunRead(t *testing.T) {
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 2; i++ {
+		result, err := Run(context.Background(), Options{Op: Read, Size: 100 * 1024, Pattern: Random, Dir: dir}, rnd)
+		require.NoError(t, err)
+		assert.Equal(t, 25, result.Blocks)
+		assert.Greater(t, result.Bytes, int64(0))
+	}
+
+	// the read file is kept and reused by reads of all sizes, growing to the largest one
+	for _, size := range []int64{10 * 1024, 200 * 1024, 50 * 1024} {
+		result, err := Run(context.Background(), Options{Op: Read, Size: size, Dir: dir}, rnd)
+		require.NoError(t, err)
+		assert.Equal(t, size, result.Bytes)
+	}
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(entries))
+	info, err := os.Stat(filepath.Join(dir, entries[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(200*1024), info.Size())
+}
+
+func TestRunRate(t *testing.T) {
+	start := time.Now()
+	result, err := Run(context.Background(), Options{Op: Write, Size: 1024 * 1024, BlockSize: 64 * 1024, Rate: 10 * 1024 * 1024, Dir: t.TempDir()}, rand.New(rand.NewSource(1)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1024*1024), result.Bytes)
+	assert.Greater(t, time.Since(start), 95*time.Millisecond)
+	assert.Less(t, time.Since(start), 200*time.Millisecond)
+}
+
+func TestRunDirect(t *testing.T) {
+	if !directSupported {
+		t.Skip("direct I/O is not supported on this platform")
+	}
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+	_, err := Run(context.Background(), Options{Op: Write, Size: 64 * 1024, Direct: true, Dir: dir}, rnd)
+	if err != nil {
+		// some file systems, like tmpfs, do not support direct I/O
+		t.Skipf("direct I/O not available in %s: %v", dir, err)
+	}
+	result, err := Run(context.Background(), Options{Op: Read, Size: 10000, BlockSize: 8192, Direct: true, Dir: dir}, rnd)
+	require.NoError(t, err)
+	assert.Equal(t, int64(16384), result.Bytes)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Options{Op: Read}.Validate())
+	assert.Error(t, Options{}.Validate())
+	assert.Error(t, Options{Op: Write, Pattern: "zigzag"}.Validate())
+	assert.Error(t, Options{Op: Write, Fsync: "sometimes"}.Validate())
+	assert.Error(t, Options{Op: Write, Size: -1}.Validate())
+	assert.Error(t, Options{Op: Write, BlockSize: 1000, Direct: true}.Validate())
+}
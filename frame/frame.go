@@ -0,0 +1,116 @@
+// Package frame implements kaller framing, used to carry kaller calls over raw TCP connections
+// and UDP datagrams
+//
+// A message is made of 2 frames: a header frame followed by a payload frame. Each frame is a
+// 4 byte big endian length followed by that many bytes of content. The header frame has a start
+// line (the path for requests, the status code for responses) followed by MIME style headers,
+// the same way HTTP/1 messages do:
+//
+//	/some/path\r\n
+//	X-Kaller-Plan: ...\r\n
+//	X-Kaller-Loc: 0.1\r\n
+//	\r\n
+//
+// Over TCP, a connection carries a sequence of request and response messages. Over UDP, each
+// datagram carries a single message
+package frame
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+// MaxFrameSize is the largest frame accepted when reading messages
+const MaxFrameSize = 64 * 1024 * 1024
+
+// MaxDatagramSize is the largest message that can be sent in a single UDP datagram
+const MaxDatagramSize = 65507
+
+var ErrFrameTooLarge = errors.New("frame too large")
+
+type Message struct {
+	Start   string
+	Header  http.Header
+	Payload []byte
+}
+
+// Encode returns the message in its wire format
+func Encode(msg Message) []byte {
+	header := bytes.Buffer{}
+	header.WriteString(msg.Start)
+	header.WriteString("\r\n")
+	msg.Header.Write(&header)
+	header.WriteString("\r\n")
+
+	buf := make([]byte, 0, 8+header.Len()+len(msg.Payload))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(header.Len()))
+	buf = append(buf, header.Bytes()...)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(len(msg.Payload)))
+	buf = append(buf, msg.Payload...)
+	return buf
+}
+
+// Decode parses a message in its wire format, like the content of a UDP datagram
+func Decode(data []byte) (Message, error) {
+	return Read(bytes.NewReader(data))
+}
+
+// Write writes the message to the writer
+func Write(w io.Writer, msg Message) error {
+	_, err := w.Write(Encode(msg))
+	return err
+}
+
+// Read reads the next message from the reader. Returns io.EOF if the reader has no more
+// messages
+func Read(r io.Reader) (Message, error) {
+	header, err := readFrame(r)
+	if err != nil {
+		return Message{}, err
+	}
+	payload, err := readFrame(r)
+	if err != nil {
+		return Message{}, unexpectedEOF(err)
+	}
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(header)))
+	start, err := reader.ReadLine()
+	if err != nil {
+		return Message{}, fmt.Errorf("bad header frame: %w", unexpectedEOF(err))
+	}
+	mimeHeader, err := reader.ReadMIMEHeader()
+	if err != nil {
+		return Message{}, fmt.Errorf("bad header frame: %w", unexpectedEOF(err))
+	}
+	return Message{Start: strings.TrimSpace(start), Header: http.Header(mimeHeader), Payload: payload}, nil
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(length[:])
+	if size > MaxFrameSize {
+		return nil, fmt.Errorf("%w: %d bytes", ErrFrameTooLarge, size)
+	}
+	content := make([]byte, size)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	return content, nil
+}
+
+// unexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, for when the message was cut short
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,49 @@
+package frame
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadWrite(t *testing.T) {
+	buf := bytes.Buffer{}
+	request := Message{
+		Start:   "/some/path",
+		Header:  http.Header{"X-Kaller-Loc": []string{"0.1"}},
+		Payload: []byte("hello"),
+	}
+	response := Message{Start: "200", Header: http.Header{}}
+	require.NoError(t, Write(&buf, request))
+	require.NoError(t, Write(&buf, response))
+
+	read, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, request, read)
+
+	read, err = Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, "200", read.Start)
+	assert.Empty(t, read.Header)
+	assert.Empty(t, read.Payload)
+
+	_, err = Read(&buf)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecode(t *testing.T) {
+	encoded := Encode(Message{Start: "/", Header: http.Header{}, Payload: []byte("hello")})
+	decoded, err := Decode(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("hello"), decoded.Payload)
+
+	_, err = Decode(encoded[:len(encoded)-1])
+	assert.Equal(t, io.ErrUnexpectedEOF, err)
+
+	_, err = Decode([]byte{0xff, 0xff, 0xff, 0xff})
+	assert.ErrorIs(t, err, ErrFrameTooLarge)
+}
@@ -0,0 +1,239 @@
+package generate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// Params controls the shape of the generated plans. Ranges are inclusive and values are
+// picked uniformly within them, unless a distribution is given for the value. In that case
+// values are picked from the distribution and the range only bounds them
+//
+// Values are always picked when the plan is generated, be it from ranges or distributions:
+// they shape how the calls of the plan differ from each other. The generated plan itself only
+// holds the picked values
+type Params struct {
+	// Seed for the random number generator. The same params with the same seed always
+	// generate the same plan. The seed is also set as the seed of the generated plan, so its
+	// runs are reproducible too. A zero seed makes the plan pick a random seed on each run
+	Seed int64
+
+	// Services is the amount of distinct services in the mesh. Services are named after
+	// HostFormat, eg: svc1, svc2, ...
+	Services   int
+	HostFormat string
+
+	// Depth is the maximum amount of hops from the entrypoint service
+	Depth int
+	// FanOutMin and FanOutMax control how many calls each service makes to other services
+	FanOutMin int
+	FanOutMax int
+	// FanOut optionally picks how many calls each service makes, eg: "pareto(1, 1.5)"
+	FanOut *ptype.Distribution
+
+	// ParallelProbability is the chance that a service calls its dependencies in parallel
+	ParallelProbability float64
+	// AsyncProbability is the chance of each call being async
+	AsyncProbability float64
+
+	// ComputeMin and ComputeMax bound the compute ranges of each call
+	ComputeMin time.Duration
+	ComputeMax time.Duration
+	// Compute optionally picks the compute ranges of each call, eg: "lognormal(20ms, 0.5)".
+	// Two values are picked for each call, the lower being the min and the higher the max
+	Compute *ptype.Distribution
+	// CPUMin and CPUMax bound the cpu load of each call compute
+	CPUMin float64
+	CPUMax float64
+
+	// BodySizeMin and BodySizeMax bound the generated response body sizes
+	BodySizeMin int
+	BodySizeMax int
+	// BodySize optionally picks the generated response body sizes, eg: "lognormal(2kb, 1)"
+	BodySize *ptype.Distribution
+}
+
+func DefaultParams() Params {
+	return Params{
+		Seed:                time.Now().UnixNano(),
+		Services:            5,
+		HostFormat:          "svc%d",
+		Depth:               3,
+		FanOutMin:           1,
+		FanOutMax:           3,
+		ParallelProbability: 0.3,
+		AsyncProbability:    0.1,
+		ComputeMin:          5 * time.Millisecond,
+		ComputeMax:          50 * time.Millisecond,
+		CPUMin:              0.0,
+		CPUMax:              0.5,
+		BodySizeMin:         100,
+		BodySizeMax:         10 * 1024,
+	}
+}
+
+func (p Params) Validate() error {
+	if p.Services < 1 {
+		return fmt.Errorf("invalid params: at least 1 service is required (services: %d)", p.Services)
+	}
+	if p.Depth < 1 {
+		return fmt.Errorf("invalid params: depth must be at least 1 (depth: %d)", p.Depth)
+	}
+	if p.FanOutMin < 0 || p.FanOutMin > p.FanOutMax {
+		return fmt.Errorf("invalid params: bad fan-out range (min: %d, max: %d)", p.FanOutMin, p.FanOutMax)
+	}
+	if p.ComputeMin < 0 || p.ComputeMin > p.ComputeMax {
+		return fmt.Errorf("invalid params: bad compute range (min: %v, max: %v)", p.ComputeMin, p.ComputeMax)
+	}
+	if p.CPUMin < 0 || p.CPUMin > p.CPUMax {
+		return fmt.Errorf("invalid params: bad cpu range (min: %v, max: %v)", p.CPUMin, p.CPUMax)
+	}
+	if p.BodySizeMin < 0 || p.BodySizeMin > p.BodySizeMax {
+		return fmt.Errorf("invalid params: bad body size range (min: %d, max: %d)", p.BodySizeMin, p.BodySizeMax)
+	}
+	distributions := []struct {
+		name         string
+		distribution *ptype.Distribution
+	}{
+		{"fan-out", p.FanOut},
+		{"compute", p.Compute},
+		{"body size", p.BodySize},
+	}
+	for _, d := range distributions {
+		if d.distribution == nil {
+			continue
+		}
+		if err := d.distribution.Validate(); err != nil {
+			return fmt.Errorf("invalid params: bad %s distribution: %w", d.name, err)
+		}
+	}
+	return nil
+}
+
+// Plan generates a random plan with a single entrypoint call. The entrypoint calls
+// other services, which in turn call other services, up to the configured depth.
+// A service never calls itself directly
+func Plan(params Params) (ptype.Plan, error) {
+	if err := params.Validate(); err != nil {
+		return ptype.Plan{}, err
+	}
+	if params.HostFormat == "" {
+		params.HostFormat = "svc%d"
+	}
+	g := generator{params: params, rand: rand.New(rand.NewSource(params.Seed))}
+	entry := g.call(1, 1)
+	return ptype.Plan{Seed: params.Seed, Execution: ptype.Execution{entry}}, nil
+}
+
+type generator struct {
+	params   Params
+	rand     *rand.Rand
+	endpoint int
+}
+
+func (g *generator) call(service int, depth int) *ptype.Call {
+	g.endpoint++
+	call := &ptype.Call{
+		HTTP: ptype.HTTP{
+			Method:          "GET",
+			URL:             ptype.MustParseURL(fmt.Sprintf("http://"+g.params.HostFormat+"/endpoint%d", service, g.endpoint)),
+			StatusCode:      200,
+			GenResponseBody: g.pick(g.params.BodySize, g.params.BodySizeMin, g.params.BodySizeMax),
+		},
+		Compute: g.compute(),
+	}
+	if depth >= g.params.Depth || g.params.Services < 2 {
+		return call
+	}
+
+	fanOut := g.pick(g.params.FanOut, g.params.FanOutMin, g.params.FanOutMax)
+	calls := make(ptype.Execution, fanOut)
+	for idx := range calls {
+		target := g.intn(1, g.params.Services-1)
+		if target >= service {
+			target++
+		}
+		child := g.call(target, depth+1)
+		child.Async = g.rand.Float64() < g.params.AsyncProbability
+		calls[idx] = child
+	}
+	if fanOut > 1 && g.rand.Float64() < g.params.ParallelProbability {
+		call.Execution = ptype.Execution{&ptype.Parallel{Concurrency: fanOut, Execution: calls}}
+	} else if fanOut > 0 {
+		call.Execution = calls
+	}
+	return call
+}
+
+func (g *generator) compute() ptype.Compute {
+	if g.params.Compute != nil {
+		min, max := g.sampleDuration(), g.sampleDuration()
+		if max < min {
+			min, max = max, min
+		}
+		return ptype.Compute{
+			Min: min,
+			Max: max,
+			CPU: g.cpu(),
+		}
+	}
+	min := g.duration(g.params.ComputeMin, g.params.ComputeMax)
+	max := g.duration(min, g.params.ComputeMax)
+	return ptype.Compute{
+		Min: min,
+		Max: max,
+		CPU: g.cpu(),
+	}
+}
+
+func (g *generator) cpu() float64 {
+	cpu := g.params.CPUMin + g.rand.Float64()*(g.params.CPUMax-g.params.CPUMin)
+	// cpu is rounded to a single decimal place to keep the generated plan readable
+	return float64(int(cpu*10)) / 10
+}
+
+// duration picks a random duration in the range, rounded to milliseconds
+func (g *generator) duration(min time.Duration, max time.Duration) time.Duration {
+	ms := g.intn(int(min.Milliseconds()), int(max.Milliseconds()))
+	return time.Duration(ms) * time.Millisecond
+}
+
+// sampleDuration picks a duration from the compute distribution, bounded by the compute range
+// and rounded to milliseconds
+func (g *generator) sampleDuration() time.Duration {
+	duration := time.Duration(g.params.Compute.Sample(g.rand)).Round(time.Millisecond)
+	if duration < g.params.ComputeMin {
+		return g.params.ComputeMin
+	}
+	if duration > g.params.ComputeMax {
+		return g.params.ComputeMax
+	}
+	return duration
+}
+
+// pick picks a value from the distribution, bounded by the range, or uniformly within the
+// range if there is no distribution
+func (g *generator) pick(distribution *ptype.Distribution, min int, max int) int {
+	if distribution == nil {
+		return g.intn(min, max)
+	}
+	value := int(math.Round(distribution.Sample(g.rand)))
+	if value < min {
+		return min
+	}
+	if value > max {
+		return max
+	}
+	return value
+}
+
+func (g *generator) intn(min int, max int) int {
+	if max <= min {
+		return min
+	}
+	return min + g.rand.Intn(max-min+1)
+}
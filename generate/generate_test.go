@@ -0,0 +1,151 @@
+package generate
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+func TestPlanIsReproducible(t *testing.T) {
+	params := DefaultParams()
+	params.Seed = 42
+
+	plan1, err := Plan(params)
+	require.NoError(t, err)
+	plan2, err := Plan(params)
+	require.NoError(t, err)
+
+	yaml1, err := plan1.ToYAML()
+	require.NoError(t, err)
+	yaml2, err := plan2.ToYAML()
+	require.NoError(t, err)
+	assert.Equal(t, string(yaml1), string(yaml2))
+
+	decoded, err := ptype.FromYAML(yaml1)
+	require.NoError(t, err)
+	assert.Equal(t, plan1, decoded)
+	assert.Equal(t, int64(42), decoded.Seed)
+}
+
+func TestPlanRespectsParams(t *testing.T) {
+	params := DefaultParams()
+	params.Services = 4
+	params.Depth = 4
+	params.FanOutMin = 2
+	params.FanOutMax = 2
+
+	for seed := int64(0); seed < 20; seed++ {
+		params.Seed = seed
+		plan, err := Plan(params)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(plan.Execution))
+
+		var check func(call *ptype.Call, depth int)
+		check = func(call *ptype.Call, depth int) {
+			assert.LessOrEqual(t, depth, params.Depth)
+			assert.Contains(t, []string{"svc1", "svc2", "svc3", "svc4"}, call.Host())
+			assert.GreaterOrEqual(t, call.Compute.Min, params.ComputeMin)
+			assert.LessOrEqual(t, call.Compute.Max, params.ComputeMax)
+			assert.LessOrEqual(t, call.Compute.Min, call.Compute.Max)
+
+			children := call.Execution
+			if len(children) == 1 {
+				if parallel, ok := children[0].(*ptype.Parallel); ok {
+					children = parallel.Execution
+				}
+			}
+			if depth < params.Depth {
+				assert.Equal(t, 2, len(children))
+			} else {
+				assert.Equal(t, 0, len(children))
+			}
+			for _, step := range children {
+				child := step.(*ptype.Call)
+				assert.NotEqual(t, call.Host(), child.Host())
+				check(child, depth+1)
+			}
+		}
+		check(plan.Execution[0].(*ptype.Call), 1)
+	}
+}
+
+func TestPlanDistributions(t *testing.T) {
+	params := DefaultParams()
+	params.Services = 4
+	params.Depth = 4
+	params.FanOutMax = 10
+	params.BodySizeMax = 1024 * 1024
+	var err error
+	params.FanOut, err = ptype.ParseDistribution("weighted(1: 50, 4: 50)")
+	require.NoError(t, err)
+	params.Compute, err = ptype.ParseDistribution("lognormal(20ms, 0.5)")
+	require.NoError(t, err)
+	params.BodySize, err = ptype.ParseDistribution("pareto(1kb, 1.2)")
+	require.NoError(t, err)
+
+	fanOuts := map[int]int{}
+	for seed := int64(0); seed < 20; seed++ {
+		params.Seed = seed
+		plan, err := Plan(params)
+		require.NoError(t, err)
+
+		var check func(call *ptype.Call)
+		check = func(call *ptype.Call) {
+			assert.Nil(t, call.Compute.Distribution)
+			assert.GreaterOrEqual(t, call.Compute.Min, params.ComputeMin)
+			assert.LessOrEqual(t, call.Compute.Max, params.ComputeMax)
+			assert.LessOrEqual(t, call.Compute.Min, call.Compute.Max)
+			assert.Equal(t, time.Duration(0), call.Compute.Min%time.Millisecond)
+			assert.GreaterOrEqual(t, call.HTTP.GenResponseBody, 1024)
+			assert.LessOrEqual(t, call.HTTP.GenResponseBody, params.BodySizeMax)
+
+			children := call.Execution
+			if len(children) == 1 {
+				if parallel, ok := children[0].(*ptype.Parallel); ok {
+					children = parallel.Execution
+				}
+			}
+			if len(children) > 0 {
+				fanOuts[len(children)]++
+			}
+			for _, step := range children {
+				check(step.(*ptype.Call))
+			}
+		}
+		check(plan.Execution[0].(*ptype.Call))
+
+		yaml, err := plan.ToYAML()
+		require.NoError(t, err)
+		decoded, err := ptype.FromYAML(yaml)
+		require.NoError(t, err)
+		assert.Equal(t, plan, decoded)
+	}
+	assert.Equal(t, []int{1, 4}, keys(fanOuts))
+}
+
+func keys(m map[int]int) []int {
+	result := []int{}
+	for key := range m {
+		result = append(result, key)
+	}
+	sort.Ints(result)
+	return result
+}
+
+func TestPlanValidatesParams(t *testing.T) {
+	params := DefaultParams()
+	params.FanOutMin = 3
+	params.FanOutMax = 1
+	_, err := Plan(params)
+	assert.Error(t, err)
+
+	params = DefaultParams()
+	params.BodySize = &ptype.Distribution{Kind: ptype.DistributionUniform, Min: ptype.Bytes(10), Max: ptype.Bytes(1)}
+	_, err = Plan(params)
+	assert.Error(t, err)
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+
+	"github.com/bcap/kaller/certs"
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// httpClients holds the HTTP clients used by http and sse calls, one per protocol and TLS
+// setting. Clients are shared by all calls so connections are reused across calls, which is
+// what makes HTTP/2 multiplexing, as opposed to HTTP/1.1 connection pooling, observable
+type httpClients struct {
+	mutex sync.Mutex
+	byKey map[clientKey]*http.Client
+}
+
+type clientKey struct {
+	protocol ptype.Protocol
+	tls      ptype.TLS
+}
+
+func (c *httpClients) get(protocol ptype.Protocol, settings *ptype.TLS) (*http.Client, error) {
+	key := clientKey{protocol: protocol}
+	if settings != nil {
+		key.tls = *settings
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.byKey == nil {
+		c.byKey = map[clientKey]*http.Client{}
+	}
+	client, ok := c.byKey[key]
+	if !ok {
+		config, err := tlsConfig(settings)
+		if err != nil {
+			return nil, err
+		}
+		client = newHTTPClient(protocol, config)
+		c.byKey[key] = client
+	}
+	return client, nil
+}
+
+func newHTTPClient(protocol ptype.Protocol, config *tls.Config) *http.Client {
+	switch protocol {
+	case ptype.ProtocolHTTP1:
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		transport.ForceAttemptHTTP2 = false
+		// a non-nil empty map disables HTTP/2 negotiation over TLS
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		return &http.Client{Transport: transport}
+	case ptype.ProtocolH2C:
+		transport := &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network string, addr string, _ *tls.Config) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, addr)
+			},
+		}
+		return &http.Client{Transport: transport}
+	case ptype.ProtocolH2:
+		return &http.Client{Transport: &http2.Transport{TLSClientConfig: config}}
+	default:
+		if config == nil {
+			return &http.Client{}
+		}
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = config
+		return &http.Client{Transport: transport}
+	}
+}
+
+// tlsConfig creates the client TLS configuration of calls. Returns nil if there are no TLS
+// settings, so the defaults are used
+func tlsConfig(settings *ptype.TLS) (*tls.Config, error) {
+	if settings == nil {
+		return nil, nil
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		ServerName:         settings.ServerName,
+		InsecureSkipVerify: settings.SkipVerify,
+	}
+	if settings.CA != "" {
+		pool, err := certs.LoadPool(settings.CA)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if settings.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(settings.Cert, settings.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
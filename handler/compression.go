@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// compress compresses the body with the given encoding and level. Level 0 means the
+// encoding default level
+func compress(encoding string, level int, body []byte) ([]byte, error) {
+	buf := bytes.Buffer{}
+	var writer io.WriteCloser
+	switch encoding {
+	case ptype.EncodingGzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gzipWriter, err := gzip.NewWriterLevel(&buf, level)
+		if err != nil {
+			return nil, err
+		}
+		writer = gzipWriter
+	case ptype.EncodingBrotli:
+		if level == 0 {
+			level = brotli.DefaultCompression
+		}
+		writer = brotli.NewWriterLevel(&buf, level)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress decompresses a body that was encoded with the given Content-Encoding
+func decompress(encoding string, body []byte) ([]byte, error) {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case ptype.EncodingGzip:
+		gzipReader, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		reader = gzipReader
+	case ptype.EncodingBrotli:
+		reader = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+	return io.ReadAll(reader)
+}
+
+func checksum(body []byte) string {
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE(body)), 16)
+}
+
+// compressResponse compresses the body according to the call compression settings and the
+// request Accept-Encoding header, setting the related response headers. Returns the body
+// unchanged and an empty encoding if no compression should be done
+func (h *handler) compressResponse(compression *ptype.Compression, body []byte) ([]byte, string, error) {
+	if compression == nil {
+		return body, "", nil
+	}
+	header := h.Response.Header()
+	header.Add("Vary", "Accept-Encoding")
+	encoding := compression.Negotiate(h.Request.Header.Get("Accept-Encoding"))
+	if encoding == "" || len(body) == 0 {
+		return body, "", nil
+	}
+	compressed, err := compress(encoding, compression.Level, body)
+	if err != nil {
+		return nil, "", err
+	}
+	header.Set("Content-Encoding", encoding)
+	header.Set(HeaderBodyChecksum, checksum(body))
+	return compressed, encoding, nil
+}
+
+// readResponse reads the whole response body, decompressing and verifying it when it was
+// compressed
+func readResponse(resp *http.Response) ([]byte, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	encoding := resp.Header.Get("Content-Encoding")
+	if encoding == "" {
+		return body, nil
+	}
+	body, err = decompress(encoding, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s response body: %w", encoding, err)
+	}
+	if expected := resp.Header.Get(HeaderBodyChecksum); expected != "" && expected != checksum(body) {
+		return nil, fmt.Errorf("%s response body checksum mismatch: expected %s, got %s", encoding, expected, checksum(body))
+	}
+	return body, nil
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	ptype "github.com/bcap/kaller/plan"
+	"golang.org/x/sync/semaphore"
+)
+
+// resources holds the shared resources contend steps compete for. Resources are kept for the
+// whole process lifetime
+type resources struct {
+	mutex sync.Mutex
+	byKey map[string]*semaphore.Weighted
+}
+
+func (r *resources) get(contend ptype.Contend) *semaphore.Weighted {
+	key := fmt.Sprintf("%s/%s/%d", contend.ResourceKind(), contend.Resource, contend.ResourcePermits())
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.byKey == nil {
+		r.byKey = map[string]*semaphore.Weighted{}
+	}
+	resource, ok := r.byKey[key]
+	if !ok {
+		resource = semaphore.NewWeighted(int64(contend.ResourcePermits()))
+		r.byKey[key] = resource
+	}
+	return resource
+}
+
+func (h *handler) contend(contend ptype.Contend, location string, iteration string) error {
+	if err := contend.Validate(); err != nil {
+		return err
+	}
+	resource := h.resources.get(contend)
+
+	ctx := h.Context
+	timeout := contend.AcquireTimeout()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	if err := resource.Acquire(ctx, 1); err != nil {
+		if h.Context.Err() != nil {
+			return h.Context.Err()
+		}
+		h.logContend(location, contend, "timed out", time.Since(start))
+		return fmt.Errorf("timed out after %v acquiring %s", timeout, contend.String())
+	}
+	defer resource.Release(1)
+	h.logContend(location, contend, "acquired", time.Since(start))
+
+	return h.processSteps(1, 0, contend.Execution, location, iteration)
+}
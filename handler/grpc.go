@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/bcap/kaller/rpc"
+)
+
+// grpcConns holds the gRPC client connections used by grpc calls, one per address. gRPC
+// connections multiplex calls, so they are shared by all calls and kept for the whole process
+// lifetime
+type grpcConns struct {
+	mutex     sync.Mutex
+	byAddress map[string]*grpc.ClientConn
+}
+
+func (c *grpcConns) get(address string) (*grpc.ClientConn, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.byAddress == nil {
+		c.byAddress = map[string]*grpc.ClientConn{}
+	}
+	conn, ok := c.byAddress[address]
+	if !ok {
+		var err error
+		conn, err = grpc.NewClient(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			return nil, err
+		}
+		c.byAddress[address] = conn
+	}
+	return conn, nil
+}
+
+// roundTripGRPC sends the request to the Kaller gRPC service (see the rpc package), carrying
+// the request headers as metadata. Calls that fail with a non OK gRPC code are still a response
+// as long as the kaller status code was sent back, otherwise the call failed before reaching
+// the other kaller (eg: connection failures) and the error is returned
+func (h *handler) roundTripGRPC(ctx context.Context, address string, req *http.Request, body []byte) (*http.Response, error) {
+	conn, err := h.grpcConns.get(address)
+	if err != nil {
+		return nil, err
+	}
+	md := metadata.MD{}
+	for key, values := range req.Header {
+		md.Append(strings.ToLower(key), values...)
+	}
+	md.Set(rpc.PathMetadata, req.URL.RequestURI())
+	ctx = metadata.NewOutgoingContext(ctx, md)
+
+	response := &wrapperspb.BytesValue{}
+	var header metadata.MD
+	err = conn.Invoke(ctx, rpc.FullMethod, &wrapperspb.BytesValue{Value: body}, response, grpc.Header(&header))
+	statusCode, convErr := strconv.Atoi(firstValue(header, rpc.StatusMetadata))
+	if err != nil && convErr != nil {
+		return nil, err
+	}
+	if convErr != nil {
+		statusCode = http.StatusOK
+	}
+	respHeader := http.Header{}
+	for key, values := range header {
+		if key == rpc.StatusMetadata {
+			continue
+		}
+		for _, value := range values {
+			respHeader.Add(key, value)
+		}
+	}
+	return &http.Response{
+		StatusCode:    statusCode,
+		Header:        respHeader,
+		Body:          io.NopCloser(bytes.NewReader(response.Value)),
+		ContentLength: int64(len(response.Value)),
+	}, nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
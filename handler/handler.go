@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"math/rand"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,9 +22,34 @@ import (
 type Handler struct {
 	BaseContext context.Context
 
+	// ServiceName identifies which service of the plan this handler is playing. It is used
+	// to pick the plan service overrides and is reported in logs, responses and request traces
+	ServiceName string
+
+	// ScratchDir is where io steps create their files, which they can only do in it or in its
+	// subdirectories. Defaults to the system temporary directory
+	ScratchDir string
+
 	requestsHandled     int64
 	requestsOutstanding int32
 
+	// shared resources contend steps compete for
+	resources resources
+
+	// clients used by http and sse calls, and client connections used by grpc calls
+	httpClients httpClients
+	grpcConns   grpcConns
+
+	// cpu time requested by and actually given to computes, in nanoseconds
+	cpuRequested  int64
+	cpuActual     int64
+	cpuUnmeasured int32
+
+	// processFill and processMmapFill hold the memory of computes with process memory scope,
+	// which is kept across requests
+	processFill     memory.Fill
+	processMmapFill memory.Fill
+
 	// access log capturing is for unit testing only
 	testCaptureAccessLog bool
 	testAccessLog        []string
@@ -32,7 +58,8 @@ type Handler struct {
 
 func New(ctx context.Context) *Handler {
 	return &Handler{
-		BaseContext: ctx,
+		BaseContext:     ctx,
+		processMmapFill: memory.Fill{Backing: memory.Mmap},
 	}
 }
 
@@ -48,16 +75,30 @@ type handler struct {
 	Response           http.ResponseWriter
 	ResponseStatusCode int
 	ResponseBody       []byte
+	// ResponseEncoding and ResponseEncodedSize describe the response body compression, if any
+	ResponseEncoding    string
+	ResponseEncodedSize int
 
 	// Plan and its encoded (serialized) version.
 	// We keep the encoded plan in memory as well to avoid re-encoding the plan everytime a call is made
 	Plan        ptype.Plan
 	EncodedPlan *EncodedPlan
 
+	// Override holds the plan service overrides for this handler service name, if any
+	Override ptype.ServiceOverride
+
+	// Seed is the plan seed in effect for this run and Iteration is the list of loop
+	// iterations that led to this call. Together with the call location they define all
+	// random decisions taken while handling the call (see ptype.Plan.Seed)
+	Seed      int64
+	Iteration string
+
 	RequestedAt time.Time
 	RespondedAt time.Time
 
-	Fill memory.Fill
+	// Fill and MmapFill hold the memory of computes with request memory scope
+	Fill     memory.Fill
+	MmapFill memory.Fill
 
 	pendingAsyncCalls syncx.WaitGroup
 }
@@ -73,9 +114,12 @@ func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		Handler:  h,
 		Request:  req,
 		Response: resp,
+		MmapFill: memory.Fill{Backing: memory.Mmap},
 	}
 	atomic.AddInt32(&h.requestsOutstanding, 1)
 	handler.Handle()
+	// memory mappings are not garbage collected and need to be released explicitly
+	handler.MmapFill.Set(0)
 	atomic.AddInt64(&h.requestsHandled, 1)
 	atomic.AddInt32(&h.requestsOutstanding, -1)
 }
@@ -88,6 +132,20 @@ func (h *Handler) Handled() int64 {
 	return atomic.LoadInt64(&h.requestsHandled)
 }
 
+// CPUUsage returns the total cpu time requested by and actually given to all computes so far
+func (h *Handler) CPUUsage() ptype.CPUUsage {
+	return ptype.CPUUsage{
+		Requested: time.Duration(atomic.LoadInt64(&h.cpuRequested)),
+		Actual:    time.Duration(atomic.LoadInt64(&h.cpuActual)),
+		Measured:  atomic.LoadInt32(&h.cpuUnmeasured) == 0,
+	}
+}
+
+// ProcessMemory returns how many bytes are held by computes with process memory scope
+func (h *Handler) ProcessMemory() int {
+	return h.processFill.Size() + h.processMmapFill.Size()
+}
+
 func (h *handler) Handle() {
 	h.RequestedAt = time.Now()
 
@@ -102,6 +160,9 @@ func (h *handler) Handle() {
 	}
 	h.RequestBody = reqBodyBytes
 	h.identifyRequest()
+	if h.ServiceName != "" {
+		h.Response.Header().Set(HeaderService, h.ServiceName)
+	}
 
 	plan, encodedPlan, location, err := ReadPlanHeaders(h.Request)
 	if err != nil {
@@ -110,6 +171,17 @@ func (h *handler) Handle() {
 	}
 	h.Plan = plan
 	h.EncodedPlan = encodedPlan
+	if h.ServiceName != "" {
+		h.Override = plan.ServiceOverrides[h.ServiceName]
+	}
+	h.Seed, h.Iteration = ReadSeedHeaders(h.Request)
+	if h.Seed == 0 {
+		h.Seed = plan.Seed
+	}
+	if h.Seed == 0 {
+		h.Seed = random.Default.Int63()
+		log.Printf("Using random seed %d", h.Seed)
+	}
 
 	h.logRequestIn(location)
 
@@ -124,25 +196,30 @@ func (h *handler) Handle() {
 		return
 	}
 
-	h.compute(call.Compute)
-
-	defer h.waitAsyncCalls()
+	h.delay(h.Override.ExtraLatency)
+	h.compute(call.Compute, location, "")
 
-	err = h.processSteps(1, 0, call.Execution, location)
-	if err != nil {
-		h.textResponse(500, "execution failure: %v", err)
+	if h.Override.ErrorRate > 0 && h.random(random.PurposeError, location, "").Float64() < h.Override.ErrorRate {
+		statusCode := h.Override.ErrorStatusCode
+		if statusCode == 0 {
+			statusCode = 500
+		}
+		h.textResponse(statusCode, "injected error for service %s", h.ServiceName)
+		h.ResponseStatusCode = statusCode
+		h.logResponseOut(location)
+		return
 	}
 
-	statusCode, respBodyBytes, err := h.respond(call)
-	if err != nil {
-		h.logResponseWriteErr(location, err)
+	defer h.waitAsyncCalls()
+
+	if call.Stream() != nil {
+		if !h.serveStream(call, location) {
+			return
+		}
+	} else if !h.execute(call, location) {
 		return
 	}
 
-	h.ResponseStatusCode = statusCode
-	h.ResponseBody = respBodyBytes
-	h.logResponseOut(location)
-
 	//
 	// post execution phase (executed after the response was sent)
 	//
@@ -151,7 +228,7 @@ func (h *handler) Handle() {
 		return
 	}
 
-	err = h.processSteps(1, len(call.Execution), call.PostExecution, location)
+	err = h.processSteps(1, len(call.Execution), call.PostExecution, location, "")
 	if err != nil {
 		h.textResponse(500, "execution failure: %v", err)
 	}
@@ -159,21 +236,52 @@ func (h *handler) Handle() {
 	h.logPostResponseOut(location)
 }
 
-func (h *handler) respond(call *ptype.Call) (int, []byte, error) {
-	statusCode := call.HTTP.StatusCode
-	if statusCode == 0 {
-		statusCode = 200
+// execute runs the call Execution and responds the call. Returns whether the call was
+// responded successfully
+func (h *handler) execute(call *ptype.Call, location string) bool {
+	err := h.processSteps(1, 0, call.Execution, location, "")
+	if err != nil {
+		h.textResponse(500, "execution failure: %v", err)
+		h.ResponseStatusCode = 500
+		h.logResponseOut(location)
+		return false
 	}
-	h.Response.WriteHeader(statusCode)
-	var body []byte
-	if call.HTTP.ResponseBody != "" {
-		body = []byte(call.HTTP.ResponseBody)
-	} else if call.HTTP.GenResponseBody > 0 {
-		body = []byte(random.String(call.HTTP.GenResponseBody))
-	} else {
+
+	statusCode, respBodyBytes, err := h.respond(call, location)
+	if err != nil {
+		h.logResponseWriteErr(location, err)
+		return false
+	}
+
+	h.ResponseStatusCode = statusCode
+	h.ResponseBody = respBodyBytes
+	h.logResponseOut(location)
+	return true
+}
+
+func (h *handler) respond(call *ptype.Call, location string) (int, []byte, error) {
+	message := call.Message()
+	statusCode := message.PickStatusCode(h.random(random.PurposeStatus, location, ""))
+	body, contentType := message.GetResponseBody(h.random(random.PurposeResponseBody, location, ""))
+	if body == nil {
 		body = []byte{}
 	}
-	_, err := h.Response.Write(body)
+	if contentType != "" {
+		h.Response.Header().Set("Content-Type", contentType)
+	}
+	for key, value := range message.ResponseHeaders {
+		h.Response.Header().Set(key, value)
+	}
+	encoded, encoding, err := h.compressResponse(message.Compression, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if encoding != "" {
+		h.ResponseEncoding = encoding
+		h.ResponseEncodedSize = len(encoded)
+	}
+	h.Response.WriteHeader(statusCode)
+	_, err = h.Response.Write(encoded)
 	h.RespondedAt = time.Now()
 	return statusCode, body, err
 }
@@ -191,7 +299,10 @@ func (h *handler) textResponse(statusCode int, msg string, args ...any) {
 
 func (h *handler) identifyRequest() {
 	h.RequestID = ReadRequestTraceHeader(h.Request)
-	newID := random.String(3)
+	newID := random.String(random.Default, 3)
+	if h.ServiceName != "" {
+		newID = h.ServiceName + ":" + newID
+	}
 	if h.RequestID == "" {
 		h.RequestID = newID
 	} else {
@@ -199,6 +310,26 @@ func (h *handler) identifyRequest() {
 	}
 }
 
+// random creates a random generator for the given purpose (see random.Derive) of the step at
+// the given location, running in the given loop iteration of this call
+func (h *handler) random(purpose string, location string, iteration string) *rand.Rand {
+	return random.New(random.Derive(h.Seed, purpose, location, joinIterations(h.Iteration, iteration)))
+}
+
+func joinIterations(iterations ...string) string {
+	result := ""
+	for _, iteration := range iterations {
+		if iteration == "" {
+			continue
+		}
+		if result != "" {
+			result += "."
+		}
+		result += iteration
+	}
+	return result
+}
+
 func locateInPlan(plan ptype.Plan, location string) (ptype.Step, error) {
 	var step ptype.Step = &ptype.Call{Execution: plan.Execution}
 	if location == "" {
@@ -221,6 +352,8 @@ func locateInPlan(plan ptype.Plan, location string) (ptype.Step, error) {
 			step = v.Execution[stepIdx]
 		case *ptype.Loop:
 			step = v.Execution[stepIdx]
+		case *ptype.Contend:
+			step = v.Execution[stepIdx]
 		default:
 			return nil, fmt.Errorf("bad location %s: step #%d is of unrecognized type %T", location, idx, v)
 		}
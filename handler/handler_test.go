@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net"
 	"net/http"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -99,6 +103,528 @@ func TestHandlerPlan2(t *testing.T) {
 	assertInLog(t, accessLog, "POST /service3/metrics 1024 -> 200 10240", 1)
 }
 
+var planServiceOverrides = `
+execution:
+- call:
+  http: GET {{addr}}/service1 200 0 100
+  execution:
+  - call:
+    http: GET {{addr2}}/service2 200 0 100
+    compute: 10ms
+service-overrides:
+  back:
+    extra-latency: 200ms
+    error-rate: 1.0
+    error-status-code: 503
+`
+
+func TestHandlerServiceOverrides(t *testing.T) {
+	ctx, cancel, front, addr := launchServer(t)
+	defer cancel()
+	front.ServiceName = "front"
+	_, cancel2, back, addr2 := launchServer(t)
+	defer cancel2()
+	back.ServiceName = "back"
+
+	planStr := strings.ReplaceAll(planServiceOverrides, "{{addr2}}", "http://"+addr2.AddrPort().String())
+	start := time.Now()
+	execPlan(t, ctx, front, addr, planStr)
+	waitRequestsHandled(back)
+	assert.Greater(t, time.Since(start), 200*time.Millisecond)
+
+	assertInLog(t, front.testAccessLog, "[front]", 4)
+	assertInLog(t, front.testAccessLog, "GET /service1 0 -> 200 100", 1)
+	assertInLog(t, back.testAccessLog, "[back]", 2)
+	assertInLog(t, back.testAccessLog, "GET /service2 0 -> 503 0", 1)
+}
+
+var planSeed = `
+seed: 42
+execution:
+- call:
+  http: GET {{addr}}/service1 200 0 64
+  compute: 1ms to 5ms
+`
+
+func TestHandlerSeed(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+
+	seeded := preparePlan(t, planSeed, addr)
+	unseeded := preparePlan(t, planSeed, addr)
+	unseeded.Seed = 0
+
+	get := func(plan ptype.Plan, iteration string) string {
+		request, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr.AddrPort().String(), nil)
+		require.NoError(t, err)
+		require.NoError(t, WritePlanHeaders(request, plan, "0"))
+		if iteration != "" {
+			WriteSeedHeaders(request, plan.Seed, iteration)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		defer response.Body.Close()
+		body, err := io.ReadAll(response.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	assert.Equal(t, 64, len(get(seeded, "")))
+	assert.Equal(t, get(seeded, ""), get(seeded, ""))
+	assert.Equal(t, get(seeded, "3"), get(seeded, "3"))
+	assert.NotEqual(t, get(seeded, ""), get(seeded, "3"))
+	assert.NotEqual(t, get(unseeded, ""), get(unseeded, ""))
+
+	waitRequestsHandled(handler)
+}
+
+var planCompression = `
+execution:
+- call:
+  http:
+    method: GET
+    url: {{addr}}/gzip
+    gen-response-body: 10000
+    gen-response-body-format: text
+    compression: gzip 9
+- call:
+  http:
+    method: GET
+    url: {{addr}}/br
+    gen-response-body: 10000
+    gen-response-body-format: json
+    compression: br
+- call:
+  http:
+    method: GET
+    url: {{addr}}/identity
+    gen-response-body: 100
+    compression: br
+    request-headers:
+      Accept-Encoding: identity
+`
+
+func TestHandlerCompression(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+
+	execPlan(t, ctx, handler, addr, planCompression)
+
+	accessLog := handler.testAccessLog
+	assertInLog(t, accessLog, "GET / 0 -> 200 0", 1)
+	assertInLog(t, accessLog, "GET /gzip 0 -> 200 10000", 1)
+	assertInLog(t, accessLog, "GET /br 0 -> 200 10000", 1)
+	assertInLog(t, accessLog, "GET /identity 0 -> 200 100", 1)
+	assertInLog(t, accessLog, "(gzip ", 1)
+	assertInLog(t, accessLog, "(br ", 1)
+}
+
+func TestReadResponseVerifiesBody(t *testing.T) {
+	body := []byte(strings.Repeat("kaller ", 100))
+	for _, encoding := range []string{ptype.EncodingGzip, ptype.EncodingBrotli} {
+		compressed, err := compress(encoding, 0, body)
+		require.NoError(t, err)
+		assert.Less(t, len(compressed), len(body))
+
+		response := func(checksum string) *http.Response {
+			header := http.Header{}
+			header.Set("Content-Encoding", encoding)
+			header.Set(HeaderBodyChecksum, checksum)
+			return &http.Response{Header: header, Body: io.NopCloser(bytes.NewReader(compressed))}
+		}
+		read, err := readResponse(response(checksum(body)))
+		require.NoError(t, err)
+		assert.Equal(t, body, read)
+
+		_, err = readResponse(response("bad"))
+		assert.Error(t, err)
+	}
+}
+
+var planProcessMemory = `
+execution:
+- loop:
+  times: 5
+  execution:
+  - call:
+    http: GET {{addr}}/leak 200
+    compute:
+      memory-delta-kb: 1024
+      memory-scope: process
+  - call:
+    http: GET {{addr}}/request 200
+    compute:
+      memory-delta-kb: 1024
+  - call:
+    http: GET {{addr}}/leak-mmap 200
+    compute:
+      memory-delta-kb: 1024
+      memory-scope: process
+      memory-backing: mmap
+  - call:
+    http: GET {{addr}}/request-mmap 200
+    compute:
+      memory-delta-kb: 1024
+      memory-backing: mmap
+- loop:
+  times: {{times}}
+  execution:
+  - call:
+    http: GET {{addr}}/capped 200
+    compute:
+      memory-delta-kb: 1024
+      memory-scope: process
+      memory-cap-kb: 7168
+      memory-cap-reset: {{reset}}
+`
+
+func TestHandlerProcessMemory(t *testing.T) {
+	run := func(times int, reset bool) int {
+		ctx, cancel, handler, addr := launchServer(t)
+		defer cancel()
+		planStr := strings.ReplaceAll(planProcessMemory, "{{times}}", strconv.Itoa(times))
+		planStr = strings.ReplaceAll(planStr, "{{reset}}", strconv.FormatBool(reset))
+		execPlan(t, ctx, handler, addr, planStr)
+		return handler.ProcessMemory()
+	}
+	mb := 1024 * 1024
+	assert.Equal(t, 11*mb, run(1, false))
+	assert.Equal(t, 12*mb, run(3, false))
+	assert.Equal(t, 5*mb, run(3, true))
+	assert.Equal(t, 6*mb, run(4, true))
+}
+
+var planContend = `
+execution:
+- parallel:
+  execution:
+  - call:
+    http: GET {{addr}}/a 200
+    execution:
+    - contend:
+      resource: db
+      kind: {{kind}}
+      permits: 2
+      timeout: {{timeout}}
+      execution:
+      - compute: 100ms
+  - call:
+    http: GET {{addr}}/b 200
+    execution:
+    - contend:
+      resource: db
+      kind: {{kind}}
+      permits: 2
+      timeout: {{timeout}}
+      execution:
+      - compute: 100ms
+  - call:
+    http: GET {{addr}}/c 200
+    execution:
+    - contend:
+      resource: db
+      kind: {{kind}}
+      permits: 2
+      timeout: {{timeout}}
+      execution:
+      - compute: 100ms
+  - call:
+    http: GET {{addr}}/d 200
+    execution:
+    - contend:
+      resource: db
+      kind: {{kind}}
+      permits: 2
+      timeout: {{timeout}}
+      execution:
+      - compute: 100ms
+`
+
+func TestHandlerContend(t *testing.T) {
+	run := func(kind string, timeout string) (time.Duration, []string) {
+		ctx, cancel, handler, addr := launchServer(t)
+		defer cancel()
+		planStr := strings.ReplaceAll(planContend, "{{kind}}", kind)
+		planStr = strings.ReplaceAll(planStr, "{{timeout}}", timeout)
+		start := time.Now()
+		execPlan(t, ctx, handler, addr, planStr)
+		return time.Since(start), handler.testAccessLog
+	}
+
+	elapsed, accessLog := run("mutex", "0s")
+	assert.Greater(t, elapsed, 400*time.Millisecond)
+	assertInLog(t, accessLog, "w mutex db acquired", 4)
+
+	elapsed, accessLog = run("semaphore", "0s")
+	assert.Greater(t, elapsed, 200*time.Millisecond)
+	assert.Less(t, elapsed, 400*time.Millisecond)
+	assertInLog(t, accessLog, "w semaphore db (2) acquired", 4)
+
+	elapsed, accessLog = run("pool", "50ms")
+	assert.Less(t, elapsed, 200*time.Millisecond)
+	assertInLog(t, accessLog, "w pool db (2) acquired", 2)
+	assertInLog(t, accessLog, "w pool db (2) timed out", 2)
+	assertInLog(t, accessLog, "0 -> 500", 2)
+}
+
+var planIO = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - io: write 1mb 64kb fsync(end)
+  - io: read 256kb random 1mb/s
+`
+
+func TestHandlerIO(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+	handler.ScratchDir = t.TempDir()
+	start := time.Now()
+	execPlan(t, ctx, handler, addr, planIO)
+	assert.Greater(t, time.Since(start), 250*time.Millisecond)
+	assertInLog(t, handler.testAccessLog, "0.0          d write 1mb 64kb fsync(end) 1048576 bytes in 16 blocks", 1)
+	assertInLog(t, handler.testAccessLog, "0.1          d read 256kb random 1mb/s 262144 bytes in 64 blocks", 1)
+}
+
+var planSockets = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - call:
+      tcp:
+        url: http://{{tcp}}/b
+        status-code: 201
+        gen-request-body: 1000
+        gen-response-body: 5000
+        gen-response-body-format: text
+        compression: gzip
+  - call:
+      udp: {{udp}}/c 200 100 lognormal(1kb, 0.1)
+`
+
+func TestHandlerSockets(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+	tcpAddr, udpAddr, _ := launchSocketServer(t, ctx, handler)
+	planStr := strings.ReplaceAll(planSockets, "{{tcp}}", tcpAddr)
+	planStr = strings.ReplaceAll(planStr, "{{udp}}", udpAddr)
+	execPlan(t, ctx, handler, addr, planStr)
+	accessLog := handler.testAccessLog
+	assertInLog(t, accessLog, "0.0          > 127.0.0.1", 1)
+	assertInLog(t, accessLog, "TCP /b 1000 -> 201 5000", 1)
+	assertInLog(t, accessLog, "(gzip ", 1)
+	assertInLog(t, accessLog, "UDP /c 100 -> 200", 1)
+	assertInLog(t, accessLog, "0            < ", 1)
+	assertInLog(t, accessLog, "-> 500", 0)
+}
+
+var planGRPC = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - call:
+      grpc:
+        url: {{grpc}}/b
+        gen-request-body: 1000
+        gen-response-body: 5000
+        gen-response-body-format: text
+        compression: br
+      execution:
+      - call:
+          grpc: {{grpc}}/c 503
+      post-execution:
+      - compute: 50ms
+`
+
+func TestHandlerGRPC(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+	_, _, grpcAddr := launchSocketServer(t, ctx, handler)
+	execPlan(t, ctx, handler, addr, strings.ReplaceAll(planGRPC, "{{grpc}}", grpcAddr))
+	accessLog := handler.testAccessLog
+	assertInLog(t, accessLog, "GRPC /b 1000 -> 200 5000", 1)
+	assertInLog(t, accessLog, "(br ", 1)
+	assertInLog(t, accessLog, "GRPC /c 0 -> 503", 1)
+	assertInLog(t, accessLog, "0.0          p 127.0.0.1", 1)
+	assertInLog(t, accessLog, "-> 500", 0)
+}
+
+var planStreams = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - call:
+      websocket: {{addr}}/b 200 100 1000 x3 every 10ms
+      execution:
+      - call:
+          http: GET {{addr}}/c 200
+      post-execution:
+      - compute: 10ms
+  - call:
+      sse:
+        url: {{addr}}/d
+        gen-response-body: 500
+        gen-response-body-format: binary
+        messages: 2
+        interval: 10ms
+  - call:
+      sse: {{addr}}/e 503 x2
+`
+
+func TestHandlerStreams(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+	execPlan(t, ctx, handler, addr, planStreams)
+	accessLog := handler.testAccessLog
+	assertInLog(t, accessLog, "0.0          m websocket message", 3)
+	assertInLog(t, accessLog, "100 -> 1000", 3)
+	assertInLog(t, accessLog, "0.0.0        > ", 3)
+	assertInLog(t, accessLog, "-> 101", 1)
+	assertInLog(t, accessLog, "0.0          p ", 1)
+	assertInLog(t, accessLog, "0.1          m sse message", 2)
+	assertInLog(t, accessLog, "0 -> 500", 2)
+	assertInLog(t, accessLog, "0.2          < ", 1)
+	assertInLog(t, accessLog, "-> 503", 1)
+	assertInLog(t, accessLog, "-> 500 0 in", 0)
+}
+
+var planProtocols = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - parallel:
+      concurrency: 3
+      execution:
+      - call:
+          http:
+            method: GET
+            url: {{addr}}/b
+            gen-response-body: 1000
+            compression: gzip
+            protocol: h2c
+      - call:
+          http:
+            method: POST
+            url: {{addr}}/c
+            gen-request-body: 1000
+            protocol: h2c
+      - call:
+          http:
+            method: GET
+            url: {{addr}}/d
+            protocol: http1
+  - call:
+      sse:
+        url: {{addr}}/e
+        messages: 2
+        gen-response-body: 100
+        protocol: h2c
+`
+
+func TestHandlerProtocols(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+	execPlan(t, ctx, handler, addr, planProtocols)
+	accessLog := handler.testAccessLog
+	assertInLog(t, accessLog, "GET /b 0 HTTP/2.0", 1)
+	assertInLog(t, accessLog, "POST /c 1000 HTTP/2.0", 1)
+	assertInLog(t, accessLog, "GET /d 0", 2)
+	assertInLog(t, accessLog, "GET /d 0 HTTP/2.0", 0)
+	assertInLog(t, accessLog, "GET /e 0 HTTP/2.0", 1)
+	assertInLog(t, accessLog, "0.1          m sse message", 2)
+	assertInLog(t, accessLog, "HTTP/2.0", 3)
+	assertInLog(t, accessLog, "-> 500", 0)
+}
+
+var planTLS = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - call:
+      http:
+        method: GET
+        url: https://{{tls}}/b
+        gen-response-body: 1000
+        tls:
+          ca: {{dir}}/ca.pem
+          cert: {{dir}}/client.pem
+          key: {{dir}}/client-key.pem
+  - call:
+      http:
+        method: POST
+        url: https://{{tls}}/c
+        gen-request-body: 1000
+        protocol: http1
+        tls:
+          ca: {{dir}}/ca.pem
+          cert: {{dir}}/client.pem
+          key: {{dir}}/client-key.pem
+          server-name: localhost
+  - call:
+      http:
+        method: GET
+        url: https://{{tls}}/d
+        protocol: h2
+        tls:
+          cert: {{dir}}/client.pem
+          key: {{dir}}/client-key.pem
+          skip-verify: true
+  - call:
+      websocket:
+        url: https://{{tls}}/e
+        messages: 2
+        tls:
+          ca: {{dir}}/ca.pem
+          cert: {{dir}}/client.pem
+          key: {{dir}}/client-key.pem
+`
+
+var planTLSNoClientCert = `
+execution:
+- call:
+  http: GET {{addr}}/a 200
+  execution:
+  - call:
+      http:
+        method: GET
+        url: https://{{tls}}/b
+        tls:
+          ca: {{dir}}/ca.pem
+`
+
+func TestHandlerTLS(t *testing.T) {
+	ctx, cancel, handler, addr := launchServer(t)
+	defer cancel()
+	dir := t.TempDir()
+	tlsAddr := launchTLSServer(t, ctx, handler, dir)
+	prepare := func(planStr string) string {
+		planStr = strings.ReplaceAll(planStr, "{{tls}}", tlsAddr)
+		return strings.ReplaceAll(planStr, "{{dir}}", dir)
+	}
+
+	execPlan(t, ctx, handler, addr, prepare(planTLS))
+	accessLog := handler.testAccessLog
+	assertInLog(t, accessLog, "GET /b 0 HTTP/2.0 mtls", 1)
+	assertInLog(t, accessLog, "POST /c 1000 mtls", 1)
+	assertInLog(t, accessLog, "GET /d 0 HTTP/2.0 mtls", 1)
+	assertInLog(t, accessLog, "GET /e 0 mtls", 1)
+	assertInLog(t, accessLog, "0.3          m websocket message", 2)
+	assertInLog(t, accessLog, "-> 500", 0)
+
+	handler.testAccessLog = nil
+	execPlan(t, ctx, handler, addr, prepare(planTLSNoClientCert))
+	accessLog = handler.testAccessLog
+	assertInLog(t, accessLog, "GET /b", 0)
+	assertInLog(t, accessLog, "0            < ", 1)
+	assertInLog(t, accessLog, "GET /a 0 -> 500", 1)
+}
+
 func assertInLog(t *testing.T, accessLog []string, msg string, times int) {
 	found := 0
 	for _, entry := range accessLog {
@@ -140,6 +666,41 @@ func launchServer(t *testing.T) (context.Context, context.CancelFunc, *Handler,
 	return ctx, cancel, handler, addr
 }
 
+// launchSocketServer launches a server for tcp, udp and grpc calls, returning their addresses
+func launchSocketServer(t *testing.T, ctx context.Context, handler *Handler) (string, string, string) {
+	srv := server.Server{}
+	tcpAddr, err := srv.ListenTCP(ctx, "127.0.0.1:0")
+	require.NoError(t, err)
+	udpAddr, err := srv.ListenUDP(ctx, "127.0.0.1:0")
+	require.NoError(t, err)
+	grpcAddr, err := srv.ListenGRPC(ctx, "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.ServeTCP(handler)
+	go srv.ServeUDP(handler)
+	go srv.ServeGRPC(handler)
+	go func() {
+		<-ctx.Done()
+		srv.ShutdownWithTimeout(1 * time.Second)
+	}()
+	return tcpAddr.AddrPort().String(), udpAddr.AddrPort().String(), grpcAddr.AddrPort().String()
+}
+
+// launchTLSServer launches a server requiring client certificates, with certificates issued by
+// a self-signed authority kept in dir
+func launchTLSServer(t *testing.T, ctx context.Context, handler *Handler, dir string) string {
+	config, err := server.SelfSignedTLSConfig(dir, filepath.Join(dir, "ca.pem"))
+	require.NoError(t, err)
+	srv := server.Server{TLSConfig: config}
+	addr, err := srv.Listen(ctx, "127.0.0.1:0")
+	require.NoError(t, err)
+	go srv.Serve(handler)
+	go func() {
+		<-ctx.Done()
+		srv.ShutdownWithTimeout(1 * time.Second)
+	}()
+	return addr.AddrPort().String()
+}
+
 func execPlan(t *testing.T, ctx context.Context, handler *Handler, addr *net.TCPAddr, planString string) {
 	request, err := http.NewRequestWithContext(ctx, "GET", "http://"+addr.AddrPort().String(), nil)
 	require.NoError(t, err)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	ptype "github.com/bcap/kaller/plan"
@@ -14,6 +15,10 @@ const HeaderPlan = "X-kaller-plan"
 const HeaderLocation = "X-kaller-loc"
 const HeaderPlanEncoding = "X-kaller-plan-encoding"
 const HeaderRequestTrace = "X-kaller-request-trace"
+const HeaderService = "X-kaller-service"
+const HeaderSeed = "X-kaller-seed"
+const HeaderIteration = "X-kaller-iteration"
+const HeaderBodyChecksum = "X-kaller-body-checksum"
 
 func WritePlanHeaders(req *http.Request, plan ptype.Plan, location string) error {
 	encodedPlan, err := EncodePlan(plan)
@@ -91,3 +96,17 @@ func WriteRequestTraceHeader(req *http.Request, requestIDs string) {
 func ReadRequestTraceHeader(req *http.Request) string {
 	return req.Header.Get(HeaderRequestTrace)
 }
+
+func WriteSeedHeaders(req *http.Request, seed int64, iteration string) {
+	req.Header.Set(HeaderSeed, strconv.FormatInt(seed, 10))
+	if iteration != "" {
+		req.Header.Set(HeaderIteration, iteration)
+	}
+}
+
+// ReadSeedHeaders returns the seed in effect for the current plan run, or 0 if not
+// present, and the loop iterations that led to the request
+func ReadSeedHeaders(req *http.Request) (int64, string) {
+	seed, _ := strconv.ParseInt(req.Header.Get(HeaderSeed), 10, 64)
+	return seed, req.Header.Get(HeaderIteration)
+}
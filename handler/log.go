@@ -4,6 +4,9 @@ import (
 	"fmt"
 	"log"
 	"time"
+
+	"github.com/bcap/kaller/disk"
+	ptype "github.com/bcap/kaller/plan"
 )
 
 func (h *handler) logRequestIn(location string) {
@@ -15,8 +18,18 @@ func (h *handler) logRequestIn(location string) {
 		h.Request.URL,
 		len(h.RequestBody),
 	)
-	log.Println(msg)
-	h.addTestAccessLogEntry(msg)
+	// only HTTP/2 and TLS are called out, as HTTP/1.1 over cleartext is the norm
+	if h.Request.ProtoMajor == 2 {
+		msg += " " + h.Request.Proto
+	}
+	if h.Request.TLS != nil {
+		if len(h.Request.TLS.PeerCertificates) > 0 {
+			msg += " mtls"
+		} else {
+			msg += " tls"
+		}
+	}
+	h.log(msg)
 }
 
 func (h *handler) logResponseWriteErr(location string, err error) {
@@ -28,8 +41,7 @@ func (h *handler) logResponseWriteErr(location string, err error) {
 		h.Request.URL,
 		err,
 	)
-	log.Println(msg)
-	h.addTestAccessLogEntry(msg)
+	h.log(msg)
 }
 
 func (h *handler) logResponseOut(location string) {
@@ -45,8 +57,10 @@ func (h *handler) logResponseOut(location string) {
 		len(h.ResponseBody),
 		timeTaken,
 	)
-	log.Println(msg)
-	h.addTestAccessLogEntry(msg)
+	if h.ResponseEncoding != "" {
+		msg += fmt.Sprintf(" (%s %d)", h.ResponseEncoding, h.ResponseEncodedSize)
+	}
+	h.log(msg)
 }
 
 func (h *handler) logPostResponseOut(location string) {
@@ -59,6 +73,63 @@ func (h *handler) logPostResponseOut(location string) {
 		h.Request.URL,
 		timeTaken,
 	)
+	h.log(msg)
+}
+
+func (h *handler) logCPUUsage(location string, usage ptype.CPUUsage) {
+	actual := "unmeasured"
+	if usage.Measured {
+		actual = fmt.Sprintf("%.3f", usage.Actual.Seconds())
+	}
+	msg := fmt.Sprintf(
+		"%-12s c cpu-seconds requested %.3f actual %s",
+		location,
+		usage.Requested.Seconds(),
+		actual,
+	)
+	h.log(msg)
+}
+
+func (h *handler) logContend(location string, contend ptype.Contend, outcome string, waited time.Duration) {
+	msg := fmt.Sprintf(
+		"%-12s w %s %s in %v",
+		location,
+		contend.String(),
+		outcome,
+		waited,
+	)
+	h.log(msg)
+}
+
+func (h *handler) logMessage(location string, kind ptype.CallKind, idx int, received int, sent int, timeTaken time.Duration) {
+	msg := fmt.Sprintf(
+		"%-12s m %s message %d %d -> %d in %v",
+		location,
+		kind,
+		idx,
+		received,
+		sent,
+		timeTaken,
+	)
+	h.log(msg)
+}
+
+func (h *handler) logIO(location string, io ptype.IO, result disk.Result) {
+	msg := fmt.Sprintf(
+		"%-12s d %s %d bytes in %d blocks in %v",
+		location,
+		io.String(),
+		result.Bytes,
+		result.Blocks,
+		result.Duration,
+	)
+	h.log(msg)
+}
+
+func (h *handler) log(msg string) {
+	if h.ServiceName != "" {
+		msg = "[" + h.ServiceName + "] " + msg
+	}
 	log.Println(msg)
 	h.addTestAccessLogEntry(msg)
 }
@@ -8,10 +8,10 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-func (h *handler) processSteps(concurrency int, stepIdxOffset int, execution ptype.Execution, location string) error {
+func (h *handler) processSteps(concurrency int, stepIdxOffset int, execution ptype.Execution, location string, iteration string) error {
 	if concurrency == 1 {
 		for stepIdx, step := range execution {
-			if err := h.processStep(stepIdxOffset+stepIdx, step, location); err != nil {
+			if err := h.processStep(stepIdxOffset+stepIdx, step, location, iteration); err != nil {
 				return err
 			}
 		}
@@ -33,7 +33,7 @@ func (h *handler) processSteps(concurrency int, stepIdxOffset int, execution pty
 					if !ok {
 						return nil
 					}
-					if err := h.processStep(stepIdxOffset+stepIdx, execution[stepIdx], location); err != nil {
+					if err := h.processStep(stepIdxOffset+stepIdx, execution[stepIdx], location, iteration); err != nil {
 						return err
 					}
 				}
@@ -47,7 +47,7 @@ func (h *handler) processSteps(concurrency int, stepIdxOffset int, execution pty
 	return group.Wait()
 }
 
-func (h *handler) processStep(stepIdx int, step ptype.Step, location string) error {
+func (h *handler) processStep(stepIdx int, step ptype.Step, location string, iteration string) error {
 	nextLocation := func() string {
 		stepIdxStr := strconv.Itoa(stepIdx)
 		if location == "" {
@@ -59,13 +59,17 @@ func (h *handler) processStep(stepIdx int, step ptype.Step, location string) err
 	var err error
 	switch v := step.(type) {
 	case *ptype.Parallel:
-		err = h.parallel(*v, nextLocation())
+		err = h.parallel(*v, nextLocation(), iteration)
 	case *ptype.Loop:
-		err = h.loop(*v, nextLocation())
+		err = h.loop(*v, nextLocation(), iteration)
 	case *ptype.Compute:
-		err = h.compute(*v)
+		err = h.compute(*v, nextLocation(), iteration)
 	case *ptype.Call:
-		err = h.call(*v, nextLocation())
+		err = h.call(*v, nextLocation(), iteration)
+	case *ptype.Contend:
+		err = h.contend(*v, nextLocation(), iteration)
+	case *ptype.IO:
+		err = h.io(*v, nextLocation(), iteration)
 	default:
 		return fmt.Errorf("unrecognized step type %T", step)
 	}
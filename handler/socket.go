@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bcap/kaller/frame"
+)
+
+// UDPReplyTimeout is how long udp calls wait for the reply datagram before failing, as
+// datagrams can be lost
+var UDPReplyTimeout = 5 * time.Second
+
+// roundTripTCP sends the request over a new TCP connection using kaller framing, and waits for
+// the response
+func roundTripTCP(ctx context.Context, address string, req *http.Request, body []byte) (*http.Response, error) {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if err := frame.Write(conn, requestMessage(req, body)); err != nil {
+		return nil, fmt.Errorf("failed to send tcp request to %s: %w", address, err)
+	}
+	msg, err := frame.Read(conn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tcp response from %s: %w", address, contextErr(ctx, err))
+	}
+	return responseFromMessage(msg)
+}
+
+// roundTripUDP sends the request in a single UDP datagram using kaller framing, and waits for
+// the reply datagram for up to UDPReplyTimeout
+func roundTripUDP(ctx context.Context, address string, req *http.Request, body []byte) (*http.Response, error) {
+	encoded := frame.Encode(requestMessage(req, body))
+	if len(encoded) > frame.MaxDatagramSize {
+		return nil, fmt.Errorf("udp request to %s is too large: %d bytes", address, len(encoded))
+	}
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "udp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn)
+	defer stop()
+
+	if _, err := conn.Write(encoded); err != nil {
+		return nil, fmt.Errorf("failed to send udp request to %s: %w", address, err)
+	}
+	conn.SetReadDeadline(time.Now().Add(UDPReplyTimeout))
+	buf := make([]byte, frame.MaxDatagramSize)
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read udp response from %s: %w", address, contextErr(ctx, err))
+	}
+	msg, err := frame.Decode(buf[:n])
+	if err != nil {
+		return nil, fmt.Errorf("bad udp response from %s: %w", address, err)
+	}
+	return responseFromMessage(msg)
+}
+
+func requestMessage(req *http.Request, body []byte) frame.Message {
+	return frame.Message{Start: req.URL.RequestURI(), Header: req.Header, Payload: body}
+}
+
+func responseFromMessage(msg frame.Message) (*http.Response, error) {
+	statusCode, err := strconv.Atoi(msg.Start)
+	if err != nil {
+		return nil, fmt.Errorf("bad response status code %q", msg.Start)
+	}
+	return &http.Response{
+		StatusCode:    statusCode,
+		Header:        msg.Header,
+		Body:          io.NopCloser(bytes.NewReader(msg.Payload)),
+		ContentLength: int64(len(msg.Payload)),
+	}, nil
+}
+
+// closeOnDone closes the connection once the context is done, unblocking any pending read or
+// write. The returned function must be called once the connection is no longer used
+func closeOnDone(ctx context.Context, conn net.Conn) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+// contextErr returns the context error if the context is done, as it is the actual reason
+// for connection errors in that case
+func contextErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
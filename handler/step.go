@@ -4,29 +4,35 @@ import (
 	"bytes"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/bcap/kaller/memory"
 	ptype "github.com/bcap/kaller/plan"
 	"github.com/bcap/kaller/random"
 	"golang.org/x/sync/errgroup"
 )
 
-func (h *handler) parallel(parallel ptype.Parallel, location string) error {
-	return h.processSteps(parallel.Concurrency, 0, parallel.Execution, location)
+func (h *handler) parallel(parallel ptype.Parallel, location string, iteration string) error {
+	return h.processSteps(parallel.Concurrency, 0, parallel.Execution, location, iteration)
 }
 
-func (h *handler) loop(loop ptype.Loop, location string) error {
-	do := func() error {
-		if err := h.processSteps(1, 0, loop.Execution, location); err != nil {
+func (h *handler) loop(loop ptype.Loop, location string, iteration string) error {
+	do := func(i int) error {
+		loopIteration := joinIterations(iteration, strconv.Itoa(i))
+		if err := h.processSteps(1, 0, loop.Execution, location, loopIteration); err != nil {
 			return err
 		}
-		h.compute(loop.Compute)
+		h.compute(loop.Compute, location, loopIteration)
 		return nil
 	}
 
 	concurrency := loop.Concurrency
 	if concurrency <= 1 {
 		for i := 0; i < loop.Times; i++ {
-			if err := do(); err != nil {
+			if err := do(i); err != nil {
 				return err
 			}
 		}
@@ -37,18 +43,18 @@ func (h *handler) loop(loop ptype.Loop, location string) error {
 		concurrency = loop.Times
 	}
 	group, ctx := errgroup.WithContext(h.Context)
-	runCh := make(chan struct{})
+	runCh := make(chan int)
 	for i := 0; i < concurrency; i++ {
 		group.Go(func() error {
 			for {
 				select {
 				case <-ctx.Done():
 					return nil
-				case _, ok := <-runCh:
+				case i, ok := <-runCh:
 					if !ok {
 						return nil
 					}
-					if err := do(); err != nil {
+					if err := do(i); err != nil {
 						return err
 					}
 				}
@@ -56,36 +62,81 @@ func (h *handler) loop(loop ptype.Loop, location string) error {
 		})
 	}
 	for i := 0; i < loop.Times; i++ {
-		runCh <- struct{}{}
+		runCh <- i
 	}
 	close(runCh)
 	return group.Wait()
 }
 
-func (h *handler) compute(compute ptype.Compute) error {
-	compute.Do(h.Context, &h.Fill)
+func (h *handler) compute(compute ptype.Compute, location string, iteration string) error {
+	compute = h.Override.ApplyCPU(compute)
+	usage := compute.Do(h.Context, h.fill(compute), h.random(random.PurposeCompute, location, iteration))
+	if compute.CPU > 0 {
+		atomic.AddInt64(&h.cpuRequested, int64(usage.Requested))
+		atomic.AddInt64(&h.cpuActual, int64(usage.Actual))
+		if !usage.Measured {
+			atomic.StoreInt32(&h.cpuUnmeasured, 1)
+		}
+		h.logCPUUsage(location, usage)
+	}
 	return nil
 }
 
-func (h *handler) call(call ptype.Call, location string) error {
-	execute := func() error {
-		client := http.Client{}
-		var body *bytes.Buffer
-		if call.HTTP.RequestBody != "" {
-			body = bytes.NewBufferString(call.HTTP.RequestBody)
-		} else if call.HTTP.GenRequestBody > 0 {
-			str := random.String(call.HTTP.GenRequestBody)
-			body = bytes.NewBufferString(str)
-		} else {
-			body = &bytes.Buffer{}
+func (h *handler) io(io ptype.IO, location string, iteration string) error {
+	if err := io.Validate(); err != nil {
+		return err
+	}
+	result, err := io.Do(h.Context, h.ScratchDir, h.random(random.PurposeIO, location, iteration))
+	if err != nil {
+		return err
+	}
+	h.logIO(location, io, result)
+	return nil
+}
+
+// fill returns the memory fill matching the compute memory scope and backing
+func (h *handler) fill(compute ptype.Compute) *memory.Fill {
+	mmap := compute.MemoryBacking == ptype.MemoryBackingMmap
+	if compute.MemoryScope == ptype.MemoryScopeProcess {
+		if mmap {
+			return &h.processMmapFill
 		}
+		return &h.processFill
+	}
+	if mmap {
+		return &h.MmapFill
+	}
+	return &h.Fill
+}
+
+func (h *handler) delay(duration time.Duration) {
+	if duration <= 0 {
+		return
+	}
+	select {
+	case <-time.After(duration):
+	case <-h.Context.Done():
+	}
+}
+
+func (h *handler) call(call ptype.Call, location string, iteration string) error {
+	rnd := h.random(random.PurposeRequestBody, location, iteration)
+	execute := func() error {
+		message := call.Message()
+		body, contentType := message.GetRequestBody(rnd)
 		req, err := http.NewRequestWithContext(
-			h.Context, call.HTTP.Method, call.HTTP.URL.String(), body,
+			h.Context, message.Method, message.URL.String(), bytes.NewBuffer(body),
 		)
 		if err != nil {
 			return err
 		}
-		for key, value := range call.HTTP.RequestHeaders {
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if message.Compression != nil {
+			req.Header.Set("Accept-Encoding", strings.Join(message.Compression.AcceptedEncodings(), ", "))
+		}
+		for key, value := range message.RequestHeaders {
 			req.Header.Set(key, value)
 		}
 		if h.EncodedPlan != nil {
@@ -94,7 +145,32 @@ func (h *handler) call(call ptype.Call, location string) error {
 			return err
 		}
 		WriteRequestTraceHeader(req, h.RequestID)
-		_, err = client.Do(req)
+		WriteSeedHeaders(req, h.Seed, joinIterations(h.Iteration, iteration))
+
+		var resp *http.Response
+		switch call.Kind() {
+		case ptype.CallKindTCP:
+			resp, err = roundTripTCP(h.Context, call.Address(), req, body)
+		case ptype.CallKindUDP:
+			resp, err = roundTripUDP(h.Context, call.Address(), req, body)
+		case ptype.CallKindGRPC:
+			resp, err = h.roundTripGRPC(h.Context, call.Address(), req, body)
+		case ptype.CallKindWebSocket:
+			return h.streamWebSocket(h.Context, call.WebSocket, req, rnd)
+		case ptype.CallKindSSE:
+			return h.streamSSE(h.Context, call.SSE, req)
+		default:
+			var client *http.Client
+			client, err = h.httpClients.get(message.Protocol, message.TLS)
+			if err != nil {
+				return err
+			}
+			resp, err = client.Do(req)
+		}
+		if err != nil {
+			return err
+		}
+		_, err = readResponse(resp)
 		return err
 	}
 
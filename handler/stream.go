@@ -0,0 +1,229 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gorilla/websocket"
+
+	ptype "github.com/bcap/kaller/plan"
+	"github.com/bcap/kaller/random"
+)
+
+// maxEventSize is the largest SSE event line accepted by sse calls
+const maxEventSize = 64 * 1024 * 1024
+
+//
+// Caller side
+//
+
+// streamWebSocket opens a WebSocket to the other kaller, sending each message and waiting for its
+// reply. Streams rejected by the other kaller are not a failure, the same way HTTP calls
+// responding with error status codes are not
+func (h *handler) streamWebSocket(ctx context.Context, stream *ptype.Stream, req *http.Request, rnd *rand.Rand) error {
+	url := *req.URL
+	url.Scheme = strings.Replace(url.Scheme, "http", "ws", 1)
+	config, err := tlsConfig(stream.TLS)
+	if err != nil {
+		return err
+	}
+	dialer := websocket.Dialer{TLSClientConfig: config}
+	conn, resp, err := dialer.DialContext(ctx, url.String(), req.Header)
+	if errors.Is(err, websocket.ErrBadHandshake) && resp != nil {
+		_, err = readResponse(resp)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := closeOnDone(ctx, conn.NetConn())
+	defer stop()
+
+	for idx := 0; idx < stream.MessageCount(); idx++ {
+		if idx > 0 {
+			h.delay(stream.Interval)
+		}
+		body, _ := stream.GetRequestBody(rnd)
+		if err := conn.WriteMessage(websocket.BinaryMessage, body); err != nil {
+			return fmt.Errorf("failed to send websocket message %d: %w", idx, contextErr(ctx, err))
+		}
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return fmt.Errorf("failed to read websocket message %d reply: %w", idx, contextErr(ctx, err))
+		}
+	}
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	return conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+}
+
+// streamSSE requests an SSE stream from the other kaller, reading events until all expected
+// messages arrived
+func (h *handler) streamSSE(ctx context.Context, stream *ptype.Stream, req *http.Request) error {
+	req.Header.Set("Accept", "text/event-stream")
+	client, err := h.httpClients.get(stream.Protocol, stream.TLS)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		_, err = readResponse(resp)
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), maxEventSize)
+	events := 0
+	hasData := false
+	for events < stream.MessageCount() && scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "" && hasData:
+			events++
+			hasData = false
+		case strings.HasPrefix(line, "data:"):
+			hasData = true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read sse stream: %w", contextErr(ctx, err))
+	}
+	if events < stream.MessageCount() {
+		return fmt.Errorf("sse stream ended after %d of %d messages", events, stream.MessageCount())
+	}
+	return nil
+}
+
+//
+// Called side
+//
+
+// serveStream serves a WebSocket or SSE stream, running the call Execution once per message.
+// Returns whether the stream was served successfully
+func (h *handler) serveStream(call *ptype.Call, location string) bool {
+	stream := call.Stream()
+	statusCode := stream.PickStatusCode(h.random(random.PurposeStatus, location, ""))
+	if statusCode < 200 || statusCode > 299 {
+		h.textResponse(statusCode, "%s stream rejected with status code %d", call.Kind(), statusCode)
+		h.ResponseStatusCode = statusCode
+		h.logResponseOut(location)
+		return false
+	}
+	for key, value := range stream.ResponseHeaders {
+		h.Response.Header().Set(key, value)
+	}
+
+	var err error
+	if call.Kind() == ptype.CallKindWebSocket {
+		err = h.serveWebSocket(call, location)
+	} else {
+		err = h.serveSSE(call, location, statusCode)
+	}
+	h.RespondedAt = time.Now()
+	if err != nil {
+		h.logResponseWriteErr(location, err)
+		return false
+	}
+	h.logResponseOut(location)
+	return true
+}
+
+func (h *handler) serveWebSocket(call *ptype.Call, location string) error {
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(h.Response, h.Request, h.Response.Header())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	h.ResponseStatusCode = http.StatusSwitchingProtocols
+	stop := closeOnDone(h.Context, conn.NetConn())
+	defer stop()
+
+	for idx := 0; ; idx++ {
+		_, msg, err := conn.ReadMessage()
+		if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+			return nil
+		}
+		if err != nil {
+			return contextErr(h.Context, err)
+		}
+		start := time.Now()
+		iteration := strconv.Itoa(idx)
+		if err := h.processSteps(1, 0, call.Execution, location, iteration); err != nil {
+			closeMsg := websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "execution failure")
+			conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+			return fmt.Errorf("execution failure: %w", err)
+		}
+		body, _ := call.WebSocket.GetResponseBody(h.random(random.PurposeResponseBody, location, iteration))
+		if err := conn.WriteMessage(websocket.BinaryMessage, body); err != nil {
+			return contextErr(h.Context, err)
+		}
+		h.logMessage(location, call.Kind(), idx, len(msg), len(body), time.Since(start))
+	}
+}
+
+func (h *handler) serveSSE(call *ptype.Call, location string, statusCode int) error {
+	flusher, ok := h.Response.(http.Flusher)
+	if !ok {
+		h.textResponse(500, "sse streams are not supported by the connection")
+		return errors.New("sse streams are not supported by the connection")
+	}
+	header := h.Response.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	h.Response.WriteHeader(statusCode)
+	h.ResponseStatusCode = statusCode
+	flusher.Flush()
+
+	for idx := 0; idx < call.SSE.MessageCount(); idx++ {
+		if idx > 0 {
+			h.delay(call.SSE.Interval)
+		}
+		if err := h.Context.Err(); err != nil {
+			return err
+		}
+		start := time.Now()
+		iteration := strconv.Itoa(idx)
+		if err := h.processSteps(1, 0, call.Execution, location, iteration); err != nil {
+			return fmt.Errorf("execution failure: %w", err)
+		}
+		body, _ := call.SSE.GetResponseBody(h.random(random.PurposeResponseBody, location, iteration))
+		if err := writeEvent(h.Response, idx, body); err != nil {
+			return err
+		}
+		flusher.Flush()
+		h.logMessage(location, call.Kind(), idx, 0, len(body), time.Since(start))
+	}
+	return nil
+}
+
+// writeEvent writes the body as an SSE event, one data line per body line. Bodies that are not
+// valid UTF-8 text are base64 encoded
+func writeEvent(w io.Writer, id int, body []byte) error {
+	data := string(body)
+	if !utf8.Valid(body) {
+		data = base64.StdEncoding.EncodeToString(body)
+	}
+	data = strings.ReplaceAll(strings.ReplaceAll(data, "\r\n", "\n"), "\r", "\n")
+	event := strings.Builder{}
+	event.WriteString("id: " + strconv.Itoa(id) + "\n")
+	for _, line := range strings.Split(data, "\n") {
+		event.WriteString("data: " + line + "\n")
+	}
+	event.WriteString("\n")
+	_, err := io.WriteString(w, event.String())
+	return err
+}
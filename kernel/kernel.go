@@ -0,0 +1,248 @@
+// Package kernel provides small units of cpu work that resemble what real applications do,
+// so that cpu profiles and hardware counters of simulated computations look realistic
+package kernel
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"sync"
+)
+
+type Kind string
+
+const (
+	// Spin does no work at all, leaving the caller to busy loop. This is the default
+	Spin Kind = "spin"
+	// SHA256 hashes a 4kb buffer
+	SHA256 Kind = "sha256"
+	// JSON encodes and decodes a small document
+	JSON Kind = "json"
+	// Regex matches a few regular expressions against log-like lines
+	Regex Kind = "regex"
+	// Compress deflates a 4kb text buffer
+	Compress Kind = "compress"
+	// PointerChase follows pointers through a 16mb randomly linked table, which is memory
+	// bound and generates cache misses
+	PointerChase Kind = "pointer-chase"
+)
+
+// Kinds lists all available kernels
+var Kinds = []Kind{Spin, SHA256, JSON, Regex, Compress, PointerChase}
+
+// Kernel runs units of work. Each unit takes in the order of microseconds. Kernels are not
+// safe for concurrent use, so each goroutine should create or acquire its own
+type Kernel interface {
+	Work()
+}
+
+// pools keeps released kernels of each kind, as some kernels hold state that is expensive to
+// allocate, like the ~1mb flate writer of the compress kernel
+var pools = func() map[Kind]*sync.Pool {
+	pools := make(map[Kind]*sync.Pool, len(Kinds))
+	for _, kind := range Kinds {
+		kind := kind
+		pools[kind] = &sync.Pool{New: func() any {
+			kernel, _ := New(kind)
+			return kernel
+		}}
+	}
+	return pools
+}()
+
+// Acquire returns a kernel of the given kind, reusing a kernel released with Release if there
+// is one, or creating a new one otherwise. An empty kind means Spin
+func Acquire(kind Kind) (Kernel, error) {
+	if kind == "" {
+		kind = Spin
+	}
+	pool, ok := pools[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown kernel %q", kind)
+	}
+	return pool.Get().(Kernel), nil
+}
+
+// Release makes the kernel, which was acquired with Acquire for the given kind, available to
+// be acquired again. The kernel must not be used after it is released
+func Release(kind Kind, kernel Kernel) {
+	if kind == "" {
+		kind = Spin
+	}
+	if pool, ok := pools[kind]; ok {
+		pool.Put(kernel)
+	}
+}
+
+// New creates a kernel of the given kind. An empty kind means Spin
+func New(kind Kind) (Kernel, error) {
+	switch kind {
+	case "", Spin:
+		return spin{}, nil
+	case SHA256:
+		return &sha256Kernel{buf: input()}, nil
+	case JSON:
+		return &jsonKernel{}, nil
+	case Regex:
+		return &regexKernel{}, nil
+	case Compress:
+		writer, _ := flate.NewWriter(nil, flate.DefaultCompression)
+		return &compressKernel{input: input(), writer: writer}, nil
+	case PointerChase:
+		return &pointerChaseKernel{table: chaseTable()}, nil
+	default:
+		return nil, fmt.Errorf("unknown kernel %q", kind)
+	}
+}
+
+// Validate checks whether the given kind is a known kernel. An empty kind means Spin. Unlike
+// New, it does not allocate any kernel state
+func Validate(kind Kind) error {
+	if kind == "" {
+		return nil
+	}
+	for _, known := range Kinds {
+		if kind == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown kernel %q", kind)
+}
+
+type spin struct{}
+
+func (spin) Work() {}
+
+// kernels accumulate their results in a sum field, so the work is not optimized away
+
+type sha256Kernel struct {
+	buf []byte
+	sum uint64
+}
+
+func (k *sha256Kernel) Work() {
+	sum := sha256.Sum256(k.buf)
+	k.sum += uint64(sum[0])
+}
+
+type document struct {
+	ID      int               `json:"id"`
+	Name    string            `json:"name"`
+	Tags    []string          `json:"tags"`
+	Score   float64           `json:"score"`
+	Active  bool              `json:"active"`
+	Attrs   map[string]string `json:"attrs"`
+	Related []int             `json:"related"`
+}
+
+type jsonKernel struct {
+	count int
+	sum   uint64
+}
+
+func (k *jsonKernel) Work() {
+	k.count++
+	doc := document{
+		ID:      k.count,
+		Name:    "kaller simulated document",
+		Tags:    []string{"service", "request", "response", "compute"},
+		Score:   float64(k.count) / 3,
+		Active:  k.count%2 == 0,
+		Attrs:   map[string]string{"region": "us-east-1", "tier": "backend", "version": "v1.2.3"},
+		Related: []int{k.count + 1, k.count + 2, k.count + 3, k.count + 4},
+	}
+	encoded, _ := json.Marshal(doc)
+	var decoded document
+	json.Unmarshal(encoded, &decoded)
+	k.sum += uint64(decoded.ID)
+}
+
+var regexes = []*regexp.Regexp{
+	regexp.MustCompile(`(\d{1,3}\.){3}\d{1,3}`),
+	regexp.MustCompile(`[\w.+-]+@[\w-]+\.[\w.]+`),
+	regexp.MustCompile(`(GET|POST|PUT|DELETE) (/[\w/.-]*)(\?\S*)? HTTP/1\.[01]" (\d{3})`),
+}
+
+var lines = []string{
+	`10.0.12.34 - alice@example.com [10/Oct/2023:13:55:36] "GET /service1/listing?page=2 HTTP/1.1" 200 10240`,
+	`192.168.1.7 - - [10/Oct/2023:13:55:37] "POST /service5/metrics HTTP/1.1" 201 200`,
+	`172.16.0.2 - bob.smith+test@corp.example.org [10/Oct/2023:13:55:38] "DELETE /profile/42 HTTP/1.0" 404 0`,
+}
+
+type regexKernel struct {
+	count int
+	sum   uint64
+}
+
+func (k *regexKernel) Work() {
+	line := lines[k.count%len(lines)]
+	k.count++
+	for _, regex := range regexes {
+		k.sum += uint64(len(regex.FindStringSubmatchIndex(line)))
+	}
+}
+
+type compressKernel struct {
+	input  []byte
+	writer *flate.Writer
+	buf    bytes.Buffer
+	sum    uint64
+}
+
+func (k *compressKernel) Work() {
+	k.buf.Reset()
+	k.writer.Reset(&k.buf)
+	k.writer.Write(k.input)
+	k.writer.Close()
+	k.sum += uint64(k.buf.Len())
+}
+
+// how many pointers are followed in a single unit of work
+const chaseSteps = 256
+
+type pointerChaseKernel struct {
+	table []uint32
+	next  uint32
+	sum   uint64
+}
+
+func (k *pointerChaseKernel) Work() {
+	next := k.next
+	for i := 0; i < chaseSteps; i++ {
+		next = k.table[next]
+	}
+	k.next = next
+	k.sum += uint64(next)
+}
+
+var chaseTableOnce sync.Once
+var chaseTableData []uint32
+
+// chaseTable returns a table where following the entries as indexes visits all entries in a
+// random order (a single random cycle). The table is shared and read only
+func chaseTable() []uint32 {
+	chaseTableOnce.Do(func() {
+		const entries = 4 * 1024 * 1024 // 16mb
+		rnd := rand.New(rand.NewSource(1))
+		order := rnd.Perm(entries)
+		chaseTableData = make([]uint32, entries)
+		for i := range order {
+			chaseTableData[order[i]] = uint32(order[(i+1)%entries])
+		}
+	})
+	return chaseTableData
+}
+
+// input returns 4kb of log-like text
+func input() []byte {
+	buf := bytes.Buffer{}
+	for i := 0; buf.Len() < 4096; i++ {
+		buf.WriteString(lines[i%len(lines)])
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()[:4096]
+}
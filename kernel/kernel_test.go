@@ -0,0 +1,82 @@
+package kernel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKernels(t *testing.T) {
+	for _, kind := range append(Kinds, "") {
+		kernel, err := New(kind)
+		require.NoError(t, err, kind)
+		start := time.Now()
+		for i := 0; i < 100; i++ {
+			kernel.Work()
+		}
+		// units of work should be small enough for computes to stay precise
+		assert.Less(t, time.Since(start), 100*time.Millisecond, kind)
+	}
+
+	_, err := New("bogus")
+	assert.Error(t, err)
+	assert.Error(t, Validate("bogus"))
+	assert.NoError(t, Validate(SHA256))
+	assert.NoError(t, Validate(""))
+	for _, kind := range Kinds {
+		assert.NoError(t, Validate(kind))
+		_, err := New(kind)
+		assert.NoError(t, err, kind)
+	}
+	allocs := testing.AllocsPerRun(10, func() { Validate(PointerChase) })
+	assert.Equal(t, 0.0, allocs)
+}
+
+func TestAcquire(t *testing.T) {
+	for _, kind := range append(Kinds, "") {
+		kernel, err := Acquire(kind)
+		require.NoError(t, err, kind)
+		kernel.Work()
+		Release(kind, kernel)
+	}
+	_, err := Acquire("bogus")
+	assert.Error(t, err)
+
+	// acquiring released kernels does not allocate their state again. Pools may still drop
+	// released kernels, so this only checks most of the allocations are avoided
+	created := testing.AllocsPerRun(100, func() {
+		kernel, _ := New(Compress)
+		kernel.Work()
+	})
+	acquired := testing.AllocsPerRun(100, func() {
+		kernel, _ := Acquire(Compress)
+		kernel.Work()
+		Release(Compress, kernel)
+	})
+	assert.Less(t, acquired, created/2)
+}
+
+func TestChaseTableIsSingleCycle(t *testing.T) {
+	table := chaseTable()
+	visited := make([]bool, len(table))
+	next := uint32(0)
+	for i := 0; i < len(table); i++ {
+		require.False(t, visited[next])
+		visited[next] = true
+		next = table[next]
+	}
+	assert.Equal(t, uint32(0), next)
+}
+
+func BenchmarkKernels(b *testing.B) {
+	for _, kind := range Kinds {
+		kernel, _ := New(kind)
+		b.Run(string(kind), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				kernel.Work()
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"context"
+	"time"
+)
+
+// how many of the most recently allocated objects are kept alive while churning
+const churnLiveObjects = 64
+
+// MaxChurnObjectSize caps the size of each object allocated by Churn, so a heavy tailed size
+// function cannot make a single allocation take a huge amount of memory
+var MaxChurnObjectSize = 64 * 1024 * 1024 // 64mb
+
+// Churn simulates garbage collection pressure by allocating short-lived objects at the given
+// rate in bytes per second, until the duration elapses or the context is done. The size of
+// each object is given by the size function, capped at the total amount of bytes to allocate
+// (rate times duration) and at MaxChurnObjectSize. Only the most recently allocated objects
+// are kept alive, so the allocated memory quickly becomes garbage
+//
+// Returns the amount of bytes allocated
+func Churn(ctx context.Context, duration time.Duration, rate int64, size func() int) int64 {
+	start := time.Now()
+	live := make([][]byte, churnLiveObjects)
+	maxSize := int64(float64(rate) * duration.Seconds())
+	if maxSize > int64(MaxChurnObjectSize) {
+		maxSize = int64(MaxChurnObjectSize)
+	}
+	var allocated int64
+	for idx := 0; ; {
+		elapsed := time.Since(start)
+		if elapsed >= duration || ctx.Err() != nil || rate <= 0 {
+			return allocated
+		}
+		target := int64(float64(rate) * elapsed.Seconds())
+		if allocated >= target {
+			wait := time.Millisecond
+			if wait > duration-elapsed {
+				wait = duration - elapsed
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		for allocated < target {
+			objectSize := size()
+			if int64(objectSize) > maxSize {
+				objectSize = int(maxSize)
+			}
+			if objectSize < 1 {
+				objectSize = 1
+			}
+			object := make([]byte, objectSize)
+			object[0] = byte(idx)
+			live[idx%churnLiveObjects] = object
+			allocated += int64(objectSize)
+			idx++
+		}
+	}
+}
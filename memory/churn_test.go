@@ -0,0 +1,45 @@
+package memory
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChurn(t *testing.T) {
+	mb := 1024 * 1024
+	ctx := context.Background()
+
+	before := Stats()
+	allocated := Churn(ctx, 200*time.Millisecond, int64(100*mb), func() int { return 1024 })
+	after := Stats()
+
+	assert.InDelta(t, 20*mb, allocated, float64(2*mb))
+	assert.GreaterOrEqual(t, after.TotalAlloc-before.TotalAlloc, uint64(allocated))
+	assert.Greater(t, after.Mallocs-before.Mallocs, uint64(allocated/1024)-1)
+
+	assert.Equal(t, int64(0), Churn(ctx, 100*time.Millisecond, 0, func() int { return 1024 }))
+}
+
+func TestChurnObjectSizeCap(t *testing.T) {
+	mb := 1024 * 1024
+	ctx := context.Background()
+
+	// objects are capped at the total amount to allocate
+	before := Stats()
+	allocated := Churn(ctx, 50*time.Millisecond, int64(20*mb), func() int { return 1 << 40 })
+	after := Stats()
+	assert.LessOrEqual(t, allocated, int64(mb))
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(10*mb))
+
+	// and at MaxChurnObjectSize
+	defer func(max int) { MaxChurnObjectSize = max }(MaxChurnObjectSize)
+	MaxChurnObjectSize = 1024
+	before = Stats()
+	allocated = Churn(ctx, 50*time.Millisecond, int64(20*mb), func() int { return 1 << 40 })
+	after = Stats()
+	assert.InDelta(t, mb, allocated, float64(mb)/2)
+	assert.Greater(t, after.Mallocs-before.Mallocs, uint64(allocated/1024)-1)
+}
@@ -1,10 +1,12 @@
 package memory
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"runtime"
 	"sync"
+	"time"
 )
 
 const (
@@ -27,10 +29,33 @@ func init() {
 	}
 }
 
+// Backing defines where the memory held by a Fill is allocated
+type Backing int8
+
+const (
+	// Heap backed fills allocate regular Go slices, which are tracked by the garbage collector
+	Heap Backing = 0
+	// Mmap backed fills allocate anonymous memory mappings outside of the Go heap, so they do
+	// not affect garbage collection. When shrinking, released pages are returned to the OS with
+	// madvise. Only supported on linux, falling back to Heap elsewhere
+	Mmap Backing = 1
+)
+
+// Fill holds an adjustable amount of memory. The zero value is an empty heap backed Fill.
+// The Backing must be set before the Fill is first used
 type Fill struct {
+	Backing Backing
+
 	buf             []byte
 	mutex           sync.RWMutex
 	debugPrintStats bool
+	// mapping is the whole memory mapping buf is part of, for mmap backed fills
+	mapping []byte
+	// where the next Touch continues sweeping the buffer from
+	touchOffset int
+	// touchSink receives the bytes read while touching, so reads are not optimized away. It is
+	// guarded by mutex like the buffer itself
+	touchSink byte
 }
 
 func (f *Fill) DebugPrintStats(set bool) {
@@ -42,19 +67,31 @@ func (f *Fill) DebugPrintStats(set bool) {
 type mode int8
 
 const (
-	set mode = 0
-	add mode = 1
+	set       mode = 0
+	add       mode = 1
+	addCapped mode = 2
+	addReset  mode = 3
 )
 
 func (f *Fill) Set(bytes int) (int, int) {
-	return f.adjust(set, bytes, "Set")
+	return f.adjust(set, bytes, 0, "Set")
 }
 
 func (f *Fill) Add(bytes int) (int, int) {
-	return f.adjust(add, bytes, "Add")
+	return f.adjust(add, bytes, 0, "Add")
 }
 
-func (f *Fill) adjust(mode mode, bytes int, debugfnName string) (int, int) {
+// AddCapped works like Add, but does not let the fill grow beyond limit bytes. When the limit
+// would be exceeded, the fill is either kept at the limit or, if reset is set, emptied. A
+// limit of 0 or less means no limit
+func (f *Fill) AddCapped(bytes int, limit int, reset bool) (int, int) {
+	if reset {
+		return f.adjust(addReset, bytes, limit, "AddCapped")
+	}
+	return f.adjust(addCapped, bytes, limit, "AddCapped")
+}
+
+func (f *Fill) adjust(mode mode, bytes int, limit int, debugfnName string) (int, int) {
 	var fnCall string
 	if f.debugPrintStats {
 		fnCall = fmt.Sprintf("Fill.%s(%s)", debugfnName, HumanizeBytesInt64(int64(bytes)))
@@ -69,12 +106,29 @@ func (f *Fill) adjust(mode mode, bytes int, debugfnName string) (int, int) {
 		newSize = bytes
 	case add:
 		newSize = oldSize + bytes
+	case addCapped:
+		newSize = oldSize + bytes
+		if limit > 0 && newSize > limit {
+			newSize = limit
+		}
+	case addReset:
+		newSize = oldSize + bytes
+		if limit > 0 && newSize > limit {
+			newSize = 0
+		}
 	default:
 		panic(fmt.Sprintf("invalid mode %d", mode))
 	}
-	adjustBuffer(&f.buf, newSize)
-	if oldSize > GCThreshold || newSize > GCThreshold {
-		runtime.GC()
+	if f.Backing == Mmap {
+		if err := f.adjustMapping(newSize); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to adjust memory mapping to %s: %v\n", HumanizeBytesInt64(int64(newSize)), err)
+			newSize = len(f.buf)
+		}
+	} else {
+		adjustBuffer(&f.buf, newSize)
+		if oldSize > GCThreshold || newSize > GCThreshold {
+			runtime.GC()
+		}
 	}
 	f.mutex.Unlock()
 	if f.debugPrintStats {
@@ -94,14 +148,90 @@ func adjustBuffer(buffer *[]byte, bytes int) {
 	}
 	new := make([]byte, bytes)
 	copy(new, *buffer)
-	for i := len(*buffer); i < len(new); i += len(chunk) {
-		copy(new[i:], chunk)
-	}
+	fillPattern(new, len(*buffer))
 	*buffer = new
 }
 
+// fillPattern writes the chunk pattern to the buffer starting at the given offset, so that
+// every byte of the buffer ends up with its offset as its value (modulo 256)
+func fillPattern(buffer []byte, from int) {
+	for i := from; i < len(buffer); {
+		i += copy(buffer[i:], chunk[i%len(chunk):])
+	}
+}
+
 func (f *Fill) Size() int {
 	f.mutex.RLock()
 	defer f.mutex.RUnlock()
 	return len(f.buf)
 }
+
+// DefaultTouchStride is the default distance in bytes between touched bytes. It matches the
+// size of a typical cache line, so every cache line of the buffer gets touched
+const DefaultTouchStride = 64
+
+// how many bytes are swept while holding the fill lock
+const touchChunk = 64 * 1024
+
+// Touch simulates memory pressure by repeatedly reading and writing the held buffer, one
+// byte every stride bytes, until the duration elapses or the context is done. The buffer is
+// swept at the given rate in bytes per second, wrapping around when reaching its end. This
+// keeps the buffer pages resident and, once the buffer is larger than the cpu caches,
+// generates cache misses. Touching does not change the buffer contents
+//
+// Returns the amount of bytes swept
+func (f *Fill) Touch(ctx context.Context, duration time.Duration, rate int64, stride int) int64 {
+	if stride <= 0 {
+		stride = DefaultTouchStride
+	}
+	start := time.Now()
+	var swept int64
+	for {
+		elapsed := time.Since(start)
+		if elapsed >= duration || ctx.Err() != nil || rate <= 0 {
+			return swept
+		}
+		target := int64(float64(rate) * elapsed.Seconds())
+		if swept >= target {
+			wait := time.Millisecond
+			if wait > duration-elapsed {
+				wait = duration - elapsed
+			}
+			select {
+			case <-time.After(wait):
+			case <-ctx.Done():
+			}
+			continue
+		}
+		toSweep := target - swept
+		if toSweep > touchChunk {
+			toSweep = touchChunk
+		}
+		n := f.touch(int(toSweep), stride)
+		if n == 0 {
+			return swept
+		}
+		swept += int64(n)
+	}
+}
+
+func (f *Fill) touch(bytes int, stride int) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if len(f.buf) == 0 {
+		return 0
+	}
+	swept := 0
+	pos := f.touchOffset
+	for swept < bytes {
+		if pos >= len(f.buf) {
+			pos = 0
+		}
+		f.touchSink += f.buf[pos]
+		f.buf[pos] = chunk[pos%len(chunk)]
+		pos += stride
+		swept += stride
+	}
+	f.touchOffset = pos
+	return swept
+}
@@ -1,7 +1,10 @@
 package memory
 
 import (
+	"context"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -57,3 +60,99 @@ func TestFill(t *testing.T) {
 	fill.Set(15 * mb)
 	check(15 * mb)
 }
+
+func TestFillTouch(t *testing.T) {
+	fill := Fill{}
+	mb := 1024 * 1024
+	ctx := context.Background()
+
+	assert.Equal(t, int64(0), fill.Touch(ctx, 10*time.Millisecond, int64(100*mb), 0))
+
+	fill.Set(mb)
+	swept := fill.Touch(ctx, 200*time.Millisecond, int64(50*mb), 0)
+	assert.InDelta(t, 10*mb, swept, float64(mb))
+	for i := 0; i < len(fill.buf); i++ {
+		if fill.buf[i] != byte(i) {
+			assert.Equal(t, byte(i), fill.buf[i])
+		}
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, int64(0), fill.Touch(canceled, time.Second, int64(50*mb), 4096))
+}
+
+// touching different fills concurrently must not share any state. Run with -race
+func TestFillTouchConcurrent(t *testing.T) {
+	mb := 1024 * 1024
+	fills := make([]Fill, 4)
+	done := make(chan struct{})
+	for idx := range fills {
+		fills[idx].Set(mb)
+		go func(fill *Fill) {
+			fill.Touch(context.Background(), 20*time.Millisecond, int64(100*mb), 0)
+			done <- struct{}{}
+		}(&fills[idx])
+	}
+	for range fills {
+		<-done
+	}
+}
+
+func TestFillAddCapped(t *testing.T) {
+	fill := Fill{}
+	kb := 1024
+
+	fill.AddCapped(10*kb, 25*kb, false)
+	fill.AddCapped(10*kb, 25*kb, false)
+	assert.Equal(t, 20*kb, fill.Size())
+	fill.AddCapped(10*kb, 25*kb, false)
+	assert.Equal(t, 25*kb, fill.Size())
+
+	fill.AddCapped(10*kb, 25*kb, true)
+	assert.Equal(t, 0, fill.Size())
+	fill.AddCapped(10*kb, 25*kb, true)
+	assert.Equal(t, 10*kb, fill.Size())
+
+	fill.AddCapped(100*kb, 0, true)
+	assert.Equal(t, 110*kb, fill.Size())
+}
+
+func TestFillMmap(t *testing.T) {
+	fill := Fill{Backing: Mmap}
+	kb := 1024
+	mb := 1024 * kb
+
+	check := func(size int) {
+		assert.Equal(t, size, fill.Size())
+		for i := 0; i < len(fill.buf); i++ {
+			if fill.buf[i] != byte(i) {
+				assert.Equal(t, byte(i), fill.buf[i])
+			}
+		}
+	}
+
+	heapBefore := Stats().HeapAlloc
+	fill.Add(100*mb + 3)
+	check(100*mb + 3)
+	if runtime.GOOS == "linux" {
+		assert.Less(t, Stats().HeapAlloc, heapBefore+10*uint64(mb))
+	}
+
+	fill.Add(-50*mb - 1000)
+	check(50*mb - 997)
+
+	fill.Add(30 * mb)
+	check(80*mb - 997)
+
+	fill.Set(200 * mb)
+	check(200 * mb)
+
+	fill.Set(0)
+	check(0)
+	assert.Nil(t, fill.mapping)
+
+	fill.Set(kb)
+	check(kb)
+	fill.Set(0)
+}
@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"os"
+	"syscall"
+)
+
+var pageSize = os.Getpagesize()
+
+func roundToPage(bytes int) int {
+	return (bytes + pageSize - 1) / pageSize * pageSize
+}
+
+// adjustMapping resizes an mmap backed fill. Growing beyond the current mapping creates a new,
+// larger mapping. Since untouched pages of anonymous mappings are not resident, mappings grow
+// at least twice as large to avoid copying the buffer on every growth. Shrinking releases the
+// pages past the new size with madvise, while shrinking to zero unmaps the memory entirely
+func (f *Fill) adjustMapping(bytes int) error {
+	oldSize := len(f.buf)
+	if bytes == oldSize {
+		return nil
+	}
+	if bytes <= 0 {
+		if f.mapping != nil {
+			if err := syscall.Munmap(f.mapping); err != nil {
+				return err
+			}
+		}
+		f.mapping = nil
+		f.buf = []byte{}
+		return nil
+	}
+	if bytes < oldSize {
+		if from := roundToPage(bytes); from < oldSize {
+			if err := syscall.Madvise(f.mapping[from:roundToPage(oldSize)], syscall.MADV_DONTNEED); err != nil {
+				return err
+			}
+		}
+		f.buf = f.mapping[:bytes]
+		return nil
+	}
+	if bytes > len(f.mapping) {
+		size := roundToPage(bytes)
+		if size < 2*len(f.mapping) {
+			size = 2 * len(f.mapping)
+		}
+		mapping, err := syscall.Mmap(
+			-1, 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE,
+		)
+		if err != nil {
+			return err
+		}
+		copy(mapping, f.buf)
+		if f.mapping != nil {
+			if err := syscall.Munmap(f.mapping); err != nil {
+				syscall.Munmap(mapping)
+				return err
+			}
+		}
+		f.mapping = mapping
+	}
+	f.buf = f.mapping[:bytes]
+	fillPattern(f.buf, oldSize)
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package memory
+
+// adjustMapping falls back to heap allocation on platforms where mmap backed fills are not supported
+func (f *Fill) adjustMapping(bytes int) error {
+	adjustBuffer(&f.buf, bytes)
+	return nil
+}
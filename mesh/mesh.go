@@ -0,0 +1,191 @@
+package mesh
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bcap/kaller/handler"
+	ptype "github.com/bcap/kaller/plan"
+	srv "github.com/bcap/kaller/server"
+)
+
+// Mesh runs one kaller server per logical service of a plan in the current process, each
+// listening on its own loopback port. This allows multi-service plans to be executed
+// locally without any container orchestration
+//
+// Each server is given the plan host (without port) as its service name, so plan service
+// overrides can be keyed by host. Servers also listen for tcp, udp and grpc calls, each on its
+// own loopback port
+type Mesh struct {
+	// Services maps each plan host to the server simulating it
+	Services map[string]*Service
+	// Client is the server used as the origin of the plan when calling Run
+	Client *Service
+	// Plan is the plan with its hosts rewritten to point to the local servers
+	Plan ptype.Plan
+
+	hosts []string
+}
+
+type Service struct {
+	Host    string
+	Server  *srv.Server
+	Handler *handler.Handler
+
+	// TCPAddress, UDPAddress and GRPCAddress are where the server listens for tcp, udp and grpc
+	// calls
+	TCPAddress  string
+	UDPAddress  string
+	GRPCAddress string
+}
+
+// address returns where the service listens for calls of the given kind
+func (s *Service) address(kind ptype.CallKind) string {
+	switch kind {
+	case ptype.CallKindTCP:
+		return s.TCPAddress
+	case ptype.CallKindUDP:
+		return s.UDPAddress
+	case ptype.CallKindGRPC:
+		return s.GRPCAddress
+	default:
+		return s.Server.AddressString()
+	}
+}
+
+// Up launches a server for each host found in the plan. Servers are shut down when the
+// context is done or when Shutdown is called. Servers only listen in plaintext, so plans with
+// calls made over TLS are rejected
+func Up(ctx context.Context, plan ptype.Plan) (*Mesh, error) {
+	if call := plan.TLSCall(); call != nil {
+		return nil, fmt.Errorf("cannot run call %s in the mesh: mesh servers do not support tls", call.String())
+	}
+	mesh := Mesh{Services: map[string]*Service{}}
+	client, err := launch(ctx, "client")
+	if err != nil {
+		return nil, err
+	}
+	mesh.Client = client
+	for _, host := range plan.Hosts() {
+		service, err := launch(ctx, host)
+		if err != nil {
+			mesh.Shutdown(1 * time.Second)
+			return nil, err
+		}
+		mesh.Services[host] = service
+		mesh.hosts = append(mesh.hosts, host)
+	}
+	rewritten, err := plan.RewriteCalls(func(call *ptype.Call) {
+		if service, ok := mesh.Services[call.Host()]; ok {
+			call.Message().URL.Host = service.address(call.Kind())
+		}
+	})
+	if err != nil {
+		mesh.Shutdown(1 * time.Second)
+		return nil, err
+	}
+	mesh.Plan = rewritten
+	return &mesh, nil
+}
+
+func launch(ctx context.Context, host string) (*Service, error) {
+	server := srv.Server{}
+	if _, err := server.Listen(ctx, "127.0.0.1:0"); err != nil {
+		return nil, fmt.Errorf("cannot launch server for %s: %w", host, err)
+	}
+	tcpAddr, err := server.ListenTCP(ctx, "127.0.0.1:0")
+	if err != nil {
+		server.ShutdownWithTimeout(0)
+		return nil, fmt.Errorf("cannot launch tcp server for %s: %w", host, err)
+	}
+	udpAddr, err := server.ListenUDP(ctx, "127.0.0.1:0")
+	if err != nil {
+		server.ShutdownWithTimeout(0)
+		return nil, fmt.Errorf("cannot launch udp server for %s: %w", host, err)
+	}
+	grpcAddr, err := server.ListenGRPC(ctx, "127.0.0.1:0")
+	if err != nil {
+		server.ShutdownWithTimeout(0)
+		return nil, fmt.Errorf("cannot launch grpc server for %s: %w", host, err)
+	}
+	h := handler.New(ctx)
+	h.ServiceName = strings.Split(host, ":")[0]
+	go serve(host, "http", server.Serve, h)
+	go serve(host, "tcp", server.ServeTCP, h)
+	go serve(host, "udp", server.ServeUDP, h)
+	go serve(host, "grpc", server.ServeGRPC, h)
+	return &Service{
+		Host:        host,
+		Server:      &server,
+		Handler:     h,
+		TCPAddress:  tcpAddr.AddrPort().String(),
+		UDPAddress:  udpAddr.AddrPort().String(),
+		GRPCAddress: grpcAddr.AddrPort().String(),
+	}, nil
+}
+
+// serve runs one of the service servers until it is shut down, logging unexpected failures
+func serve(host string, kind string, serve func(http.Handler) error, handler http.Handler) {
+	if err := serve(handler); !srv.IsClosedError(err) {
+		log.Printf("!! %s server for %s failed: %v", kind, host, err)
+	}
+}
+
+// Hosts returns the plan hosts simulated by this mesh, in order of appearance in the plan
+func (m *Mesh) Hosts() []string {
+	return m.hosts
+}
+
+// Run executes the plan once, with the mesh client as the origin, and waits for all
+// services to finish handling their requests
+func (m *Mesh) Run(ctx context.Context) error {
+	url := fmt.Sprintf("http://%s/run-plan", m.Client.Server.AddressString())
+	req, err := http.NewRequestWithContext(ctx, "POST", url, nil)
+	if err != nil {
+		return err
+	}
+	if err := handler.WritePlanHeaders(req, m.Plan, ""); err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return m.Wait(ctx)
+}
+
+// Outstanding returns the amount of requests being handled across all services
+func (m *Mesh) Outstanding() int32 {
+	outstanding := m.Client.Handler.Outstanding()
+	for _, service := range m.Services {
+		outstanding += service.Handler.Outstanding()
+	}
+	return outstanding
+}
+
+// Wait blocks until no service has requests outstanding or until the context is done, in which
+// case the context error is returned
+func (m *Mesh) Wait(ctx context.Context) error {
+	for m.Outstanding() > 0 {
+		select {
+		case <-time.After(10 * time.Millisecond):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (m *Mesh) Shutdown(timeout time.Duration) {
+	if m.Client != nil {
+		m.Client.Server.ShutdownWithTimeout(timeout)
+	}
+	for _, service := range m.Services {
+		service.Server.ShutdownWithTimeout(timeout)
+	}
+}
@@ -0,0 +1,94 @@
+package mesh
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+var plan1 = `
+execution:
+- call:
+  http: GET svc1/listing 200 0 1024
+  execution:
+  - parallel:
+    concurrency: 2
+    execution:
+    - call:
+      http: GET svc2/product?id=1 200 0 100
+      compute: 10ms
+    - call:
+      http: GET svc2/product?id=2 200 0 100
+      compute: 10ms
+    - call:
+      http: GET svc3:9000/profile 200 0 100
+  post-execution:
+  - call:
+    async: true
+    http: POST svc4/metrics 200 100 0
+`
+
+func TestMesh(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	plan, err := ptype.FromYAML([]byte(strings.TrimSpace(plan1)))
+	require.NoError(t, err)
+
+	mesh, err := Up(ctx, plan)
+	require.NoError(t, err)
+	defer mesh.Shutdown(1 * time.Second)
+
+	assert.Equal(t, []string{"svc1", "svc2", "svc3:9000", "svc4"}, mesh.Hosts())
+	for _, host := range mesh.Plan.Hosts() {
+		assert.True(t, strings.HasPrefix(host, "127.0.0.1:"), "host %s should be rewritten", host)
+	}
+
+	require.NoError(t, mesh.Run(ctx))
+	assert.Equal(t, int64(1), mesh.Client.Handler.Handled())
+	assert.Equal(t, int64(1), mesh.Services["svc1"].Handler.Handled())
+	assert.Equal(t, int64(2), mesh.Services["svc2"].Handler.Handled())
+	assert.Equal(t, int64(1), mesh.Services["svc3:9000"].Handler.Handled())
+	assert.Equal(t, int64(1), mesh.Services["svc4"].Handler.Handled())
+}
+
+var planSockets = `
+execution:
+- call:
+  tcp: svc1:7000/reserve 200 100 100
+  execution:
+  - call:
+    udp: svc2/stats 200
+  - call:
+    grpc: svc3/lookup 404
+`
+
+func TestMeshSockets(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	plan, err := ptype.FromYAML([]byte(strings.TrimSpace(planSockets)))
+	require.NoError(t, err)
+
+	mesh, err := Up(ctx, plan)
+	require.NoError(t, err)
+	defer mesh.Shutdown(1 * time.Second)
+
+	require.NoError(t, mesh.Run(ctx))
+	assert.Equal(t, int64(1), mesh.Services["svc1:7000"].Handler.Handled())
+	assert.Equal(t, int64(1), mesh.Services["svc2"].Handler.Handled())
+	assert.Equal(t, int64(1), mesh.Services["svc3"].Handler.Handled())
+}
+
+func TestMeshRejectsTLS(t *testing.T) {
+	plan, err := ptype.FromYAML([]byte("execution:\n- call:\n  http: GET https://svc1/a 200\n"))
+	require.NoError(t, err)
+	_, err = Up(context.Background(), plan)
+	assert.ErrorContains(t, err, "cannot run call GET https://svc1/a 200 in the mesh: mesh servers do not support tls")
+}
@@ -0,0 +1,173 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+
+	"github.com/bcap/kaller/random"
+	"gopkg.in/yaml.v3"
+)
+
+type BodyKind string
+
+const (
+	BodyAlphanumeric BodyKind = "alphanumeric"
+	BodyJSON         BodyKind = "json"
+	BodyText         BodyKind = "text"
+	BodyBinary       BodyKind = "binary"
+	BodyTemplate     BodyKind = "template"
+)
+
+// BodyFormat describes how generated request or response bodies should look like:
+//   - alphanumeric: random ASCII letters and digits. This is the default. See random.String
+//   - json: a random JSON document with objects nested up to Depth levels. See random.JSON
+//   - text: highly compressible text made of a small set of words. See random.Text
+//   - binary: random bytes, which are essentially incompressible. See random.Binary
+//   - template: the fixed Template text with its fills replaced by random values. Because the
+//     template defines the body, bodies are generated even if no body size is set.
+//     See random.Template
+//
+// Each kind sets its own Content-Type, which can be changed with ContentType or by
+// explicitly setting the header in the request or response headers
+//
+// In yaml the format can be written in the compact form of just its kind, or json(<depth>)
+type BodyFormat struct {
+	Kind        BodyKind `json:"kind" yaml:"kind"`
+	Depth       int      `json:"depth,omitempty" yaml:"depth,omitempty"`
+	Template    string   `json:"template,omitempty" yaml:"template,omitempty"`
+	ContentType string   `json:"content-type,omitempty" yaml:"content-type,omitempty"`
+
+	// template is the parsed Template. It is parsed once when the format is decoded, so
+	// generating bodies does not need to parse it again
+	template *random.Template
+}
+
+func (f *BodyFormat) kind() BodyKind {
+	if f == nil || f.Kind == "" {
+		return BodyAlphanumeric
+	}
+	return f.Kind
+}
+
+func (f *BodyFormat) Validate() error {
+	_, err := f.parse()
+	return err
+}
+
+// prepare validates the format and keeps its parsed template. It must only be called while
+// the format is not shared yet, like when decoding it
+func (f *BodyFormat) prepare() error {
+	template, err := f.parse()
+	if err != nil {
+		return err
+	}
+	f.template = template
+	return nil
+}
+
+// parse validates the format and parses its template, if it is a template format
+func (f *BodyFormat) parse() (*random.Template, error) {
+	switch f.kind() {
+	case BodyAlphanumeric, BodyText, BodyBinary:
+	case BodyJSON:
+		if f.Depth < 0 {
+			return nil, fmt.Errorf("invalid body format: negative json depth %d", f.Depth)
+		}
+	case BodyTemplate:
+		if f.template != nil {
+			return f.template, nil
+		}
+		if f.Template == "" {
+			return nil, fmt.Errorf("invalid body format: template kind requires a template")
+		}
+		template, err := random.ParseTemplate(f.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid body format: %w", err)
+		}
+		return template, nil
+	default:
+		return nil, fmt.Errorf("invalid body format: unknown kind %q", f.Kind)
+	}
+	return nil, nil
+}
+
+// ContentTypeHeader returns the Content-Type header value bodies of this format should be sent with
+func (f *BodyFormat) ContentTypeHeader() string {
+	if f != nil && f.ContentType != "" {
+		return f.ContentType
+	}
+	switch f.kind() {
+	case BodyJSON:
+		return "application/json"
+	case BodyBinary:
+		return "application/octet-stream"
+	default:
+		return "text/plain; charset=utf-8"
+	}
+}
+
+// Generate generates a body of the given size. The size is ignored for templates. Returns
+// nil if no body should be generated
+func (f *BodyFormat) Generate(rnd *rand.Rand, size int) []byte {
+	kind := f.kind()
+	if size <= 0 && kind != BodyTemplate {
+		return nil
+	}
+	switch kind {
+	case BodyJSON:
+		return random.JSON(rnd, size, f.Depth)
+	case BodyText:
+		return random.Text(rnd, size)
+	case BodyBinary:
+		return random.Binary(rnd, size)
+	case BodyTemplate:
+		template, err := f.parse()
+		if err != nil {
+			return nil
+		}
+		return template.Execute(rnd)
+	default:
+		return []byte(random.String(rnd, size))
+	}
+}
+
+// The regex pattern used to parse compact body formats
+const BodyFormatPattern = `^(\w+)(?:\((\d+)\))?$`
+
+var bodyFormatPattern = regexp.MustCompile(BodyFormatPattern)
+
+func (f *BodyFormat) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value != "" {
+		parts := bodyFormatPattern.FindStringSubmatch(node.Value)
+		if parts == nil {
+			return fmt.Errorf("invalid body format at line %d: cannot parse %q", node.Line, node.Value)
+		}
+		*f = BodyFormat{Kind: BodyKind(parts[1])}
+		if parts[2] != "" {
+			if f.Kind != BodyJSON {
+				return fmt.Errorf("invalid body format at line %d: only json accepts a depth", node.Line)
+			}
+			f.Depth, _ = strconv.Atoi(parts[2])
+		}
+	} else {
+		type rawBodyFormat BodyFormat
+		if err := node.Decode((*rawBodyFormat)(f)); err != nil {
+			return err
+		}
+	}
+	if err := f.prepare(); err != nil {
+		return fmt.Errorf("invalid body format at line %d: %w", node.Line, err)
+	}
+	return nil
+}
+
+func (f *BodyFormat) UnmarshalJSON(data []byte) error {
+	type rawBodyFormat BodyFormat
+	if err := json.Unmarshal(data, (*rawBodyFormat)(f)); err != nil {
+		return err
+	}
+	return f.prepare()
+}
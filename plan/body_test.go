@@ -0,0 +1,85 @@
+package plan
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bcap/kaller/random"
+)
+
+var bodyFormats = `
+execution:
+- call:
+    http:
+      method: POST
+      url: service-a/x
+      gen-request-body: 1000
+      gen-request-body-format: json(3)
+      gen-response-body: 2000
+      gen-response-body-format: binary
+- call:
+    http:
+      method: GET
+      url: service-b/y
+      gen-response-body-format:
+        kind: template
+        template: '{"id": {{int}}, "name": "{{string 10}}"}'
+        content-type: application/json
+- call:
+    http: GET service-c/z 200 0 100
+`
+
+func TestBodyFormat(t *testing.T) {
+	plan := load(t, bodyFormats)
+	require.Equal(t, 3, len(plan.Execution))
+	rnd := random.New(1)
+
+	first := plan.Execution[0].(*Call).HTTP
+	assert.Equal(t, &BodyFormat{Kind: BodyJSON, Depth: 3}, first.GenRequestBodyFormat)
+	assert.Equal(t, &BodyFormat{Kind: BodyBinary}, first.GenResponseBodyFormat)
+	body, contentType := first.GetRequestBody(rnd)
+	assert.Equal(t, 1000, len(body))
+	assert.True(t, json.Valid(body))
+	assert.Equal(t, "application/json", contentType)
+	body, contentType = first.GetResponseBody(rnd)
+	assert.Equal(t, 2000, len(body))
+	assert.Equal(t, "application/octet-stream", contentType)
+
+	second := plan.Execution[1].(*Call).HTTP
+	body, contentType = second.GetResponseBody(rnd)
+	assert.Regexp(t, `^{"id": \d+, "name": "\w{10}"}$`, string(body))
+	assert.Equal(t, "application/json", contentType)
+	body, contentType = second.GetRequestBody(rnd)
+	assert.Nil(t, body)
+	assert.Equal(t, "", contentType)
+
+	third := plan.Execution[2].(*Call).HTTP
+	body, contentType = third.GetResponseBody(rnd)
+	assert.Regexp(t, `^[a-zA-Z0-9]{100}$`, string(body))
+	assert.Equal(t, "text/plain; charset=utf-8", contentType)
+
+	encoded, err := plan.ToYAML()
+	require.NoError(t, err)
+	decoded, err := FromYAML(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	encoded, err = plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err = FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	for _, invalid := range []string{
+		"gen-response-body-format: xml",
+		"gen-response-body-format: binary(3)",
+		"gen-response-body-format: template",
+		"gen-response-body-format: {kind: template, template: '{{nope}}'}",
+	} {
+		_, err := FromYAML([]byte("execution:\n- call:\n    http:\n      url: a/b\n      " + invalid + "\n"))
+		assert.Error(t, err, invalid)
+	}
+}
@@ -1,5 +1,10 @@
 package plan
 
+import (
+	"net"
+	"strconv"
+)
+
 type CallType string
 
 const (
@@ -7,6 +12,33 @@ const (
 	CallTypeAsync CallType = "async"
 )
 
+// CallKind is the transport a call is made over
+type CallKind string
+
+const (
+	CallKindHTTP      CallKind = "http"
+	CallKindTCP       CallKind = "tcp"
+	CallKindUDP       CallKind = "udp"
+	CallKindGRPC      CallKind = "grpc"
+	CallKindWebSocket CallKind = "websocket"
+	CallKindSSE       CallKind = "sse"
+)
+
+// DefaultPort returns the port calls of this kind target when their url has no port. Calls
+// made over http default to 443 when the url scheme is secure (https or wss) and to 80 otherwise
+func (k CallKind) DefaultPort(scheme string) int {
+	switch k {
+	case CallKindTCP, CallKindUDP:
+		return DefaultSocketPort
+	case CallKindGRPC:
+		return DefaultGRPCPort
+	}
+	if scheme == "https" || scheme == "wss" {
+		return 443
+	}
+	return 80
+}
+
 // Call represents that a service call should be invoked and how that service should
 // process it
 //
@@ -25,10 +57,17 @@ const (
 // will wait for the call result before moving to the next step. In async calls the client will
 // not wait for the call result and move to the next step.
 //
-// NOTE: As of now only HTTP calls are supported
+// Calls are made over HTTP by default. Setting TCP, UDP or GRPC makes the call over a raw TCP
+// connection, UDP datagrams or gRPC instead. See Socket for more. Setting WebSocket or SSE opens
+// a long-lived stream exchanging multiple messages instead. See Stream for more
 type Call struct {
 	Async         bool      `json:"async" yaml:"async"`
 	HTTP          HTTP      `json:"http,omitempty" yaml:"http,omitempty"`
+	TCP           *Socket   `json:"tcp,omitempty" yaml:"tcp,omitempty"`
+	UDP           *Socket   `json:"udp,omitempty" yaml:"udp,omitempty"`
+	GRPC          *Socket   `json:"grpc,omitempty" yaml:"grpc,omitempty"`
+	WebSocket     *Stream   `json:"websocket,omitempty" yaml:"websocket,omitempty"`
+	SSE           *Stream   `json:"sse,omitempty" yaml:"sse,omitempty"`
 	Compute       Compute   `json:"compute,omitempty" yaml:"compute,omitempty"`
 	Execution     Execution `json:"execution,omitempty" yaml:"execution,omitempty"`
 	PostExecution Execution `json:"post-execution,omitempty" yaml:"post-execution,omitempty"`
@@ -37,3 +76,113 @@ type Call struct {
 func (Call) StepType() StepType {
 	return StepTypeCall
 }
+
+// Kind returns the transport the call is made over
+func (c *Call) Kind() CallKind {
+	switch {
+	case c.TCP != nil:
+		return CallKindTCP
+	case c.UDP != nil:
+		return CallKindUDP
+	case c.GRPC != nil:
+		return CallKindGRPC
+	case c.WebSocket != nil:
+		return CallKindWebSocket
+	case c.SSE != nil:
+		return CallKindSSE
+	default:
+		return CallKindHTTP
+	}
+}
+
+// Stream returns the call stream for WebSocket and SSE calls, or nil for other calls
+func (c *Call) Stream() *Stream {
+	switch c.Kind() {
+	case CallKindWebSocket:
+		return c.WebSocket
+	case CallKindSSE:
+		return c.SSE
+	default:
+		return nil
+	}
+}
+
+// Socket returns the call socket for TCP, UDP and gRPC calls, or nil for other calls
+func (c *Call) Socket() *Socket {
+	switch c.Kind() {
+	case CallKindTCP:
+		return c.TCP
+	case CallKindUDP:
+		return c.UDP
+	case CallKindGRPC:
+		return c.GRPC
+	default:
+		return nil
+	}
+}
+
+// Message returns the description of the call request and response, whatever transport the
+// call is made over
+func (c *Call) Message() *HTTP {
+	if stream := c.Stream(); stream != nil {
+		return &stream.HTTP
+	}
+	if socket := c.Socket(); socket != nil {
+		return &socket.HTTP
+	}
+	return &c.HTTP
+}
+
+// Address returns the host and port to connect to, using the default port of the call kind and
+// url scheme when the call url has no port
+func (c *Call) Address() string {
+	url := c.Message().URL
+	if url.URL == nil {
+		return ""
+	}
+	if url.Port() != "" {
+		return url.Host
+	}
+	return net.JoinHostPort(url.Hostname(), strconv.Itoa(c.Kind().DefaultPort(url.Scheme)))
+}
+
+func (c *Call) String() string {
+	if stream := c.Stream(); stream != nil {
+		return string(c.Kind()) + " " + stream.String()
+	}
+	if socket := c.Socket(); socket != nil {
+		return string(c.Kind()) + " " + socket.String()
+	}
+	return c.HTTP.String()
+}
+
+// Validate checks the transport settings of the call, like the protocol matching the url
+// scheme. TCP, UDP and gRPC calls do not support the protocol and tls settings (see Socket)
+func (c *Call) Validate() error {
+	if socket := c.Socket(); socket != nil {
+		return socket.Validate()
+	}
+	return c.Message().Validate()
+}
+
+// UsesTLS returns whether the call is made over TLS, which is the case for secure url schemes
+// (https or wss) and for calls with TLS options
+func (c *Call) UsesTLS() bool {
+	message := c.Message()
+	if message.TLS != nil {
+		return true
+	}
+	if message.URL.URL == nil {
+		return false
+	}
+	return message.URL.Scheme == "https" || message.URL.Scheme == "wss"
+}
+
+// Host returns the host (and port, if any) this call targets
+func (c *Call) Host() string {
+	url := c.Message().URL
+	if url.URL == nil {
+		return ""
+	}
+	return url.Host
+}
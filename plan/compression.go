@@ -0,0 +1,115 @@
+package plan
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// Compression describes how the response body of a call should be compressed
+//
+// The server compresses the response with the first of Encodings the request accepts, as
+// advertised in its Accept-Encoding header. If none is accepted, the response is sent
+// uncompressed. When calling a service with compression set, the caller advertises Encodings
+// in the Accept-Encoding header and then decompresses and verifies the response body
+//
+// Available encodings are gzip and br (brotli), which are both used by default. Level
+// controls the compression level of the chosen encoding (1 to 9 for gzip, 0 to 11 for br).
+// A zero Level means the encoding default level
+//
+// In yaml compression can be written in the compact form of a comma separated list of
+// encodings, optionally followed by the level. Eg: "gzip", "br, gzip 5"
+type Compression struct {
+	Encodings []string `json:"encodings,omitempty" yaml:"encodings,omitempty"`
+	Level     int      `json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+// AcceptedEncodings returns the encodings, in order of preference
+func (c *Compression) AcceptedEncodings() []string {
+	if len(c.Encodings) == 0 {
+		return []string{EncodingBrotli, EncodingGzip}
+	}
+	return c.Encodings
+}
+
+// Negotiate picks the encoding to use given the request Accept-Encoding header value. Returns
+// an empty string if the response should not be compressed
+func (c *Compression) Negotiate(acceptEncoding string) string {
+	accepted := map[string]bool{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		params := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				quality, _ = strconv.ParseFloat(param[2:], 64)
+			}
+		}
+		accepted[name] = quality > 0
+	}
+	for _, encoding := range c.AcceptedEncodings() {
+		if ok, found := accepted[encoding]; ok || (!found && accepted["*"]) {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func (c *Compression) Validate() error {
+	for _, encoding := range c.AcceptedEncodings() {
+		var min, max int
+		switch encoding {
+		case EncodingGzip:
+			min, max = 1, 9
+		case EncodingBrotli:
+			min, max = 0, 11
+		default:
+			return fmt.Errorf("invalid compression: unknown encoding %q", encoding)
+		}
+		if c.Level != 0 && (c.Level < min || c.Level > max) {
+			return fmt.Errorf("invalid compression: level %d out of the %d to %d range for %s", c.Level, min, max, encoding)
+		}
+	}
+	return nil
+}
+
+// The regex pattern used to parse compact compression definitions
+const CompressionPattern = `^([\w\s,]*?)(?:\s+(\d+))?$`
+
+var compressionPattern = regexp.MustCompile(CompressionPattern)
+
+func (c *Compression) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value != "" {
+		parts := compressionPattern.FindStringSubmatch(strings.TrimSpace(node.Value))
+		if parts == nil {
+			return fmt.Errorf("invalid compression at line %d: cannot parse %q", node.Line, node.Value)
+		}
+		*c = Compression{}
+		for _, encoding := range strings.Split(parts[1], ",") {
+			if encoding = strings.TrimSpace(encoding); encoding != "" {
+				c.Encodings = append(c.Encodings, encoding)
+			}
+		}
+		if parts[2] != "" {
+			c.Level, _ = strconv.Atoi(parts[2])
+		}
+	} else {
+		type rawCompression Compression
+		if err := node.Decode((*rawCompression)(c)); err != nil {
+			return err
+		}
+	}
+	if err := c.Validate(); err != nil {
+		return fmt.Errorf("invalid compression at line %d: %w", node.Line, err)
+	}
+	return nil
+}
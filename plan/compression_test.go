@@ -0,0 +1,42 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestCompressionParse(t *testing.T) {
+	tests := map[string]Compression{
+		"gzip":                          {Encodings: []string{"gzip"}},
+		"br, gzip 5":                    {Encodings: []string{"br", "gzip"}, Level: 5},
+		"{encodings: [gzip], level: 1}": {Encodings: []string{"gzip"}, Level: 1},
+		"{level: 3}":                    {Level: 3},
+	}
+	for input, expected := range tests {
+		var compression Compression
+		require.NoError(t, yaml.Unmarshal([]byte(input), &compression), input)
+		assert.Equal(t, expected, compression, input)
+	}
+
+	for _, input := range []string{"zstd", "gzip 10", "br 12", "{encodings: [deflate]}"} {
+		var compression Compression
+		assert.Error(t, yaml.Unmarshal([]byte(input), &compression), input)
+	}
+}
+
+func TestCompressionNegotiate(t *testing.T) {
+	both := Compression{}
+	gzip := Compression{Encodings: []string{EncodingGzip}}
+
+	assert.Equal(t, "br", both.Negotiate("gzip, deflate, br"))
+	assert.Equal(t, "gzip", both.Negotiate("gzip"))
+	assert.Equal(t, "gzip", both.Negotiate("br;q=0, gzip;q=0.5"))
+	assert.Equal(t, "br", both.Negotiate("*"))
+	assert.Equal(t, "gzip", both.Negotiate("br;q=0, *"))
+	assert.Equal(t, "", both.Negotiate(""))
+	assert.Equal(t, "", both.Negotiate("identity"))
+	assert.Equal(t, "", gzip.Negotiate("br"))
+}
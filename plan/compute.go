@@ -3,12 +3,17 @@ package plan
 import (
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/bcap/kaller/kernel"
 	"github.com/bcap/kaller/memory"
 	"gopkg.in/yaml.v3"
 )
@@ -18,29 +23,93 @@ import (
 // The time it will take to simulate the computation can be defined in 2 different ways:
 //   - A fixed amount of time if only Min is defined, or if both Min and Max have the same value
 //   - A random time will be chosen between Min and Max if both have different values
+//   - A random time will be picked from a Distribution, if one is defined. In this case Min and
+//     Max, when set, bound the picked time. See Distribution for the available distributions
 //
 // To generate CPU load, a CPU float parameter can be passed to Compute in order:
 //   - A CPU value of 0.0 means no cpu usage. Compute will only sleep
 //   - A CPU value of 1.0 means that we should load a single core completely
 //   - A CPU value of 2.3 means that we should load 2 cores completely and 30% of a 3rd core
 //
+// By default the CPU is loaded by busy looping, which exercises little besides the clock. A Kernel
+// can be picked so the CPU is loaded with work that resembles real applications, like hashing,
+// JSON encoding, regex matching, compression or pointer chasing. See the kernel package for more
+//
 // To simulate memory usage, a MemoryDeltaKB parameter can be passed. The parameter can be either
 // positive (memory allocated) as well negative (memory fred).
 //
-// NOTE: While Compute simulates memory usage (amount of allocated bytes), it does *not* simulate
-// memory pressure (constantly accessing memory and potentially causing paging)
+// Memory held by computes is by default scoped to the request being handled, being freed once
+// the request is done. With a MemoryScope of process, memory is instead held by the whole
+// process and kept across requests, which simulates a memory leak when MemoryDeltaKB is
+// positive. MemoryCapKB limits how much memory can be held: once reached memory stops growing
+// or, if MemoryCapReset is set, all held memory is released (like a cache being flushed)
+//
+// Memory is by default allocated in the Go heap, which means the garbage collector has to deal
+// with it. Setting MemoryBacking to mmap allocates memory in anonymous memory mappings instead,
+// so simulating memory usage does not affect GC pause times. See memory.Backing for more
+//
+// To simulate garbage collection pressure, an AllocRate can be passed. During the compute,
+// short-lived objects are then allocated at that rate, with sizes picked from the AllocSize
+// distribution (1kb objects by default). Sizes are capped at the amount of bytes the compute
+// allocates in total and at memory.MaxChurnObjectSize. See memory.Churn for more
+//
+// To simulate memory pressure (constantly accessing memory and potentially causing cache misses
+// and paging), a MemoryTouch rate can be passed. During the compute the held memory is then
+// repeatedly read and written at that rate, touching one byte every MemoryTouchStride bytes
+// (64 bytes, a cache line, by default). See memory.Fill.Touch for more
 type Compute struct {
-	Min           time.Duration `json:"min" yaml:"min"`
-	Max           time.Duration `json:"max" yaml:"max"`
-	CPU           float64       `json:"cpu" yaml:"cpu"`
-	MemoryDeltaKB int           `json:"memory-delta-kb" yaml:"memory-delta-kb"`
+	Min               time.Duration `json:"min" yaml:"min"`
+	Max               time.Duration `json:"max" yaml:"max"`
+	Distribution      *Distribution `json:"distribution,omitempty" yaml:"distribution,omitempty"`
+	CPU               float64       `json:"cpu" yaml:"cpu"`
+	Kernel            kernel.Kind   `json:"kernel,omitempty" yaml:"kernel,omitempty"`
+	MemoryDeltaKB     int           `json:"memory-delta-kb" yaml:"memory-delta-kb"`
+	MemoryScope       MemoryScope   `json:"memory-scope,omitempty" yaml:"memory-scope,omitempty"`
+	MemoryCapKB       int           `json:"memory-cap-kb,omitempty" yaml:"memory-cap-kb,omitempty"`
+	MemoryCapReset    bool          `json:"memory-cap-reset,omitempty" yaml:"memory-cap-reset,omitempty"`
+	MemoryBacking     MemoryBacking `json:"memory-backing,omitempty" yaml:"memory-backing,omitempty"`
+	AllocRate         ByteRate      `json:"alloc-rate,omitempty" yaml:"alloc-rate,omitempty"`
+	AllocSize         *Distribution `json:"alloc-size,omitempty" yaml:"alloc-size,omitempty"`
+	MemoryTouch       ByteRate      `json:"memory-touch,omitempty" yaml:"memory-touch,omitempty"`
+	MemoryTouchStride int           `json:"memory-touch-stride,omitempty" yaml:"memory-touch-stride,omitempty"`
+}
+
+// MaxCPU caps the cpu load of computes. It defaults to the number of cores, but can be lowered
+// to the cpus actually available to the process, like its cgroup cpu quota (see cgroup.EffectiveCPU)
+var MaxCPU = float64(runtime.NumCPU())
+
+// CPUUsage compares the cpu time a compute requested (its CPU load times its duration) with the
+// cpu time it actually got. Actual cpu time is lower than requested when the compute is capped
+// by MaxCPU or when the process is throttled, like when exceeding its cgroup cpu quota. Measured
+// tells whether the actual cpu time could be measured, which is only supported on linux
+type CPUUsage struct {
+	Requested time.Duration
+	Actual    time.Duration
+	Measured  bool
 }
 
+type MemoryScope string
+
+const (
+	MemoryScopeRequest MemoryScope = "request"
+	MemoryScopeProcess MemoryScope = "process"
+)
+
+type MemoryBacking string
+
+const (
+	MemoryBackingHeap MemoryBacking = "heap"
+	MemoryBackingMmap MemoryBacking = "mmap"
+)
+
 func (Compute) StepType() StepType {
 	return StepTypeCompute
 }
 
 func (d *Compute) String() string {
+	if d.Distribution != nil {
+		return d.Distribution.String()
+	}
 	if d.Min == d.Max {
 		return d.Min.String()
 	}
@@ -48,60 +117,150 @@ func (d *Compute) String() string {
 }
 
 func (d Compute) IsZero() bool {
-	return d.Min == 0 && d.Max == 0
+	return d.Min == 0 && d.Max == 0 && d.Distribution == nil
 }
 
-func (d Compute) Validate() error {
-	if d.IsZero() {
-		return nil
+// Bounds returns the shortest and longest times the compute is expected to take
+func (d Compute) Bounds() (time.Duration, time.Duration) {
+	if d.Distribution == nil {
+		if d.Max > d.Min {
+			return d.Min, d.Max
+		}
+		return d.Min, d.Min
+	}
+	min, max := d.Distribution.Bounds()
+	return d.clamp(time.Duration(min)), d.clamp(time.Duration(max))
+}
+
+// clamp limits the duration to the Min and Max range, when those are set
+func (d Compute) clamp(duration time.Duration) time.Duration {
+	if duration < d.Min {
+		duration = d.Min
 	}
+	if d.Max > 0 && duration > d.Max {
+		duration = d.Max
+	}
+	return duration
+}
+
+func (d Compute) Validate() error {
 	if d.Min < 0 || d.Max < 0 {
 		return fmt.Errorf("invalid compute: min and/or max are negative (min: %v, max: %v)", d.Min, d.Max)
 	}
 	if d.Min > d.Max && d.Max > 0 {
 		return fmt.Errorf("invalid compute: min is higher than max (min: %v, max: %v)", d.Min, d.Max)
 	}
+	if d.Distribution != nil {
+		if err := d.Distribution.Validate(); err != nil {
+			return fmt.Errorf("invalid compute: %w", err)
+		}
+	}
+	if err := kernel.Validate(d.Kernel); err != nil {
+		return fmt.Errorf("invalid compute: %w", err)
+	}
+	switch d.MemoryScope {
+	case "", MemoryScopeRequest, MemoryScopeProcess:
+	default:
+		return fmt.Errorf("invalid compute: unknown memory scope %q", d.MemoryScope)
+	}
+	switch d.MemoryBacking {
+	case "", MemoryBackingHeap, MemoryBackingMmap:
+	default:
+		return fmt.Errorf("invalid compute: unknown memory backing %q", d.MemoryBacking)
+	}
+	if d.MemoryCapKB < 0 {
+		return fmt.Errorf("invalid compute: memory cap is negative (cap: %dkb)", d.MemoryCapKB)
+	}
+	if d.AllocRate < 0 {
+		return fmt.Errorf("invalid compute: alloc rate is negative (rate: %v)", d.AllocRate)
+	}
+	if d.AllocSize != nil {
+		if err := d.AllocSize.Validate(); err != nil {
+			return fmt.Errorf("invalid compute alloc size: %w", err)
+		}
+	}
+	if d.MemoryTouch < 0 || d.MemoryTouchStride < 0 {
+		return fmt.Errorf("invalid compute: memory touch rate and/or stride are negative (rate: %v, stride: %d)", d.MemoryTouch, d.MemoryTouchStride)
+	}
 	return nil
 }
 
-var computeRand = rand.New(rand.NewSource(time.Now().UnixNano()))
-
-func (d Compute) Do(ctx context.Context, fill *memory.Fill) {
+// Do runs the compute. The given random generator is used to pick the compute duration
+// and must be safe for concurrent use (see random.New). The given fill must match the
+// compute MemoryScope and MemoryBacking
+//
+// Returns how much cpu time the compute requested and how much it actually got
+func (d Compute) Do(ctx context.Context, fill *memory.Fill, rnd *rand.Rand) CPUUsage {
 	if err := d.Validate(); err != nil {
-		return
+		return CPUUsage{}
 	}
-	if d.IsZero() {
-		return
+	// memory can still be adjusted by computes that take no time
+	if d.IsZero() && d.MemoryDeltaKB == 0 {
+		return CPUUsage{}
 	}
 	duration := d.Min
-	if d.Min < d.Max {
+	if d.Distribution != nil {
+		duration = d.clamp(time.Duration(d.Distribution.Sample(rnd)))
+	} else if d.Min < d.Max {
 		delta := int64(d.Max - d.Min)
-		duration = d.Min + time.Duration(computeRand.Int63n(delta))
+		duration = d.Min + time.Duration(rnd.Int63n(delta))
 	}
 
-	d.do(ctx, duration, fill)
+	return d.do(ctx, duration, fill, rnd)
 }
 
-func (d Compute) do(ctx context.Context, duration time.Duration, fill *memory.Fill) {
+func (d Compute) do(ctx context.Context, duration time.Duration, fill *memory.Fill, rnd *rand.Rand) CPUUsage {
 	start := time.Now()
 	d.memory(fill)
-	d.compute(ctx, duration-time.Since(start))
+	remaining := duration - time.Since(start)
+	// memory is touched and churned alongside the cpu load, and the compute is only done once
+	// all of them are
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+	if d.MemoryTouch > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fill.Touch(ctx, remaining, int64(d.MemoryTouch), d.MemoryTouchStride)
+		}()
+	}
+	if d.AllocRate > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			memory.Churn(ctx, remaining, int64(d.AllocRate), d.allocSize(rnd))
+		}()
+	}
+	return d.compute(ctx, remaining)
+}
+
+// allocSize returns a function picking the size of objects allocated when churning memory
+func (d Compute) allocSize(rnd *rand.Rand) func() int {
+	if d.AllocSize == nil {
+		return func() int { return 1024 }
+	}
+	return func() int { return int(d.AllocSize.Sample(rnd)) }
 }
 
 func (d Compute) memory(fill *memory.Fill) {
 	if d.MemoryDeltaKB != 0 {
-		fill.Add(d.MemoryDeltaKB * 1024)
+		fill.AddCapped(d.MemoryDeltaKB*1024, d.MemoryCapKB*1024, d.MemoryCapReset)
 	}
 }
 
-func (d Compute) compute(ctx context.Context, duration time.Duration) {
+func (d Compute) compute(ctx context.Context, duration time.Duration) CPUUsage {
+	usage := CPUUsage{Requested: time.Duration(d.CPU * float64(duration))}
+	var actual int64
+	var unmeasured int32
+	wg := sync.WaitGroup{}
 	if d.CPU > 0.0 {
 		start := time.Now()
-		// Compute.CPU is capped at the number of cores.
+		// Compute.CPU is capped at MaxCPU, the number of cores by default.
 		// For instance if Compute.CPU is 8.5 on a 4 core system, effectivelly
 		// a load of CPU 4.0 will be generated
-		for cpu := 0; cpu < runtime.NumCPU(); cpu++ {
-			ratio := d.CPU - float64(cpu)
+		load := math.Min(d.CPU, MaxCPU)
+		for cpu := 0; float64(cpu) < load; cpu++ {
+			ratio := load - float64(cpu)
 			if ratio <= 0.0 {
 				break
 			}
@@ -114,12 +273,25 @@ func (d Compute) compute(ctx context.Context, duration time.Duration) {
 			}
 			toRun := time.Duration(float64(workUnit) * ratio)
 			toSleep := workUnit - toRun
+			wg.Add(1)
 			go func() {
+				defer wg.Done()
 				runtime.LockOSThread()
+				cpuStart, measured := threadCPUTime()
+				defer func() {
+					cpuEnd, _ := threadCPUTime()
+					atomic.AddInt64(&actual, int64(cpuEnd-cpuStart))
+					if !measured {
+						atomic.StoreInt32(&unmeasured, 1)
+					}
+				}()
+				work, _ := kernel.Acquire(d.Kernel)
+				defer kernel.Release(d.Kernel, work)
 				for {
 					unitStart := time.Now()
 					// this tight loop should take 100% of a core
 					for {
+						work.Work()
 						if time.Since(start) >= duration {
 							return
 						} else if time.Since(unitStart) >= toRun {
@@ -140,20 +312,26 @@ func (d Compute) compute(ctx context.Context, duration time.Duration) {
 	case <-time.After(duration):
 	case <-ctx.Done():
 	}
+	wg.Wait()
+	usage.Actual = time.Duration(actual)
+	usage.Measured = unmeasured == 0
+	return usage
 }
 
 const ComputePattern = `` +
-	// Min
-	`(\w+)` +
+	// Min or a distribution (see DistributionPattern)
+	`(\w+(?:\([^)]*\))?)` +
 	// Optional Max
 	`(?:\s+to\s+(\w+))?` +
-	// Optional CPU
-	`(?:\s+([\d\.]+)\s*cpu)?` +
+	// Optional CPU, with an optional kernel
+	`(?:\s+([\d\.]+)\s*cpu(?:\(([\w-]+)\))?)?` +
 	// Optional Memory delta
 	`(?:\s+(` +
 	`((?:-|\+)[\d\.]+)` + // signal (+ or -) plus numeric value
 	`((?:k|m)b)` + // unit: kb or mb
-	`))?`
+	`))?` +
+	// Optional memory touch rate
+	`(?:\s+touch\s+([\d\.]+(?:b|kb|mb|gb)/s))?`
 
 var computePattern = regexp.MustCompile(ComputePattern)
 
@@ -164,16 +342,33 @@ var computePattern = regexp.MustCompile(ComputePattern)
 //   - "10ms to 100ms 1.5 cpu" creates a Compute with that will run for 10ms to 100ms and will use 1.3 cores (load 1 core by 100% and another one by 30%)
 //   - "10ms +10mb" creates a Compute that will run for 10ms and increase memory usage by 10mb
 //   - "10ms to 50ms 1.3 cpu -100kb" creates a Compute that will run for 10ms to 50ms, use 1.3 cpus and decrease memory usage by 100kb
+//   - "10ms 1 cpu(sha256)" creates a Compute that will run for 10ms loading a core completely by hashing data
+//   - "50ms +100mb touch 1gb/s" creates a Compute that will run for 50ms, increase memory usage by 100mb and touch the held memory at 1gb/s
+//   - "lognormal(20ms, 0.5) 0.5 cpu" creates a Compute with a log-normally distributed time with median 20ms, using half a core
+//   - "empirical(p50=10ms, p99=200ms)" creates a Compute with times following the given percentiles
 func (d *Compute) Parse(s string) error {
 	parts := computePattern.FindStringSubmatch(s)
 	if parts == nil {
 		return fmt.Errorf("cannot parse compute definition %q", s)
 	}
-	min, err := time.ParseDuration(parts[1])
-	if err != nil {
-		return fmt.Errorf("invalid compute time %q: %w", parts[1], err)
+	var min, max time.Duration
+	var distribution *Distribution
+	var err error
+	if strings.Contains(parts[1], "(") {
+		if parts[2] != "" {
+			return fmt.Errorf("invalid compute definition %q: distributions cannot have a max time", s)
+		}
+		distribution, err = ParseDistribution(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid compute distribution %q: %w", parts[1], err)
+		}
+	} else {
+		min, err = time.ParseDuration(parts[1])
+		if err != nil {
+			return fmt.Errorf("invalid compute time %q: %w", parts[1], err)
+		}
+		max = min
 	}
-	max := min
 	if parts[2] != "" {
 		max, err = time.ParseDuration(parts[2])
 		if err != nil {
@@ -187,25 +382,40 @@ func (d *Compute) Parse(s string) error {
 			return fmt.Errorf("invalid compute cpu %q: %w", parts[3], err)
 		}
 	}
+	kernelKind := kernel.Kind(parts[4])
+	if err := kernel.Validate(kernelKind); err != nil {
+		return fmt.Errorf("invalid compute kernel %q: %w", parts[4], err)
+	}
 	memDelta := 0
-	if parts[4] != "" {
-		memDelta, err = strconv.Atoi(parts[5])
+	if parts[5] != "" {
+		memDelta, err = strconv.Atoi(parts[6])
 		if err != nil {
-			return fmt.Errorf("invalid memory delta %q: %w", parts[4], err)
+			return fmt.Errorf("invalid memory delta %q: %w", parts[5], err)
 		}
-		unit := parts[6]
+		unit := parts[7]
 		switch unit {
 		case "kb":
 		case "mb":
 			memDelta *= 1024
 		default:
-			return fmt.Errorf("invalid memory delta %q: %w", parts[4], err)
+			return fmt.Errorf("invalid memory delta %q: %w", parts[5], err)
+		}
+	}
+	var touch ByteRate
+	if parts[8] != "" {
+		touch, err = ParseByteRate(parts[8])
+		if err != nil {
+			return fmt.Errorf("invalid memory touch rate %q: %w", parts[8], err)
 		}
 	}
 	d.Min = min
 	d.Max = max
+	d.Distribution = distribution
 	d.CPU = cpu
+	d.Kernel = kernelKind
 	d.MemoryDeltaKB = memDelta
+	d.MemoryTouch = touch
+	d.MemoryTouchStride = 0
 	return nil
 }
 
@@ -2,10 +2,13 @@ package plan
 
 import (
 	"context"
+	"runtime"
 	"testing"
 	"time"
 
+	"github.com/bcap/kaller/kernel"
 	"github.com/bcap/kaller/memory"
+	"github.com/bcap/kaller/random"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,9 +17,90 @@ func TestCompute(t *testing.T) {
 	compute := Compute{CPU: 1.8, Min: duration, Max: duration, MemoryDeltaKB: 1024}
 	start := time.Now()
 	fill := memory.Fill{}
-	compute.Do(context.Background(), &fill)
+	compute.Do(context.Background(), &fill, random.New(1))
 	timeTaken := time.Since(start)
 	assert.Greater(t, timeTaken, duration)
 	assert.Less(t, timeTaken, duration+100*time.Millisecond)
 	assert.Equal(t, 1024*1024, fill.Size())
 }
+
+func TestComputeParseMemoryTouch(t *testing.T) {
+	compute := Compute{}
+	assert.NoError(t, compute.Parse("50ms 0.5 cpu +100mb touch 1gb/s"))
+	assert.Equal(t, Compute{
+		Min:           50 * time.Millisecond,
+		Max:           50 * time.Millisecond,
+		CPU:           0.5,
+		MemoryDeltaKB: 100 * 1024,
+		MemoryTouch:   ByteRate(1024 * 1024 * 1024),
+	}, compute)
+	assert.Equal(t, "1gb/s", compute.MemoryTouch.String())
+
+	_, err := ParseByteRate("10ms/s")
+	assert.Error(t, err)
+}
+
+func TestComputeWaitsForMemoryTouch(t *testing.T) {
+	compute := Compute{}
+	assert.NoError(t, compute.Parse("20ms +1mb touch 1gb/s"))
+	goroutines := runtime.NumGoroutine()
+	compute.Do(context.Background(), &memory.Fill{}, random.New(1))
+	assert.Equal(t, goroutines, runtime.NumGoroutine())
+}
+
+func TestComputeAllocRate(t *testing.T) {
+	plan := load(t, `
+execution:
+- compute:
+    min: 100ms
+    alloc-rate: 100mb/s
+    alloc-size: lognormal(512b, 1)
+`)
+	compute := plan.Execution[0].(*Compute)
+	assert.Equal(t, ByteRate(100*1024*1024), compute.AllocRate)
+	assert.Equal(t, &Distribution{Kind: DistributionLogNormal, Median: Bytes(512), Sigma: 1}, compute.AllocSize)
+
+	before := memory.Stats()
+	goroutines := runtime.NumGoroutine()
+	compute.Do(context.Background(), &memory.Fill{}, random.New(1))
+	assert.Equal(t, goroutines, runtime.NumGoroutine())
+	after := memory.Stats()
+	assert.Greater(t, after.TotalAlloc-before.TotalAlloc, uint64(5*1024*1024))
+}
+
+func TestComputeParseKernel(t *testing.T) {
+	compute := Compute{}
+	assert.NoError(t, compute.Parse("10ms 1.5 cpu(pointer-chase) +1mb"))
+	assert.Equal(t, Compute{
+		Min:           10 * time.Millisecond,
+		Max:           10 * time.Millisecond,
+		CPU:           1.5,
+		Kernel:        kernel.PointerChase,
+		MemoryDeltaKB: 1024,
+	}, compute)
+
+	assert.Error(t, compute.Parse("10ms 1 cpu(bogus)"))
+	assert.Error(t, Compute{Min: time.Millisecond, Kernel: "bogus"}.Validate())
+
+	start := time.Now()
+	Compute{Min: 50 * time.Millisecond, CPU: 1, Kernel: kernel.Compress}.Do(context.Background(), &memory.Fill{}, random.New(1))
+	assert.Less(t, time.Since(start), 70*time.Millisecond)
+}
+
+func TestComputeCPUUsage(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("cpu usage is only measured on linux")
+	}
+	duration := 300 * time.Millisecond
+	usage := Compute{Min: duration, CPU: 1}.Do(context.Background(), &memory.Fill{}, random.New(1))
+	assert.True(t, usage.Measured)
+	assert.InDelta(t, duration, usage.Requested, float64(5*time.Millisecond))
+	assert.InDelta(t, duration, usage.Actual, float64(60*time.Millisecond))
+
+	defer func(previous float64) { MaxCPU = previous }(MaxCPU)
+	MaxCPU = 0.25
+	capped := Compute{Min: duration, CPU: 1}.Do(context.Background(), &memory.Fill{}, random.New(1))
+	assert.InDelta(t, duration, capped.Requested, float64(5*time.Millisecond))
+	assert.Greater(t, capped.Actual, time.Duration(0))
+	assert.Less(t, capped.Actual, usage.Actual/2)
+}
@@ -0,0 +1,94 @@
+package plan
+
+import (
+	"fmt"
+	"time"
+)
+
+type ResourceKind string
+
+const (
+	ResourceMutex     ResourceKind = "mutex"
+	ResourceSemaphore ResourceKind = "semaphore"
+	ResourcePool      ResourceKind = "pool"
+)
+
+// DefaultPoolTimeout is how long acquiring a connection from a pool waits by default
+const DefaultPoolTimeout = 1 * time.Second
+
+// Contend makes concurrent requests handled by the same kaller process compete for a named
+// shared resource. The steps in Execution only run once the resource is acquired, and the
+// resource is held until they are done. This simulates hot locks and connection pools being
+// exhausted. Resources are shared by all contend steps with the same Resource name, Kind and
+// Permits, even across different plans
+//
+// Available resource kinds:
+//   - mutex: a single holder at a time. This is the default
+//   - semaphore: up to Permits holders at a time
+//   - pool: a connection pool with Permits connections. Unlike semaphores, acquiring a
+//     connection fails after Timeout (DefaultPoolTimeout by default) if the pool is exhausted
+//
+// Timeout can also be set for mutexes and semaphores, which otherwise wait as long as the request
+// is active. Failing to acquire the resource fails the step, failing the request
+type Contend struct {
+	Resource  string        `json:"resource" yaml:"resource"`
+	Kind      ResourceKind  `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Permits   int           `json:"permits,omitempty" yaml:"permits,omitempty"`
+	Timeout   time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+	Execution Execution     `json:"execution,omitempty" yaml:"execution,omitempty"`
+}
+
+func (Contend) StepType() StepType {
+	return StepTypeContend
+}
+
+func (c Contend) String() string {
+	switch c.ResourceKind() {
+	case ResourceMutex:
+		return fmt.Sprintf("mutex %s", c.Resource)
+	default:
+		return fmt.Sprintf("%s %s (%d)", c.ResourceKind(), c.Resource, c.ResourcePermits())
+	}
+}
+
+// ResourceKind returns the kind of resource being contended, mutex by default
+func (c Contend) ResourceKind() ResourceKind {
+	if c.Kind == "" {
+		return ResourceMutex
+	}
+	return c.Kind
+}
+
+// ResourcePermits returns how many holders the resource can have at the same time
+func (c Contend) ResourcePermits() int {
+	if c.ResourceKind() == ResourceMutex || c.Permits <= 0 {
+		return 1
+	}
+	return c.Permits
+}
+
+// AcquireTimeout returns how long to wait for the resource. Zero means no timeout
+func (c Contend) AcquireTimeout() time.Duration {
+	if c.Timeout == 0 && c.ResourceKind() == ResourcePool {
+		return DefaultPoolTimeout
+	}
+	return c.Timeout
+}
+
+func (c Contend) Validate() error {
+	if c.Resource == "" {
+		return fmt.Errorf("invalid contend: resource name is required")
+	}
+	switch c.ResourceKind() {
+	case ResourceMutex, ResourceSemaphore, ResourcePool:
+	default:
+		return fmt.Errorf("invalid contend: unknown resource kind %q", c.Kind)
+	}
+	if c.Permits < 0 {
+		return fmt.Errorf("invalid contend: negative permits %d", c.Permits)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid contend: negative timeout %v", c.Timeout)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var contendPlan = `
+execution:
+- contend:
+  resource: orders-lock
+  execution:
+  - compute: 10ms
+- contend:
+    resource: db
+    kind: pool
+    permits: 10
+    execution:
+    - call:
+      http: GET db/query 200
+`
+
+func TestContend(t *testing.T) {
+	plan := load(t, contendPlan)
+	require.Equal(t, 2, len(plan.Execution))
+
+	lock := plan.Execution[0].(*Contend)
+	assert.Equal(t, "orders-lock", lock.Resource)
+	assert.Equal(t, ResourceMutex, lock.ResourceKind())
+	assert.Equal(t, 1, lock.ResourcePermits())
+	assert.Equal(t, time.Duration(0), lock.AcquireTimeout())
+	assert.Equal(t, "mutex orders-lock", lock.String())
+	assert.Equal(t, 1, len(lock.Execution))
+
+	pool := plan.Execution[1].(*Contend)
+	assert.Equal(t, ResourcePool, pool.ResourceKind())
+	assert.Equal(t, 10, pool.ResourcePermits())
+	assert.Equal(t, DefaultPoolTimeout, pool.AcquireTimeout())
+	assert.Equal(t, "pool db (10)", pool.String())
+	assert.NoError(t, pool.Validate())
+
+	assert.Equal(t, []string{"db"}, plan.Hosts())
+	estimate := plan.Estimate()
+	assert.Equal(t, 10*time.Millisecond, estimate.Latency.Min)
+	assert.Equal(t, "0 contend mutex orders-lock", estimate.CriticalPath[0])
+
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err := FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	assert.Error(t, Contend{}.Validate())
+	assert.Error(t, Contend{Resource: "x", Kind: "spinlock"}.Validate())
+	assert.Error(t, Contend{Resource: "x", Permits: -1}.Validate())
+}
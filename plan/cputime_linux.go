@@ -0,0 +1,19 @@
+package plan
+
+import (
+	"syscall"
+	"time"
+)
+
+// RUSAGE_THREAD is not exposed by the syscall package
+const rusageThread = 1
+
+// threadCPUTime returns the cpu time (user and system) consumed by the current thread. Callers
+// should lock their goroutine to the thread (see runtime.LockOSThread)
+func threadCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
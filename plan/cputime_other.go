@@ -0,0 +1,10 @@
+//go:build !linux
+
+package plan
+
+import "time"
+
+// threadCPUTime is only supported on linux
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,463 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type DistributionKind string
+
+const (
+	DistributionUniform     DistributionKind = "uniform"
+	DistributionNormal      DistributionKind = "normal"
+	DistributionLogNormal   DistributionKind = "lognormal"
+	DistributionExponential DistributionKind = "exponential"
+	DistributionPareto      DistributionKind = "pareto"
+	DistributionEmpirical   DistributionKind = "empirical"
+	DistributionWeighted    DistributionKind = "weighted"
+)
+
+// Distribution describes how to randomly pick a value. Parameters are Quantity values,
+// so the same machinery can be used for durations, sizes or plain numbers. Each kind
+// uses a different set of parameters:
+//   - uniform: Min and Max
+//   - normal: Mean and StdDev
+//   - lognormal: Median and Sigma, where Sigma is the standard deviation of the
+//     logarithm of the values
+//   - exponential: Mean
+//   - pareto: Scale (the minimum value) and Shape (the alpha parameter, lower values
+//     meaning longer tails)
+//   - empirical: Percentiles, a table from percentile (eg: "p50", "p99.9") to value.
+//     Values in between percentiles are linearly interpolated. Unless p0 is given, values
+//     below the first percentile are interpolated from 0
+//   - weighted: Weights, a table from value to its relative weight. Only the listed values
+//     are picked, eg: status code 200 with weight 99 and 500 with weight 1
+//
+// Distributions can also be defined with a compact function-like syntax. See ParseDistribution
+type Distribution struct {
+	Kind        DistributionKind    `json:"kind" yaml:"kind"`
+	Min         Quantity            `json:"min,omitempty" yaml:"min,omitempty"`
+	Max         Quantity            `json:"max,omitempty" yaml:"max,omitempty"`
+	Mean        Quantity            `json:"mean,omitempty" yaml:"mean,omitempty"`
+	StdDev      Quantity            `json:"stddev,omitempty" yaml:"stddev,omitempty"`
+	Median      Quantity            `json:"median,omitempty" yaml:"median,omitempty"`
+	Sigma       float64             `json:"sigma,omitempty" yaml:"sigma,omitempty"`
+	Scale       Quantity            `json:"scale,omitempty" yaml:"scale,omitempty"`
+	Shape       float64             `json:"shape,omitempty" yaml:"shape,omitempty"`
+	Percentiles map[string]Quantity `json:"percentiles,omitempty" yaml:"percentiles,omitempty"`
+	Weights     map[string]float64  `json:"weights,omitempty" yaml:"weights,omitempty"`
+
+	// table holds Percentiles or Weights parsed and sorted. It is built once when the
+	// distribution is parsed or decoded, so sampling does not need to rebuild it
+	table *distributionTable
+}
+
+type distributionTable struct {
+	points      []percentilePoint
+	choices     []weightedChoice
+	totalWeight float64
+}
+
+// Validate checks the distribution parameters. Distributions that were parsed or decoded are
+// already validated, so this is cheap for them
+func (d *Distribution) Validate() error {
+	if d.table != nil {
+		return nil
+	}
+	_, err := d.newTable()
+	return err
+}
+
+// prepare validates the distribution and keeps its table. It must only be called while the
+// distribution is not shared yet, like when parsing or decoding it
+func (d *Distribution) prepare() error {
+	table, err := d.newTable()
+	if err != nil {
+		return err
+	}
+	d.table = table
+	return nil
+}
+
+// newTable validates the distribution and builds its table, if its kind needs one
+func (d *Distribution) newTable() (*distributionTable, error) {
+	switch d.Kind {
+	case DistributionUniform:
+		if d.Min.Value > d.Max.Value {
+			return nil, fmt.Errorf("invalid uniform distribution: min is higher than max (min: %v, max: %v)", d.Min, d.Max)
+		}
+	case DistributionNormal:
+		if d.StdDev.Value < 0 {
+			return nil, fmt.Errorf("invalid normal distribution: stddev is negative (%v)", d.StdDev)
+		}
+	case DistributionLogNormal:
+		if d.Median.Value <= 0 || d.Sigma < 0 {
+			return nil, fmt.Errorf("invalid lognormal distribution: median must be positive and sigma non negative (median: %v, sigma: %v)", d.Median, d.Sigma)
+		}
+	case DistributionExponential:
+		if d.Mean.Value <= 0 {
+			return nil, fmt.Errorf("invalid exponential distribution: mean must be positive (%v)", d.Mean)
+		}
+	case DistributionPareto:
+		if d.Scale.Value <= 0 || d.Shape <= 0 {
+			return nil, fmt.Errorf("invalid pareto distribution: scale and shape must be positive (scale: %v, shape: %v)", d.Scale, d.Shape)
+		}
+	case DistributionEmpirical:
+		points, err := d.points()
+		if err != nil {
+			return nil, err
+		}
+		if len(points) == 0 {
+			return nil, fmt.Errorf("invalid empirical distribution: no percentiles defined")
+		}
+		return &distributionTable{points: points}, nil
+	case DistributionWeighted:
+		choices, err := d.choices()
+		if err != nil {
+			return nil, err
+		}
+		if len(choices) == 0 {
+			return nil, fmt.Errorf("invalid weighted distribution: no weights defined")
+		}
+		table := &distributionTable{choices: choices}
+		for _, choice := range choices {
+			table.totalWeight += choice.weight
+		}
+		if table.totalWeight == 0 {
+			return nil, fmt.Errorf("invalid weighted distribution: weights add up to 0")
+		}
+		return table, nil
+	default:
+		return nil, fmt.Errorf("invalid distribution kind %q", d.Kind)
+	}
+	return nil, nil
+}
+
+// Sample picks a random value from the distribution, expressed in the base unit of its
+// parameters (nanoseconds for durations, bytes for sizes)
+func (d *Distribution) Sample(rnd *rand.Rand) float64 {
+	switch d.Kind {
+	case DistributionUniform:
+		return d.Min.Value + rnd.Float64()*(d.Max.Value-d.Min.Value)
+	case DistributionNormal:
+		return d.Mean.Value + rnd.NormFloat64()*d.StdDev.Value
+	case DistributionLogNormal:
+		return d.Median.Value * math.Exp(d.Sigma*rnd.NormFloat64())
+	case DistributionExponential:
+		return rnd.ExpFloat64() * d.Mean.Value
+	case DistributionPareto:
+		return d.Scale.Value / math.Pow(1-rnd.Float64(), 1/d.Shape)
+	case DistributionEmpirical:
+		return d.quantile(rnd.Float64() * 100)
+	case DistributionWeighted:
+		table := d.lookup()
+		choices := table.choices
+		pick := rnd.Float64() * table.totalWeight
+		for _, choice := range choices {
+			if pick < choice.weight {
+				return choice.value.Value
+			}
+			pick -= choice.weight
+		}
+		if len(choices) > 0 {
+			return choices[len(choices)-1].value.Value
+		}
+	}
+	return 0
+}
+
+// Bounds returns the range where most values of the distribution fall. For unbounded
+// distributions this is the 0.1 to 99.9 percentiles range
+func (d *Distribution) Bounds() (float64, float64) {
+	const z = 3.09 // 99.9 percentile of the standard normal distribution
+	var min, max float64
+	switch d.Kind {
+	case DistributionUniform:
+		min, max = d.Min.Value, d.Max.Value
+	case DistributionNormal:
+		min, max = d.Mean.Value-z*d.StdDev.Value, d.Mean.Value+z*d.StdDev.Value
+	case DistributionLogNormal:
+		min, max = d.Median.Value*math.Exp(-z*d.Sigma), d.Median.Value*math.Exp(z*d.Sigma)
+	case DistributionExponential:
+		min, max = d.Mean.Value*-math.Log(0.999), d.Mean.Value*-math.Log(0.001)
+	case DistributionPareto:
+		min, max = d.Scale.Value, d.Scale.Value/math.Pow(0.001, 1/d.Shape)
+	case DistributionEmpirical:
+		min, max = d.quantile(0), d.quantile(100)
+	case DistributionWeighted:
+		choices := d.lookup().choices
+		if len(choices) > 0 {
+			min, max = choices[0].value.Value, choices[len(choices)-1].value.Value
+		}
+	}
+	return math.Max(min, 0), math.Max(max, 0)
+}
+
+// lookup returns the distribution table. Distributions that were not parsed or decoded, like
+// the ones created directly in code, get a table built on the spot, which is not kept so
+// sampling stays safe for concurrent use
+func (d *Distribution) lookup() *distributionTable {
+	if d.table != nil {
+		return d.table
+	}
+	table, err := d.newTable()
+	if err != nil || table == nil {
+		return &distributionTable{}
+	}
+	return table
+}
+
+type weightedChoice struct {
+	key    string
+	value  Quantity
+	weight float64
+}
+
+// choices returns the weighted values sorted by value, so sampling is deterministic
+func (d *Distribution) choices() ([]weightedChoice, error) {
+	choices := make([]weightedChoice, 0, len(d.Weights))
+	for key, weight := range d.Weights {
+		value, err := ParseQuantity(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid weighted distribution: %w", err)
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("invalid weighted distribution: negative weight for %s", key)
+		}
+		choices = append(choices, weightedChoice{key: key, value: value, weight: weight})
+	}
+	sort.Slice(choices, func(i, j int) bool { return choices[i].value.Value < choices[j].value.Value })
+	return choices, nil
+}
+
+type percentilePoint struct {
+	percentile float64
+	value      float64
+}
+
+func (d *Distribution) points() ([]percentilePoint, error) {
+	points := make([]percentilePoint, 0, len(d.Percentiles))
+	for key, value := range d.Percentiles {
+		percentile, err := strconv.ParseFloat(strings.TrimPrefix(strings.ToLower(key), "p"), 64)
+		if err != nil || percentile < 0 || percentile > 100 {
+			return nil, fmt.Errorf("invalid empirical distribution: bad percentile %q", key)
+		}
+		points = append(points, percentilePoint{percentile: percentile, value: value.Value})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].percentile < points[j].percentile })
+	for idx := 1; idx < len(points); idx++ {
+		if points[idx].value < points[idx-1].value {
+			return nil, fmt.Errorf("invalid empirical distribution: values must grow with percentiles")
+		}
+	}
+	return points, nil
+}
+
+// quantile returns the value at percentile p by interpolating the percentile table.
+// Percentiles below the first entry are interpolated from an implicit p0 of 0 (or of the
+// first entry value, if it is negative). Percentiles above the last entry take its value
+func (d *Distribution) quantile(p float64) float64 {
+	points := d.lookup().points
+	if len(points) == 0 {
+		return 0
+	}
+	if first := points[0]; p < first.percentile {
+		lowest := math.Min(first.value, 0)
+		return lowest + p/first.percentile*(first.value-lowest)
+	}
+	for idx := 1; idx < len(points); idx++ {
+		prev, next := points[idx-1], points[idx]
+		if p <= next.percentile {
+			ratio := (p - prev.percentile) / (next.percentile - prev.percentile)
+			return prev.value + ratio*(next.value-prev.value)
+		}
+	}
+	return points[len(points)-1].value
+}
+
+func (d *Distribution) String() string {
+	var args []string
+	switch d.Kind {
+	case DistributionUniform:
+		args = []string{d.Min.String(), d.Max.String()}
+	case DistributionNormal:
+		args = []string{d.Mean.String(), d.StdDev.String()}
+	case DistributionLogNormal:
+		args = []string{d.Median.String(), formatFloat(d.Sigma)}
+	case DistributionExponential:
+		args = []string{d.Mean.String()}
+	case DistributionPareto:
+		args = []string{d.Scale.String(), formatFloat(d.Shape)}
+	case DistributionEmpirical:
+		points := d.lookup().points
+		keys := make(map[float64]string, len(d.Percentiles))
+		for key := range d.Percentiles {
+			percentile, _ := strconv.ParseFloat(strings.TrimPrefix(strings.ToLower(key), "p"), 64)
+			keys[percentile] = key
+		}
+		for _, point := range points {
+			key := keys[point.percentile]
+			args = append(args, fmt.Sprintf("%s=%s", key, d.Percentiles[key]))
+		}
+	case DistributionWeighted:
+		for _, choice := range d.lookup().choices {
+			args = append(args, fmt.Sprintf("%s=%s%%", choice.key, formatFloat(choice.weight)))
+		}
+	}
+	return fmt.Sprintf("%s(%s)", d.Kind, strings.Join(args, ", "))
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// The regex pattern used to recognize distributions in compact form, eg: "normal(50ms, 10ms)"
+const DistributionPattern = `(\w+)\(([^)]*)\)`
+
+var distributionPattern = regexp.MustCompile(`^` + DistributionPattern + `$`)
+
+// ParseDistribution parses a distribution from its compact form. Examples:
+//   - "uniform(10ms, 50ms)"
+//   - "normal(50ms, 10ms)": mean 50ms and standard deviation of 10ms
+//   - "lognormal(10kb, 0.5)": median of 10kb and sigma 0.5
+//   - "exponential(20ms)" or "exp(20ms)": mean of 20ms
+//   - "pareto(10ms, 1.5)": scale (minimum value) of 10ms and shape 1.5
+//   - "empirical(p50=10ms, p90=50ms, p99=200ms)"
+//   - "weighted(200=99%, 500=1%)": weights do not need to add up to 100%
+//
+// Pairs of empirical and weighted distributions can also be separated with ": ", as in
+// "weighted(200: 99%, 500: 1%)", but then the distribution must be quoted when used in yaml
+func ParseDistribution(s string) (*Distribution, error) {
+	parts := distributionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if parts == nil {
+		return nil, fmt.Errorf("cannot parse distribution %q", s)
+	}
+	kind := DistributionKind(strings.ToLower(parts[1]))
+	args := []string{}
+	for _, arg := range strings.Split(parts[2], ",") {
+		if arg = strings.TrimSpace(arg); arg != "" {
+			args = append(args, arg)
+		}
+	}
+
+	quantities := func(n int) ([]Quantity, error) {
+		if len(args) != n {
+			return nil, fmt.Errorf("cannot parse distribution %q: %s takes %d arguments", s, kind, n)
+		}
+		result := make([]Quantity, n)
+		for idx, arg := range args {
+			q, err := ParseQuantity(arg)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse distribution %q: %w", s, err)
+			}
+			result[idx] = q
+		}
+		return result, nil
+	}
+
+	d := Distribution{Kind: kind}
+	var q []Quantity
+	var err error
+	switch kind {
+	case DistributionUniform:
+		if q, err = quantities(2); err == nil {
+			d.Min, d.Max = q[0], q[1]
+		}
+	case DistributionNormal:
+		if q, err = quantities(2); err == nil {
+			d.Mean, d.StdDev = q[0], q[1]
+		}
+	case DistributionLogNormal:
+		if q, err = quantities(2); err == nil {
+			d.Median, d.Sigma = q[0], q[1].Value
+		}
+	case DistributionExponential, "exp":
+		d.Kind = DistributionExponential
+		if q, err = quantities(1); err == nil {
+			d.Mean = q[0]
+		}
+	case DistributionPareto:
+		if q, err = quantities(2); err == nil {
+			d.Scale, d.Shape = q[0], q[1].Value
+		}
+	case DistributionEmpirical:
+		d.Percentiles = map[string]Quantity{}
+		for _, arg := range args {
+			kv := splitPair(arg)
+			if kv == nil {
+				return nil, fmt.Errorf("cannot parse distribution %q: expected percentile=value pairs", s)
+			}
+			value, err := ParseQuantity(kv[1])
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse distribution %q: %w", s, err)
+			}
+			d.Percentiles[strings.TrimSpace(kv[0])] = value
+		}
+	case DistributionWeighted:
+		d.Weights = map[string]float64{}
+		for _, arg := range args {
+			kv := splitPair(arg)
+			if kv == nil {
+				return nil, fmt.Errorf("cannot parse distribution %q: expected value=weight pairs", s)
+			}
+			weight, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(kv[1]), "%"), 64)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse distribution %q: bad weight %q", s, kv[1])
+			}
+			d.Weights[strings.TrimSpace(kv[0])] = weight
+		}
+	default:
+		return nil, fmt.Errorf("cannot parse distribution %q: unknown kind %q", s, kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := d.prepare(); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// splitPair splits a "key=value" (or "key: value") pair of a distribution in compact form
+func splitPair(arg string) []string {
+	idx := strings.IndexAny(arg, "=:")
+	if idx < 0 {
+		return nil
+	}
+	return []string{arg[:idx], arg[idx+1:]}
+}
+
+func (d *Distribution) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value != "" {
+		parsed, err := ParseDistribution(node.Value)
+		if err != nil {
+			return fmt.Errorf("invalid distribution at line %d: %w", node.Line, err)
+		}
+		*d = *parsed
+		return nil
+	}
+	type raw Distribution
+	if err := node.Decode((*raw)(d)); err != nil {
+		return err
+	}
+	if err := d.prepare(); err != nil {
+		return fmt.Errorf("invalid distribution at line %d: %w", node.Line, err)
+	}
+	return nil
+}
+
+func (d *Distribution) UnmarshalJSON(data []byte) error {
+	type raw Distribution
+	if err := json.Unmarshal(data, (*raw)(d)); err != nil {
+		return err
+	}
+	return d.prepare()
+}
@@ -0,0 +1,263 @@
+package plan
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/bcap/kaller/random"
+)
+
+func TestParseDistribution(t *testing.T) {
+	tests := map[string]Distribution{
+		"uniform(10ms, 50ms)":  {Kind: DistributionUniform, Min: Duration(10 * time.Millisecond), Max: Duration(50 * time.Millisecond)},
+		"normal(50ms, 10ms)":   {Kind: DistributionNormal, Mean: Duration(50 * time.Millisecond), StdDev: Duration(10 * time.Millisecond)},
+		"lognormal(10kb, 0.5)": {Kind: DistributionLogNormal, Median: Bytes(10 * 1024), Sigma: 0.5},
+		"exp(20ms)":            {Kind: DistributionExponential, Mean: Duration(20 * time.Millisecond)},
+		"pareto(10ms, 1.5)":    {Kind: DistributionPareto, Scale: Duration(10 * time.Millisecond), Shape: 1.5},
+		"empirical(p50=10ms, p99.9=200ms)": {
+			Kind: DistributionEmpirical,
+			Percentiles: map[string]Quantity{
+				"p50":   Duration(10 * time.Millisecond),
+				"p99.9": Duration(200 * time.Millisecond),
+			},
+		},
+		"empirical(p50: 10ms, p99.9: 200ms)": {
+			Kind: DistributionEmpirical,
+			Percentiles: map[string]Quantity{
+				"p50":   Duration(10 * time.Millisecond),
+				"p99.9": Duration(200 * time.Millisecond),
+			},
+		},
+		"weighted(200=99%, 500=1%)":   {Kind: DistributionWeighted, Weights: map[string]float64{"200": 99, "500": 1}},
+		"weighted(200: 99%, 500: 1%)": {Kind: DistributionWeighted, Weights: map[string]float64{"200": 99, "500": 1}},
+	}
+	for input, expected := range tests {
+		require.NoError(t, expected.prepare(), input)
+		parsed, err := ParseDistribution(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, *parsed, input)
+
+		reparsed, err := ParseDistribution(parsed.String())
+		require.NoError(t, err, parsed.String())
+		assert.Equal(t, expected, *reparsed, parsed.String())
+	}
+
+	for _, input := range []string{
+		"normal(50ms)",
+		"weird(10ms)",
+		"lognormal(0ms, 1)",
+		"empirical(p50=20ms, p90=10ms)",
+		"empirical(p500=20ms)",
+		"uniform(50ms, 10ms)",
+		"weighted()",
+		"weighted(200=-1%)",
+		"weighted(200=0%, 500=0%)",
+	} {
+		_, err := ParseDistribution(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestDistributionSample(t *testing.T) {
+	median := func(d string) float64 {
+		distribution, err := ParseDistribution(d)
+		require.NoError(t, err)
+		rnd := random.New(1)
+		samples := make([]float64, 10001)
+		for idx := range samples {
+			samples[idx] = distribution.Sample(rnd)
+		}
+		sort.Float64s(samples)
+		return samples[len(samples)/2]
+	}
+	ms := float64(time.Millisecond)
+	assert.InDelta(t, 30*ms, median("uniform(10ms, 50ms)"), 1*ms)
+	assert.InDelta(t, 50*ms, median("normal(50ms, 10ms)"), 1*ms)
+	assert.InDelta(t, 20*ms, median("lognormal(20ms, 0.5)"), 1*ms)
+	assert.InDelta(t, 20*ms*0.693, median("exp(20ms)"), 1*ms)
+	assert.InDelta(t, 10*ms*1.587, median("pareto(10ms, 1.5)"), 1*ms)
+	assert.InDelta(t, 10*ms, median("empirical(p0=1ms, p50=10ms, p99=200ms)"), 1*ms)
+	assert.Equal(t, 200.0, median("weighted(200=99%, 500=1%)"))
+	assert.Equal(t, 500.0, median("weighted(200=10%, 500=90%)"))
+}
+
+func TestEmpiricalBelowFirstPercentile(t *testing.T) {
+	distribution, err := ParseDistribution("empirical(p50=10ms, p99=200ms)")
+	require.NoError(t, err)
+	ms := float64(time.Millisecond)
+	assert.Equal(t, 0.0, distribution.quantile(0))
+	assert.InDelta(t, 5*ms, distribution.quantile(25), 0.001*ms)
+	assert.Equal(t, 10*ms, distribution.quantile(50))
+
+	// values below p50 spread from 0 to 10ms instead of all being 10ms
+	rnd := random.New(1)
+	below, exact := 0, 0
+	for idx := 0; idx < 10000; idx++ {
+		sample := distribution.Sample(rnd)
+		if sample < 10*ms {
+			below++
+		}
+		if sample == 10*ms {
+			exact++
+		}
+	}
+	assert.InDelta(t, 5000, below, 200)
+	assert.Equal(t, 0, exact)
+
+	withP0, err := ParseDistribution("empirical(p0=8ms, p50=10ms)")
+	require.NoError(t, err)
+	assert.InDelta(t, 9*ms, withP0.quantile(25), 0.001*ms)
+}
+
+var computeDistributions = `
+execution:
+- compute: lognormal(20ms, 0.5) 0.5 cpu +1mb
+- compute:
+    max: 70ms
+    cpu: 1
+    distribution: normal(50ms, 10ms)
+- compute:
+    distribution:
+      kind: empirical
+      percentiles:
+        p50: 10ms
+        p99: 200ms
+- compute: empirical(p50=10ms, p99=200ms) 0.5 cpu
+`
+
+func TestComputeDistribution(t *testing.T) {
+	plan := load(t, computeDistributions)
+	require.Equal(t, 4, len(plan.Execution))
+
+	assert.Equal(t,
+		&Compute{
+			Distribution:  &Distribution{Kind: DistributionLogNormal, Median: Duration(20 * time.Millisecond), Sigma: 0.5},
+			CPU:           0.5,
+			MemoryDeltaKB: 1024,
+		},
+		plan.Execution[0],
+	)
+	assert.Equal(t,
+		&Compute{
+			Max:          70 * time.Millisecond,
+			Distribution: &Distribution{Kind: DistributionNormal, Mean: Duration(50 * time.Millisecond), StdDev: Duration(10 * time.Millisecond)},
+			CPU:          1,
+		},
+		plan.Execution[1],
+	)
+	assert.Equal(t,
+		&Compute{
+			Distribution: prepared(&Distribution{
+				Kind: DistributionEmpirical,
+				Percentiles: map[string]Quantity{
+					"p50": Duration(10 * time.Millisecond),
+					"p99": Duration(200 * time.Millisecond),
+				},
+			}),
+		},
+		plan.Execution[2],
+	)
+	// the compact form in the docs works unquoted in yaml
+	assert.Equal(t,
+		&Compute{
+			Distribution: prepared(&Distribution{
+				Kind: DistributionEmpirical,
+				Percentiles: map[string]Quantity{
+					"p50": Duration(10 * time.Millisecond),
+					"p99": Duration(200 * time.Millisecond),
+				},
+			}),
+			CPU: 0.5,
+		},
+		plan.Execution[3],
+	)
+
+	min, max := plan.Execution[1].(*Compute).Bounds()
+	assert.Equal(t, 50*time.Millisecond-30900*time.Microsecond, min)
+	assert.Equal(t, 70*time.Millisecond, max)
+
+	encoded, err := plan.ToYAML()
+	require.NoError(t, err)
+	decoded, err := FromYAML(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	encoded, err = plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err = FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+}
+
+var httpDistributions = `
+execution:
+- call:
+    http: GET service-a/x weighted(200=99%, 500=1%) 0 lognormal(10kb, 0.5)
+- call:
+    http:
+      method: POST
+      url: service-b/y
+      status-code: weighted(200=90%, 503=10%)
+      gen-request-body: uniform(1kb, 2kb)
+      gen-response-body: 100
+- call:
+    http: "GET service-c/z weighted(200: 50%, 500: 50%)"
+`
+
+func TestHTTPDistribution(t *testing.T) {
+	// the first calls use the compact forms from the docs unquoted, while the last one uses
+	// the ": " separator, which needs quoting
+	plan := load(t, httpDistributions)
+	require.Equal(t, 3, len(plan.Execution))
+
+	first := plan.Execution[0].(*Call).HTTP
+	assert.Equal(t, "GET", first.Method)
+	assert.Equal(t, prepared(&Distribution{Kind: DistributionWeighted, Weights: map[string]float64{"200": 99, "500": 1}}), first.StatusCodeDistribution)
+	assert.Equal(t, 0, first.GenRequestBody)
+	assert.Nil(t, first.GenRequestBodyDistribution)
+	assert.Equal(t, &Distribution{Kind: DistributionLogNormal, Median: Bytes(10 * 1024), Sigma: 0.5}, first.GenResponseBodyDistribution)
+
+	second := plan.Execution[1].(*Call).HTTP
+	assert.Equal(t, prepared(&Distribution{Kind: DistributionWeighted, Weights: map[string]float64{"200": 90, "503": 10}}), second.StatusCodeDistribution)
+	assert.Equal(t, &Distribution{Kind: DistributionUniform, Min: Bytes(1024), Max: Bytes(2048)}, second.GenRequestBodyDistribution)
+	assert.Equal(t, 100, second.GenResponseBody)
+	assert.Equal(t, "POST service-b/y weighted(200=90%, 503=10%)", second.String())
+
+	third := plan.Execution[2].(*Call).HTTP
+	assert.Equal(t, prepared(&Distribution{Kind: DistributionWeighted, Weights: map[string]float64{"200": 50, "500": 50}}), third.StatusCodeDistribution)
+
+	rnd := random.New(1)
+	statusCodes := map[int]int{}
+	for i := 0; i < 1000; i++ {
+		statusCodes[second.PickStatusCode(rnd)]++
+		size := second.PickRequestBodySize(rnd)
+		assert.True(t, size >= 1024 && size <= 2048, size)
+		assert.Equal(t, 100, second.PickResponseBodySize(rnd))
+	}
+	assert.Equal(t, 2, len(statusCodes))
+	assert.InDelta(t, 900, statusCodes[200], 50)
+
+	encoded, err := plan.ToYAML()
+	require.NoError(t, err)
+	decoded, err := FromYAML(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	encoded, err = plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err = FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+}
+
+// prepared returns the distribution as if it was parsed
+func prepared(d *Distribution) *Distribution {
+	if err := d.prepare(); err != nil {
+		panic(err)
+	}
+	return d
+}
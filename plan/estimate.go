@@ -0,0 +1,325 @@
+package plan
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Estimate is the result of statically analyzing a plan, without executing it.
+// See Plan.Estimate
+type Estimate struct {
+	// Latency is the time the plan takes until the top level execution is done, which
+	// does not include async calls and post executions that are still running
+	Latency Range
+	// Completion is the time until all work in the plan is done, including async calls
+	// and post executions
+	Completion Range
+	// CPUSeconds is the total amount of cpu time spent across all services
+	CPUSeconds FloatRange
+	// Requests is the total amount of requests received per service (host)
+	Requests map[string]int
+	// CriticalPath lists the steps that determine the max latency, from top to bottom
+	CriticalPath []string
+}
+
+// The disk throughput range assumed when estimating io steps, as how fast the disk of each
+// service is cannot be known statically. Steps with a lower rate cap are bounded by it instead
+var (
+	EstimateDiskMinRate ByteRate = 100 * 1024 * 1024      // 100mb/s, a slow network or spinning disk
+	EstimateDiskMaxRate ByteRate = 2 * 1024 * 1024 * 1024 // 2gb/s, a fast nvme disk or the page cache
+)
+
+type Range struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+func (r Range) add(o Range) Range {
+	return Range{Min: r.Min + o.Min, Max: r.Max + o.Max}
+}
+
+func (r Range) max(o Range) Range {
+	if o.Min > r.Min {
+		r.Min = o.Min
+	}
+	if o.Max > r.Max {
+		r.Max = o.Max
+	}
+	return r
+}
+
+func (r Range) String() string {
+	if r.Min == r.Max {
+		return r.Min.String()
+	}
+	return fmt.Sprintf("%s to %s", r.Min, r.Max)
+}
+
+type FloatRange struct {
+	Min float64
+	Max float64
+}
+
+func (r FloatRange) String() string {
+	if r.Min == r.Max {
+		return fmt.Sprintf("%.3f", r.Min)
+	}
+	return fmt.Sprintf("%.3f to %.3f", r.Min, r.Max)
+}
+
+// Estimate statically analyzes the plan, computing the expected latencies, critical path,
+// cpu usage and requests per service. Compute ranges are taken at their min and max values,
+// loops and parallel blocks are simulated according to their concurrency, the same way
+// the handler package schedules them
+//
+// Service overrides are applied to the services they target, which are identified by the host
+// they are called with (without the port). Their extra latency and cpu multiplier are
+// accounted for, while injected errors are not: calls are assumed to succeed. Cpu loads are
+// capped at MaxCPU, like computes are when executed. IO steps are estimated with the disk
+// throughput range of EstimateDiskMinRate to EstimateDiskMaxRate
+//
+// The estimate does not account for network, serialization or scheduling overhead
+func (p Plan) Estimate() Estimate {
+	e := estimator{estimate: Estimate{Requests: map[string]int{}}, overrides: p.ServiceOverrides}
+	t := e.execution(p.Execution, 0, "", 1)
+	e.estimate.Latency = t.latency
+	e.estimate.Completion = t.completion
+	e.estimate.CriticalPath = t.path
+	return e.estimate
+}
+
+type estimator struct {
+	estimate  Estimate
+	overrides map[string]ServiceOverride
+	// service whose steps are being estimated
+	service string
+}
+
+// timing of a single step or a list of steps, relative to its start
+type timing struct {
+	// time until the step returns control to its caller
+	latency Range
+	// time until all the work started by the step is done
+	completion Range
+	// steps that define the max latency
+	path []string
+}
+
+func (e *estimator) execution(execution Execution, offset int, location string, times int) timing {
+	result := timing{}
+	for idx, step := range execution {
+		stepTiming := e.step(step, childLocation(location, offset+idx), times)
+		result.completion = result.completion.max(result.latency.add(stepTiming.completion))
+		result.latency = result.latency.add(stepTiming.latency)
+		result.path = append(result.path, stepTiming.path...)
+	}
+	result.completion = result.completion.max(result.latency)
+	return result
+}
+
+func (e *estimator) step(step Step, location string, times int) timing {
+	switch v := step.(type) {
+	case *Compute:
+		return e.compute(*v, location, times)
+	case *Call:
+		return e.call(v, location, times)
+	case *Parallel:
+		return e.parallel(v, location, times)
+	case *Loop:
+		return e.loop(v, location, times)
+	case *Contend:
+		return e.contend(v, location, times)
+	case *IO:
+		return e.io(*v, location)
+	}
+	return timing{}
+}
+
+func (e *estimator) compute(compute Compute, location string, times int) timing {
+	if compute.IsZero() {
+		return timing{}
+	}
+	var r Range
+	r.Min, r.Max = compute.Bounds()
+	cpu := math.Min(e.overrides[e.service].ApplyCPU(compute).CPU, MaxCPU)
+	e.estimate.CPUSeconds.Min += float64(times) * cpu * r.Min.Seconds()
+	e.estimate.CPUSeconds.Max += float64(times) * cpu * r.Max.Seconds()
+	return timing{
+		latency:    r,
+		completion: r,
+		path:       []string{fmt.Sprintf("%s compute %s", location, compute.String())},
+	}
+}
+
+func (e *estimator) call(call *Call, location string, times int) timing {
+	e.estimate.Requests[call.Host()] += times
+
+	// the call steps run in the called service
+	caller := e.service
+	e.service = ""
+	if url := call.Message().URL; url.URL != nil {
+		e.service = url.Hostname()
+	}
+	defer func() { e.service = caller }()
+
+	compute := e.compute(call.Compute, location, times)
+	if extra := e.overrides[e.service].ExtraLatency; extra > 0 {
+		compute.latency = compute.latency.add(Range{Min: extra, Max: extra})
+		compute.completion = compute.completion.add(Range{Min: extra, Max: extra})
+		compute.path = append(compute.path, fmt.Sprintf("%s extra latency %s", location, extra))
+	}
+	var execution timing
+	if stream := call.Stream(); stream != nil {
+		execution = e.stream(stream, call.Execution, location, times)
+	} else {
+		execution = e.execution(call.Execution, 0, location, times)
+	}
+	postExecution := e.execution(call.PostExecution, len(call.Execution), location, times)
+
+	response := compute.latency.add(execution.latency)
+	completion := compute.latency.add(execution.completion).max(response.add(postExecution.completion))
+
+	result := timing{completion: completion}
+	if !call.Async {
+		result.latency = response
+		result.path = append([]string{fmt.Sprintf("%s call %s", location, call.String())}, compute.path...)
+		result.path = append(result.path, execution.path...)
+	}
+	return result
+}
+
+// stream estimates the execution of a stream call, which runs once per message, with the
+// interval between messages
+func (e *estimator) stream(stream *Stream, execution []Step, location string, times int) timing {
+	messages := stream.MessageCount()
+	message := e.execution(execution, 0, location, times*messages)
+	interval := Range{Min: stream.Interval, Max: stream.Interval}
+	result := timing{path: message.path}
+	for idx := 0; idx < messages; idx++ {
+		if idx > 0 {
+			result.latency = result.latency.add(interval)
+		}
+		result.completion = result.completion.max(result.latency.add(message.completion))
+		result.latency = result.latency.add(message.latency)
+	}
+	return result
+}
+
+func (e *estimator) parallel(parallel *Parallel, location string, times int) timing {
+	timings := make([]timing, len(parallel.Execution))
+	for idx, step := range parallel.Execution {
+		timings[idx] = e.step(step, childLocation(location, idx), times)
+	}
+	return schedule(timings, parallel.Concurrency)
+}
+
+func (e *estimator) loop(loop *Loop, location string, times int) timing {
+	iteration := e.execution(loop.Execution, 0, location, times*loop.Times)
+	wait := e.compute(loop.Compute, location, times*loop.Times)
+	iteration.completion = iteration.completion.max(iteration.latency.add(wait.completion))
+	iteration.latency = iteration.latency.add(wait.latency)
+	iteration.path = append(iteration.path, wait.path...)
+
+	concurrency := loop.Concurrency
+	if concurrency <= 1 {
+		concurrency = 1
+	}
+	timings := make([]timing, loop.Times)
+	for idx := range timings {
+		timings[idx] = iteration
+	}
+	result := schedule(timings, concurrency)
+	header := fmt.Sprintf("%s loop x%d", location, loop.Times)
+	if loop.Concurrency > 1 {
+		header += fmt.Sprintf(" (concurrency %d)", loop.Concurrency)
+	}
+	result.path = append([]string{header}, iteration.path...)
+	return result
+}
+
+// contend estimates the contend step as if the resource was always available, since
+// contention depends on the concurrent requests a service gets
+func (e *estimator) contend(contend *Contend, location string, times int) timing {
+	result := e.execution(contend.Execution, 0, location, times)
+	result.path = append([]string{fmt.Sprintf("%s contend %s", location, contend.String())}, result.path...)
+	return result
+}
+
+// io estimates the io step with the assumed disk throughput range, bounded by the step rate
+// cap. The fastest throughput gives the min latency and the slowest the max latency
+func (e *estimator) io(io IO, location string) timing {
+	duration := func(rate ByteRate) time.Duration {
+		if io.Rate > 0 && io.Rate < rate {
+			rate = io.Rate
+		}
+		if rate <= 0 {
+			return 0
+		}
+		return time.Duration(float64(io.Size) / float64(rate) * float64(time.Second))
+	}
+	r := Range{Min: duration(EstimateDiskMaxRate), Max: duration(EstimateDiskMinRate)}
+	return timing{
+		latency:    r,
+		completion: r,
+		path:       []string{fmt.Sprintf("%s io %s", location, io.String())},
+	}
+}
+
+// schedule simulates running the given timings with the given concurrency, the same way
+// the handler does: each step is picked by the first worker that becomes available
+func schedule(timings []timing, concurrency int) timing {
+	if concurrency <= 0 || concurrency > len(timings) {
+		concurrency = len(timings)
+	}
+	if concurrency == 0 {
+		return timing{}
+	}
+	type worker struct {
+		free time.Duration
+		path []string
+	}
+	simulate := func(pick func(Range) time.Duration) (time.Duration, time.Duration, []string) {
+		workers := make([]worker, concurrency)
+		var completion time.Duration
+		for _, t := range timings {
+			next := 0
+			for idx := range workers {
+				if workers[idx].free < workers[next].free {
+					next = idx
+				}
+			}
+			start := workers[next].free
+			if end := start + pick(t.completion); end > completion {
+				completion = end
+			}
+			workers[next].free = start + pick(t.latency)
+			workers[next].path = append(workers[next].path, t.path...)
+		}
+		last := 0
+		for idx := range workers {
+			if workers[idx].free > workers[last].free {
+				last = idx
+			}
+		}
+		if workers[last].free > completion {
+			completion = workers[last].free
+		}
+		return workers[last].free, completion, workers[last].path
+	}
+	minLatency, minCompletion, _ := simulate(func(r Range) time.Duration { return r.Min })
+	maxLatency, maxCompletion, path := simulate(func(r Range) time.Duration { return r.Max })
+	return timing{
+		latency:    Range{Min: minLatency, Max: maxLatency},
+		completion: Range{Min: minCompletion, Max: maxCompletion},
+		path:       path,
+	}
+}
+
+func childLocation(location string, idx int) string {
+	if location == "" {
+		return strconv.Itoa(idx)
+	}
+	return location + "." + strconv.Itoa(idx)
+}
@@ -0,0 +1,131 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var estimatePlan = `
+execution:
+- call:
+  http: GET svc1/listing 200
+  compute: 10ms to 20ms 1.0 cpu
+  execution:
+  - parallel:
+    concurrency: 2
+    execution:
+    - call:
+      http: GET svc2/product?id=1 200
+      compute: 100ms
+    - call:
+      http: GET svc2/product?id=2 200
+      compute: 50ms to 150ms 0.5 cpu
+    - call:
+      http: GET svc2/product?id=3 200
+      compute: 100ms
+  - loop:
+    times: 4
+    concurrency: 2
+    compute: 10ms
+    execution:
+    - call:
+      http: GET svc3/profile 200
+      compute: 20ms 2 cpu
+  - call:
+    async: true
+    http: POST svc4/viewed 200
+    compute: 1s
+  post-execution:
+  - call:
+    http: POST svc4/metrics 200
+    compute: 500ms
+`
+
+func TestEstimate(t *testing.T) {
+	defer func(max float64) { MaxCPU = max }(MaxCPU)
+	MaxCPU = 4
+	estimate := load(t, estimatePlan).Estimate()
+
+	// svc1 compute + parallel block (2 workers, 3 steps) + loop (2 workers, 4 iterations of 30ms)
+	assert.Equal(t,
+		Range{
+			Min: 10*time.Millisecond + 150*time.Millisecond + 60*time.Millisecond,
+			Max: 20*time.Millisecond + 200*time.Millisecond + 60*time.Millisecond,
+		},
+		estimate.Latency,
+	)
+	// async call launched after the loop, taking 1s
+	assert.Equal(t,
+		Range{
+			Min: 10*time.Millisecond + 150*time.Millisecond + 60*time.Millisecond + time.Second,
+			Max: 20*time.Millisecond + 200*time.Millisecond + 60*time.Millisecond + time.Second,
+		},
+		estimate.Completion,
+	)
+	assert.InDelta(t, 0.010+0.025+4*0.040, estimate.CPUSeconds.Min, 0.0001)
+	assert.InDelta(t, 0.020+0.075+4*0.040, estimate.CPUSeconds.Max, 0.0001)
+	assert.Equal(t, map[string]int{"svc1": 1, "svc2": 3, "svc3": 4, "svc4": 2}, estimate.Requests)
+	assert.Equal(t,
+		[]string{
+			"0 call GET http://svc1/listing 200",
+			"0 compute 10ms to 20ms",
+			"0.0.0 call GET http://svc2/product?id=1 200",
+			"0.0.0 compute 100ms",
+			"0.0.2 call GET http://svc2/product?id=3 200",
+			"0.0.2 compute 100ms",
+			"0.1 loop x4 (concurrency 2)",
+			"0.1.0 call GET http://svc3/profile 200",
+			"0.1.0 compute 20ms",
+			"0.1 compute 10ms",
+		},
+		estimate.CriticalPath,
+	)
+}
+
+func TestEstimateServiceOverrides(t *testing.T) {
+	defer func(max float64) { MaxCPU = max }(MaxCPU)
+	MaxCPU = 4
+	plan := load(t, `
+execution:
+- call:
+  http: GET svc1/a 200
+  compute: 10ms 1.0 cpu
+  execution:
+  - call:
+    http: GET svc2:8080/b 200
+    compute: 20ms 0.5 cpu
+service-overrides:
+  svc2:
+    extra-latency: 100ms
+    cpu-multiplier: 3
+`)
+	estimate := plan.Estimate()
+	assert.Equal(t, Range{Min: 130 * time.Millisecond, Max: 130 * time.Millisecond}, estimate.Latency)
+	assert.InDelta(t, 0.010+0.020*1.5, estimate.CPUSeconds.Min, 0.0001)
+	assert.Contains(t, estimate.CriticalPath, "0.0 extra latency 100ms")
+
+	// overrides only apply to the service they target
+	plan.ServiceOverrides = map[string]ServiceOverride{"svc3": {ExtraLatency: time.Second}}
+	estimate = plan.Estimate()
+	assert.Equal(t, Range{Min: 30 * time.Millisecond, Max: 30 * time.Millisecond}, estimate.Latency)
+	assert.InDelta(t, 0.010+0.020*0.5, estimate.CPUSeconds.Min, 0.0001)
+}
+
+func TestEstimateMaxCPU(t *testing.T) {
+	defer func(max float64) { MaxCPU = max }(MaxCPU)
+	plan := load(t, `
+execution:
+- call:
+  http: GET svc1/a 200
+  compute: 100ms 8 cpu
+service-overrides:
+  svc1:
+    cpu-multiplier: 2
+`)
+	MaxCPU = 4
+	assert.InDelta(t, 0.4, plan.Estimate().CPUSeconds.Max, 0.0001)
+	MaxCPU = 32
+	assert.InDelta(t, 1.6, plan.Estimate().CPUSeconds.Max, 0.0001)
+}
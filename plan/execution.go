@@ -31,6 +31,10 @@ func (e *Execution) UnmarshalYAML(node *yaml.Node) error {
 			step = &Parallel{}
 		case StepTypeLoop:
 			step = &Loop{}
+		case StepTypeContend:
+			step = &Contend{}
+		case StepTypeIO:
+			step = &IO{}
 		default:
 			return fmt.Errorf("unrecognized step type %q in line %d", stepType, node.Line)
 		}
@@ -84,6 +88,10 @@ func (e *Execution) UnmarshalJSON(data []byte) error {
 				step = &Parallel{}
 			case StepTypeLoop:
 				step = &Loop{}
+			case StepTypeContend:
+				step = &Contend{}
+			case StepTypeIO:
+				step = &IO{}
 			default:
 				return fmt.Errorf("unrecognized step type %q", stepType)
 			}
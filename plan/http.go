@@ -2,6 +2,8 @@ package plan
 
 import (
 	"fmt"
+	"math"
+	"math/rand"
 	"net/url"
 	"regexp"
 	"strconv"
@@ -18,21 +20,125 @@ import (
 //     characters the body strings should have. Characters are regular ASCII single byte
 //     runes. Check random.String documentation for more
 //
+// The status code and generated body sizes can also be randomly picked from distributions,
+// by setting StatusCodeDistribution, GenRequestBodyDistribution or GenResponseBodyDistribution.
+// In yaml, distributions can also be given directly in the status-code, gen-request-body
+// and gen-response-body fields. See Distribution for more
+//
+// Generated bodies are alphanumeric by default. Other formats, like JSON documents, compressible
+// text or binary content, can be picked with GenRequestBodyFormat and GenResponseBodyFormat.
+// See BodyFormat for more
+//
+// Response bodies can be compressed according to the request Accept-Encoding by setting
+// Compression. See Compression for more
+//
+// The HTTP protocol version, like HTTP/2 over cleartext (h2c), can be picked with Protocol.
+// See Protocol for more
+//
+// Calls to https urls can set how TLS is set up, like the trusted authorities or client
+// certificates, with TLS. See TLS for more
+//
 // See also the HTTP.Parse function for creating HTTP structs from simple strings
 type HTTP struct {
-	Method          string            `json:"method" yaml:"method"`
-	URL             URL               `json:"url" yaml:"url"`
-	StatusCode      int               `json:"status-code" yaml:"status-code"`
-	RequestBody     string            `json:"request-body,omitempty" yaml:"request-body,omitempty"`
-	ResponseBody    string            `json:"response-body,omitempty" yaml:"response-body,omitempty"`
-	GenRequestBody  int               `json:"gen-request-body,omitempty" yaml:"gen-request-body,omitempty"`
-	GenResponseBody int               `json:"gen-response-body,omitempty" yaml:"gen-response-body,omitempty"`
-	RequestHeaders  map[string]string `json:"request-headers,omitempty" yaml:"request-headers,omitempty"`
-	ResponseHeaders map[string]string `json:"response-headers,omitempty" yaml:"response-headers,omitempty"`
+	Method                      string            `json:"method" yaml:"method"`
+	URL                         URL               `json:"url" yaml:"url"`
+	StatusCode                  int               `json:"status-code" yaml:"status-code"`
+	StatusCodeDistribution      *Distribution     `json:"status-code-distribution,omitempty" yaml:"status-code-distribution,omitempty"`
+	RequestBody                 string            `json:"request-body,omitempty" yaml:"request-body,omitempty"`
+	ResponseBody                string            `json:"response-body,omitempty" yaml:"response-body,omitempty"`
+	GenRequestBody              int               `json:"gen-request-body,omitempty" yaml:"gen-request-body,omitempty"`
+	GenRequestBodyDistribution  *Distribution     `json:"gen-request-body-distribution,omitempty" yaml:"gen-request-body-distribution,omitempty"`
+	GenResponseBody             int               `json:"gen-response-body,omitempty" yaml:"gen-response-body,omitempty"`
+	GenResponseBodyDistribution *Distribution     `json:"gen-response-body-distribution,omitempty" yaml:"gen-response-body-distribution,omitempty"`
+	GenRequestBodyFormat        *BodyFormat       `json:"gen-request-body-format,omitempty" yaml:"gen-request-body-format,omitempty"`
+	GenResponseBodyFormat       *BodyFormat       `json:"gen-response-body-format,omitempty" yaml:"gen-response-body-format,omitempty"`
+	Compression                 *Compression      `json:"compression,omitempty" yaml:"compression,omitempty"`
+	RequestHeaders              map[string]string `json:"request-headers,omitempty" yaml:"request-headers,omitempty"`
+	ResponseHeaders             map[string]string `json:"response-headers,omitempty" yaml:"response-headers,omitempty"`
+	Protocol                    Protocol          `json:"protocol,omitempty" yaml:"protocol,omitempty"`
+	TLS                         *TLS              `json:"tls,omitempty" yaml:"tls,omitempty"`
 }
 
 func (h *HTTP) String() string {
-	return fmt.Sprintf("%s %s %d", h.Method, h.URL.String(), h.StatusCode)
+	return fmt.Sprintf("%s %s %s", h.Method, h.URL.String(), h.StatusCodeString())
+}
+
+// Validate checks the protocol, which must match the url scheme
+func (h *HTTP) Validate() error {
+	if err := h.Protocol.Validate(); err != nil {
+		return fmt.Errorf("invalid http call: %w", err)
+	}
+	if h.Protocol == ProtocolH2 && (h.URL.URL == nil || h.URL.Scheme != "https") {
+		return fmt.Errorf("invalid http call: protocol %s requires an https url (%s)", h.Protocol, h.URL.String())
+	}
+	return nil
+}
+
+// StatusCodeString describes the status code, or its distribution if one is set
+func (h *HTTP) StatusCodeString() string {
+	if h.StatusCodeDistribution != nil {
+		return h.StatusCodeDistribution.String()
+	}
+	statusCode := h.StatusCode
+	if statusCode == 0 {
+		statusCode = 200
+	}
+	return strconv.Itoa(statusCode)
+}
+
+// PickStatusCode returns the status code to respond with, defaulting to 200
+func (h *HTTP) PickStatusCode(rnd *rand.Rand) int {
+	statusCode := h.StatusCode
+	if h.StatusCodeDistribution != nil {
+		statusCode = int(math.Round(h.StatusCodeDistribution.Sample(rnd)))
+	}
+	if statusCode == 0 {
+		statusCode = 200
+	}
+	return statusCode
+}
+
+// PickRequestBodySize returns how many bytes should be generated for the request body
+func (h *HTTP) PickRequestBodySize(rnd *rand.Rand) int {
+	return pickSize(h.GenRequestBody, h.GenRequestBodyDistribution, rnd)
+}
+
+// PickResponseBodySize returns how many bytes should be generated for the response body
+func (h *HTTP) PickResponseBodySize(rnd *rand.Rand) int {
+	return pickSize(h.GenResponseBody, h.GenResponseBodyDistribution, rnd)
+}
+
+// GetRequestBody returns the request body to send and its content type. The content type is
+// empty for explicitly defined bodies. The body is nil if there is no body to send
+func (h *HTTP) GetRequestBody(rnd *rand.Rand) ([]byte, string) {
+	return getBody(h.RequestBody, h.GenRequestBodyFormat, h.PickRequestBodySize(rnd), rnd)
+}
+
+// GetResponseBody returns the response body to send and its content type. The content type is
+// empty for explicitly defined bodies. The body is nil if there is no body to send
+func (h *HTTP) GetResponseBody(rnd *rand.Rand) ([]byte, string) {
+	return getBody(h.ResponseBody, h.GenResponseBodyFormat, h.PickResponseBodySize(rnd), rnd)
+}
+
+func getBody(explicit string, format *BodyFormat, size int, rnd *rand.Rand) ([]byte, string) {
+	if explicit != "" {
+		return []byte(explicit), ""
+	}
+	body := format.Generate(rnd, size)
+	if body == nil {
+		return nil, ""
+	}
+	return body, format.ContentTypeHeader()
+}
+
+func pickSize(size int, distribution *Distribution, rnd *rand.Rand) int {
+	if distribution != nil {
+		size = int(math.Round(distribution.Sample(rnd)))
+	}
+	if size < 0 {
+		size = 0
+	}
+	return size
 }
 
 // The regex pattern used in the HTTP.Parse function
@@ -41,10 +147,10 @@ const HTTPPattern = `` +
 	`(\w+)\s+` +
 	// URL
 	`([^\s]+)\s+` +
-	// Status code
-	`(\d+)` +
-	// Optional request body and response body sizes
-	`(?:\s+(\d+)\s+(\d+))?`
+	// Status code or its distribution
+	`(\d+|\w+\([^)]*\))` +
+	// Optional request body and response body sizes, or their distributions
+	`(?:\s+(\d+|\w+\([^)]*\))\s+(\d+|\w+\([^)]*\)))?`
 
 var httpPattern = regexp.MustCompile(HTTPPattern)
 
@@ -53,27 +159,27 @@ var httpPattern = regexp.MustCompile(HTTPPattern)
 //     GET some/url?with=multiple&query=params 200
 //   - Method, url, status code, request body size and response body size (both randomly generated):
 //     POST some/url?with=multiple&query=params 200 500 1048
+//   - Any of status code, request body size and response body size as distributions:
+//     GET some/url weighted(200=99%, 500=1%) 0 lognormal(10kb, 0.5)
 func (h *HTTP) Parse(s string) error {
 	parts := httpPattern.FindStringSubmatch(s)
 	if parts == nil {
 		return fmt.Errorf("cannot parse http definition %q", s)
 	}
-	statusCode, err := strconv.Atoi(parts[3])
+	statusCode, statusCodeDistribution, err := parseIntOrDistribution(parts[3])
 	if err != nil {
-		return fmt.Errorf("cannot parse http definition %q: status code is not an integer: %w", s, err)
+		return fmt.Errorf("cannot parse http definition %q: bad status code: %w", s, err)
 	}
-	var reqBodySize int
+	var reqBodySize, respBodySize int
+	var reqBodyDistribution, respBodyDistribution *Distribution
 	if parts[4] != "" {
-		reqBodySize, err = strconv.Atoi(parts[4])
+		reqBodySize, reqBodyDistribution, err = parseIntOrDistribution(parts[4])
 		if err != nil {
-			return fmt.Errorf("cannot parse http definition %q: request body size is not an integer: %w", s, err)
+			return fmt.Errorf("cannot parse http definition %q: bad request body size: %w", s, err)
 		}
-	}
-	var respBodySize int
-	if parts[4] != "" {
-		respBodySize, err = strconv.Atoi(parts[5])
+		respBodySize, respBodyDistribution, err = parseIntOrDistribution(parts[5])
 		if err != nil {
-			return fmt.Errorf("cannot parse http definition %q: response body size is not an integer: %w", s, err)
+			return fmt.Errorf("cannot parse http definition %q: bad response body size: %w", s, err)
 		}
 	}
 	urlString := parts[2]
@@ -87,11 +193,23 @@ func (h *HTTP) Parse(s string) error {
 	h.Method = parts[1]
 	h.URL = URL{URL: url}
 	h.StatusCode = statusCode
+	h.StatusCodeDistribution = statusCodeDistribution
 	h.GenRequestBody = reqBodySize
+	h.GenRequestBodyDistribution = reqBodyDistribution
 	h.GenResponseBody = respBodySize
+	h.GenResponseBodyDistribution = respBodyDistribution
 	return nil
 }
 
+func parseIntOrDistribution(s string) (int, *Distribution, error) {
+	if strings.Contains(s, "(") {
+		distribution, err := ParseDistribution(s)
+		return 0, distribution, err
+	}
+	value, err := strconv.Atoi(s)
+	return value, nil, err
+}
+
 func (h *HTTP) UnmarshalYAML(node *yaml.Node) error {
 	if node.Value != "" {
 		if err := h.Parse(node.Value); err != nil {
@@ -99,6 +217,22 @@ func (h *HTTP) UnmarshalYAML(node *yaml.Node) error {
 		}
 		return nil
 	}
+	// distributions can be given in place of the numeric fields they are related to
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		key, value := node.Content[idx], node.Content[idx+1]
+		switch key.Value {
+		case "status-code", "gen-request-body", "gen-response-body":
+			if value.Kind == yaml.MappingNode || strings.Contains(value.Value, "(") {
+				key.Value += "-distribution"
+			}
+		}
+	}
 	type rawHTTP HTTP
-	return node.Decode((*rawHTTP)(h))
+	if err := node.Decode((*rawHTTP)(h)); err != nil {
+		return err
+	}
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("invalid http definition at line %d: %w", node.Line, err)
+	}
+	return nil
 }
@@ -0,0 +1,172 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bcap/kaller/disk"
+	"gopkg.in/yaml.v3"
+)
+
+// IO represents that the service should read or write files before moving to the next step,
+// which simulates services whose latency is bound by disk I/O instead of cpu
+//
+// Size bytes are read or written, BlockSize bytes at a time (4kb by default). Blocks are
+// accessed sequentially by default, or at random offsets with the random Pattern. Written data
+// is by default left for the operating system to flush, but the Fsync policy can make writes
+// be flushed to the storage device once all data is written (end) or after every block
+// (always). Rate caps the throughput, making the step take at least Size / Rate
+//
+// Reads are likely served from the page cache, since the files read are reused across
+// requests. Direct makes reads and writes bypass the page cache (linux only, and BlockSize must
+// be a multiple of 4kb)
+//
+// Files are created in the scratch directory of the service (the system temporary directory
+// unless configured otherwise), or in Dir, a subdirectory of it that is created if missing.
+// Dir must be a relative path that does not leave the scratch directory, so plans cannot make
+// services read or write files anywhere else. See disk.Run for more
+//
+// IO steps can also be written in a compact form: the operation and the size, optionally
+// followed by the block size, the pattern, the fsync policy, the rate cap and direct, eg:
+//
+//	io: write 10mb 64kb random fsync(end) 50mb/s direct
+type IO struct {
+	Op        disk.Op      `json:"op" yaml:"op"`
+	Size      ByteSize     `json:"size" yaml:"size"`
+	BlockSize ByteSize     `json:"block-size,omitempty" yaml:"block-size,omitempty"`
+	Pattern   disk.Pattern `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	Fsync     disk.Fsync   `json:"fsync,omitempty" yaml:"fsync,omitempty"`
+	Rate      ByteRate     `json:"rate,omitempty" yaml:"rate,omitempty"`
+	Direct    bool         `json:"direct,omitempty" yaml:"direct,omitempty"`
+	Dir       string       `json:"dir,omitempty" yaml:"dir,omitempty"`
+}
+
+func (IO) StepType() StepType {
+	return StepTypeIO
+}
+
+func (i IO) String() string {
+	parts := []string{string(i.Op), i.Size.String()}
+	if i.BlockSize > 0 {
+		parts = append(parts, i.BlockSize.String())
+	}
+	if i.Pattern != "" {
+		parts = append(parts, string(i.Pattern))
+	}
+	if i.Fsync != "" {
+		parts = append(parts, fmt.Sprintf("fsync(%s)", i.Fsync))
+	}
+	if i.Rate > 0 {
+		parts = append(parts, i.Rate.String())
+	}
+	if i.Direct {
+		parts = append(parts, "direct")
+	}
+	return strings.Join(parts, " ")
+}
+
+func (i *IO) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value != "" {
+		if err := i.Parse(node.Value); err != nil {
+			return fmt.Errorf("invalid io at line %d: %w", node.Line, err)
+		}
+		return nil
+	}
+	type raw IO
+	if err := node.Decode((*raw)(i)); err != nil {
+		return err
+	}
+	return i.Validate()
+}
+
+// Parse parses the compact form of IO steps, eg: "write 10mb 64kb random fsync(end) 50mb/s"
+func (i *IO) Parse(s string) error {
+	fields := strings.Fields(s)
+	if len(fields) < 2 {
+		return fmt.Errorf("%q should have at least an operation and a size, eg: read 10mb", s)
+	}
+	parsed := IO{Op: disk.Op(strings.ToLower(fields[0]))}
+	size, err := ParseByteSize(fields[1])
+	if err != nil {
+		return err
+	}
+	parsed.Size = size
+	for _, field := range fields[2:] {
+		lower := strings.ToLower(field)
+		switch {
+		case lower == string(disk.Sequential) || lower == string(disk.Random):
+			parsed.Pattern = disk.Pattern(lower)
+		case lower == "direct":
+			parsed.Direct = true
+		case strings.HasPrefix(lower, "fsync(") && strings.HasSuffix(lower, ")"):
+			parsed.Fsync = disk.Fsync(strings.TrimSuffix(strings.TrimPrefix(lower, "fsync("), ")"))
+		case strings.HasSuffix(lower, "/s"):
+			rate, err := ParseByteRate(lower)
+			if err != nil {
+				return err
+			}
+			parsed.Rate = rate
+		default:
+			blockSize, err := ParseByteSize(lower)
+			if err != nil {
+				return fmt.Errorf("unrecognized io option %q", field)
+			}
+			parsed.BlockSize = blockSize
+		}
+	}
+	if err := parsed.Validate(); err != nil {
+		return err
+	}
+	*i = parsed
+	return nil
+}
+
+func (i IO) Validate() error {
+	if err := i.options("").Validate(); err != nil {
+		return fmt.Errorf("invalid io: %w", err)
+	}
+	if i.Dir != "" && !filepath.IsLocal(i.Dir) {
+		return fmt.Errorf("invalid io: dir must be a relative path within the scratch directory (dir: %s)", i.Dir)
+	}
+	return nil
+}
+
+// Do runs the I/O, creating files in the given scratch directory, or in the step Dir within
+// it. The given random generator is used for random access patterns and to generate written
+// data
+func (i IO) Do(ctx context.Context, scratchDir string, rnd *rand.Rand) (disk.Result, error) {
+	if err := i.Validate(); err != nil {
+		return disk.Result{}, err
+	}
+	opts := i.options(scratchDir)
+	if i.Dir != "" {
+		if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+			return disk.Result{}, fmt.Errorf("failed to create io dir: %w", err)
+		}
+	}
+	return disk.Run(ctx, opts, rnd)
+}
+
+func (i IO) options(scratchDir string) disk.Options {
+	dir := scratchDir
+	if i.Dir != "" {
+		if dir == "" {
+			dir = os.TempDir()
+		}
+		dir = filepath.Join(dir, i.Dir)
+	}
+	return disk.Options{
+		Op:        i.Op,
+		Size:      int64(i.Size),
+		BlockSize: int(i.BlockSize),
+		Pattern:   i.Pattern,
+		Fsync:     i.Fsync,
+		Rate:      int64(i.Rate),
+		Direct:    i.Direct,
+		Dir:       dir,
+	}
+}
@@ -0,0 +1,80 @@
+package plan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bcap/kaller/disk"
+	"github.com/bcap/kaller/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var ioPlan = `
+execution:
+- io: write 10mb 64kb random fsync(end) 50mb/s
+- io:
+    op: read
+    size: 1mb
+    direct: true
+    dir: data/reads
+`
+
+func TestIO(t *testing.T) {
+	plan := load(t, ioPlan)
+	require.Equal(t, 2, len(plan.Execution))
+
+	write := plan.Execution[0].(*IO)
+	assert.Equal(t, IO{
+		Op:        disk.Write,
+		Size:      10 * 1024 * 1024,
+		BlockSize: 64 * 1024,
+		Pattern:   disk.Random,
+		Fsync:     disk.FsyncEnd,
+		Rate:      50 * 1024 * 1024,
+	}, *write)
+	assert.Equal(t, "write 10mb 64kb random fsync(end) 50mb/s", write.String())
+
+	read := plan.Execution[1].(*IO)
+	assert.Equal(t, IO{Op: disk.Read, Size: 1024 * 1024, Direct: true, Dir: "data/reads"}, *read)
+
+	estimate := plan.Estimate()
+	// the write is bound by its rate cap, the read by the assumed disk throughput
+	assert.Equal(t, 200*time.Millisecond+488281*time.Nanosecond, estimate.Latency.Min)
+	assert.Equal(t, 210*time.Millisecond, estimate.Latency.Max)
+	assert.Equal(t, "0 io write 10mb 64kb random fsync(end) 50mb/s", estimate.CriticalPath[0])
+
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err := FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	io := IO{}
+	assert.Error(t, io.Parse("write"))
+	assert.Error(t, io.Parse("delete 10mb"))
+	assert.Error(t, io.Parse("read 10ms"))
+	assert.Error(t, io.Parse("read 10mb fsync(sometimes)"))
+	assert.Error(t, io.Parse("read 10mb 1000 direct"))
+
+	for _, dir := range []string{"/var/lib/data", "../data", "data/../../etc"} {
+		_, err := FromYAML([]byte("execution:\n- io:\n    op: write\n    size: 1kb\n    dir: " + dir + "\n"))
+		assert.ErrorContains(t, err, "dir must be a relative path within the scratch directory", dir)
+	}
+}
+
+func TestIODir(t *testing.T) {
+	scratch := t.TempDir()
+	io := IO{Op: disk.Read, Size: 1024, Dir: "data/reads"}
+	_, err := io.Do(context.Background(), scratch, random.New(1))
+	require.NoError(t, err)
+	_, err = os.Stat(filepath.Join(scratch, "data", "reads", "kaller-read"))
+	assert.NoError(t, err)
+
+	io.Dir = "../escape"
+	_, err = io.Do(context.Background(), scratch, random.New(1))
+	assert.Error(t, err)
+}
@@ -3,6 +3,8 @@ package plan
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
@@ -13,8 +15,58 @@ import (
 // As of now the Plan is serialized and sent to all services that participate in the
 // call mesh. For more details on how this is transported check handler.WritePlanHeaders and
 // handler.ReadPlanHeaders
+//
+// Seed makes runs of the plan reproducible: all random decisions (compute durations,
+// generated bodies, etc) are derived from the seed, the location of the step in the plan
+// and the loop iteration it runs in. Each kind of decision taken for a step draws from its own
+// random stream, so they are independent from each other. A zero seed means a random seed is picked for each run
+//
+// ServiceOverrides allow changing the behaviour of specific services, identified by the
+// name kaller servers are started with. See ServiceOverride
 type Plan struct {
-	Execution Execution `json:"execution" yaml:"execution"`
+	Seed             int64                      `json:"seed,omitempty" yaml:"seed,omitempty"`
+	Execution        Execution                  `json:"execution" yaml:"execution"`
+	ServiceOverrides map[string]ServiceOverride `json:"service-overrides,omitempty" yaml:"service-overrides,omitempty"`
+}
+
+// Validate checks the parts of the plan that are not validated by the steps themselves, like
+// the service overrides and the transport settings of calls. Plans are validated when decoded
+func (p Plan) Validate() error {
+	services := make([]string, 0, len(p.ServiceOverrides))
+	for service := range p.ServiceOverrides {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	for _, service := range services {
+		if err := p.ServiceOverrides[service].Validate(); err != nil {
+			return fmt.Errorf("invalid plan: service %q: %w", service, err)
+		}
+	}
+	var err error
+	Walk(p.Execution, func(step Step) {
+		if call, ok := step.(*Call); ok && err == nil {
+			if callErr := call.Validate(); callErr != nil {
+				err = fmt.Errorf("invalid plan: call %s: %w", call.String(), callErr)
+			}
+		}
+	})
+	return err
+}
+
+func (p *Plan) UnmarshalYAML(node *yaml.Node) error {
+	type rawPlan Plan
+	if err := node.Decode((*rawPlan)(p)); err != nil {
+		return err
+	}
+	return p.Validate()
+}
+
+func (p *Plan) UnmarshalJSON(data []byte) error {
+	type rawPlan Plan
+	if err := json.Unmarshal(data, (*rawPlan)(p)); err != nil {
+		return err
+	}
+	return p.Validate()
 }
 
 func FromJSON(data []byte) (Plan, error) {
@@ -0,0 +1,42 @@
+package plan
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Protocol is the HTTP protocol http and sse calls are made with:
+//   - http1: HTTP/1.1, with a single request in flight per connection
+//   - h2c: HTTP/2 over cleartext, multiplexing concurrent requests over a single connection
+//   - h2: HTTP/2 over TLS, which requires an https url
+//
+// When unset, calls use HTTP/1.1 over cleartext and whatever protocol gets negotiated over TLS.
+// Kaller servers accept all protocols on the same port
+type Protocol string
+
+const (
+	ProtocolHTTP1 Protocol = "http1"
+	ProtocolH2C   Protocol = "h2c"
+	ProtocolH2    Protocol = "h2"
+)
+
+func (p Protocol) Validate() error {
+	switch p {
+	case "", ProtocolHTTP1, ProtocolH2C, ProtocolH2:
+		return nil
+	}
+	return fmt.Errorf("invalid protocol %q, must be one of %s, %s or %s", p, ProtocolHTTP1, ProtocolH2C, ProtocolH2)
+}
+
+func (p *Protocol) UnmarshalYAML(node *yaml.Node) error {
+	var str string
+	if err := node.Decode(&str); err != nil {
+		return err
+	}
+	if err := Protocol(str).Validate(); err != nil {
+		return fmt.Errorf("invalid protocol at line %d: %w", node.Line, err)
+	}
+	*p = Protocol(str)
+	return nil
+}
@@ -0,0 +1,40 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestProtocolParse(t *testing.T) {
+	var http HTTP
+	require.NoError(t, yaml.Unmarshal([]byte("{method: GET, url: http://a/b, protocol: h2c}"), &http))
+	assert.Equal(t, ProtocolH2C, http.Protocol)
+
+	encoded, err := yaml.Marshal(http)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), "protocol: h2c")
+
+	var protocol Protocol
+	assert.NoError(t, yaml.Unmarshal([]byte("http1"), &protocol))
+	assert.Equal(t, ProtocolHTTP1, protocol)
+	assert.Error(t, yaml.Unmarshal([]byte("http3"), &protocol))
+	assert.Error(t, Protocol("spdy").Validate())
+}
+
+func TestProtocolH2RequiresHTTPS(t *testing.T) {
+	var http HTTP
+	assert.NoError(t, yaml.Unmarshal([]byte("{method: GET, url: https://a/b, protocol: h2}"), &http))
+	assert.ErrorContains(t, yaml.Unmarshal([]byte("{method: GET, url: http://a/b, protocol: h2}"), &http), "requires an https url")
+
+	plan, err := FromYAML([]byte("execution:\n- call:\n    http: GET https://a/b 200\n"))
+	require.NoError(t, err)
+	plan.Execution[0].(*Call).HTTP.Protocol = ProtocolH2
+	plan.Execution[0].(*Call).HTTP.URL = MustParseURL("http://a/b")
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	_, err = FromJSON(encoded)
+	assert.ErrorContains(t, err, "invalid plan: call GET http://a/b 200: invalid http call: protocol h2 requires an https url")
+}
@@ -0,0 +1,125 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Unit string
+
+const (
+	UnitNone     Unit = ""
+	UnitDuration Unit = "duration"
+	UnitBytes    Unit = "bytes"
+)
+
+// Quantity is a numeric value with an optional unit. Quantities are written as:
+//   - durations: "10ms", "1.5s". The value is stored in nanoseconds
+//   - sizes: "100b", "10kb", "2mb", "1gb". The value is stored in bytes
+//   - plain numbers: "200", "1.5"
+type Quantity struct {
+	Value float64
+	Unit  Unit
+}
+
+func Duration(d time.Duration) Quantity {
+	return Quantity{Value: float64(d), Unit: UnitDuration}
+}
+
+func Bytes(b int) Quantity {
+	return Quantity{Value: float64(b), Unit: UnitBytes}
+}
+
+func Number(n float64) Quantity {
+	return Quantity{Value: n}
+}
+
+var sizePattern = regexp.MustCompile(`^(\d+(?:\.\d+)?)\s*(b|kb|mb|gb)$`)
+
+func ParseQuantity(s string) (Quantity, error) {
+	s = strings.TrimSpace(s)
+	if number, err := strconv.ParseFloat(s, 64); err == nil {
+		return Number(number), nil
+	}
+	if parts := sizePattern.FindStringSubmatch(strings.ToLower(s)); parts != nil {
+		value, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return Quantity{}, fmt.Errorf("invalid size %q: %w", s, err)
+		}
+		switch parts[2] {
+		case "kb":
+			value *= 1024
+		case "mb":
+			value *= 1024 * 1024
+		case "gb":
+			value *= 1024 * 1024 * 1024
+		}
+		return Quantity{Value: value, Unit: UnitBytes}, nil
+	}
+	if duration, err := time.ParseDuration(s); err == nil {
+		return Duration(duration), nil
+	}
+	return Quantity{}, fmt.Errorf("invalid quantity %q: not a duration, size or number", s)
+}
+
+func (q Quantity) IsZero() bool {
+	return q.Value == 0
+}
+
+func (q Quantity) String() string {
+	switch q.Unit {
+	case UnitDuration:
+		return time.Duration(q.Value).String()
+	case UnitBytes:
+		bytes := int64(math.Round(q.Value))
+		switch {
+		case bytes != 0 && bytes%(1024*1024*1024) == 0:
+			return fmt.Sprintf("%dgb", bytes/(1024*1024*1024))
+		case bytes != 0 && bytes%(1024*1024) == 0:
+			return fmt.Sprintf("%dmb", bytes/(1024*1024))
+		case bytes != 0 && bytes%1024 == 0:
+			return fmt.Sprintf("%dkb", bytes/1024)
+		default:
+			return fmt.Sprintf("%db", bytes)
+		}
+	default:
+		return strconv.FormatFloat(q.Value, 'g', -1, 64)
+	}
+}
+
+func (q Quantity) MarshalYAML() (interface{}, error) {
+	return q.String(), nil
+}
+
+func (q *Quantity) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseQuantity(node.Value)
+	if err != nil {
+		return fmt.Errorf("invalid quantity at line %d: %w", node.Line, err)
+	}
+	*q = parsed
+	return nil
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+func (q *Quantity) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := ParseQuantity(str)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
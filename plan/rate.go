@@ -0,0 +1,58 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteRate is an amount of bytes per second. Rates are written as sizes per second, eg: "200mb/s"
+type ByteRate float64
+
+func ParseByteRate(s string) (ByteRate, error) {
+	size := strings.TrimSuffix(strings.TrimSpace(s), "/s")
+	quantity, err := ParseQuantity(size)
+	if err != nil || (quantity.Unit != UnitBytes && quantity.Unit != UnitNone) {
+		return 0, fmt.Errorf("invalid rate %q: expected a size per second, eg: 200mb/s", s)
+	}
+	if quantity.Value < 0 {
+		return 0, fmt.Errorf("invalid rate %q: negative rate", s)
+	}
+	return ByteRate(quantity.Value), nil
+}
+
+func (r ByteRate) String() string {
+	return Quantity{Value: float64(r), Unit: UnitBytes}.String() + "/s"
+}
+
+func (r ByteRate) MarshalYAML() (interface{}, error) {
+	return r.String(), nil
+}
+
+func (r *ByteRate) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseByteRate(node.Value)
+	if err != nil {
+		return fmt.Errorf("invalid rate at line %d: %w", node.Line, err)
+	}
+	*r = parsed
+	return nil
+}
+
+func (r ByteRate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.String())
+}
+
+func (r *ByteRate) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := ParseByteRate(str)
+	if err != nil {
+		return err
+	}
+	*r = parsed
+	return nil
+}
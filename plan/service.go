@@ -0,0 +1,46 @@
+package plan
+
+import (
+	"fmt"
+	"time"
+)
+
+// ServiceOverride changes how a given service behaves for all calls it receives, without
+// having to change each call individually. Overrides are keyed by service name in the plan
+// and are applied by kaller servers started with a matching service name
+//
+// The following overrides are available:
+//   - ExtraLatency is added before the call compute
+//   - ErrorRate is the chance (0.0 to 1.0) of the call failing right after the compute,
+//     skipping its execution steps. Failed calls respond with ErrorStatusCode (500 by default)
+//   - CPUMultiplier scales the cpu load of all computes done by the service
+type ServiceOverride struct {
+	ExtraLatency    time.Duration `json:"extra-latency,omitempty" yaml:"extra-latency,omitempty"`
+	ErrorRate       float64       `json:"error-rate,omitempty" yaml:"error-rate,omitempty"`
+	ErrorStatusCode int           `json:"error-status-code,omitempty" yaml:"error-status-code,omitempty"`
+	CPUMultiplier   float64       `json:"cpu-multiplier,omitempty" yaml:"cpu-multiplier,omitempty"`
+}
+
+func (o ServiceOverride) Validate() error {
+	if o.ExtraLatency < 0 {
+		return fmt.Errorf("invalid service override: extra latency is negative (%v)", o.ExtraLatency)
+	}
+	if o.ErrorRate < 0 || o.ErrorRate > 1 {
+		return fmt.Errorf("invalid service override: error rate must be between 0.0 and 1.0 (%v)", o.ErrorRate)
+	}
+	if o.ErrorStatusCode != 0 && (o.ErrorStatusCode < 100 || o.ErrorStatusCode > 599) {
+		return fmt.Errorf("invalid service override: error status code must be between 100 and 599 (%d)", o.ErrorStatusCode)
+	}
+	if o.CPUMultiplier < 0 {
+		return fmt.Errorf("invalid service override: cpu multiplier is negative (%v)", o.CPUMultiplier)
+	}
+	return nil
+}
+
+// ApplyCPU returns the compute with its cpu load scaled by the CPUMultiplier, if set
+func (o ServiceOverride) ApplyCPU(compute Compute) Compute {
+	if o.CPUMultiplier > 0 {
+		compute.CPU *= o.CPUMultiplier
+	}
+	return compute
+}
@@ -0,0 +1,49 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var serviceOverridesPlan = `
+execution:
+- call:
+  http: GET svc1/a 200
+service-overrides:
+  svc1:
+    extra-latency: 10ms
+    error-rate: 0.1
+    error-status-code: 503
+    cpu-multiplier: 2
+`
+
+func TestServiceOverrides(t *testing.T) {
+	plan := load(t, serviceOverridesPlan)
+	assert.Equal(t,
+		map[string]ServiceOverride{
+			"svc1": {ExtraLatency: 10 * time.Millisecond, ErrorRate: 0.1, ErrorStatusCode: 503, CPUMultiplier: 2},
+		},
+		plan.ServiceOverrides,
+	)
+
+	for _, invalid := range []string{
+		"extra-latency: -1ms",
+		"error-rate: 1.5",
+		"error-rate: -0.1",
+		"error-status-code: 1000",
+		"cpu-multiplier: -2",
+	} {
+		yaml := "execution: []\nservice-overrides:\n  svc1:\n    " + invalid + "\n"
+		_, err := FromYAML([]byte(yaml))
+		assert.ErrorContains(t, err, `invalid plan: service "svc1"`, invalid)
+	}
+
+	plan.ServiceOverrides["svc1"] = ServiceOverride{ErrorRate: 2}
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	_, err = FromJSON(encoded)
+	assert.ErrorContains(t, err, `invalid plan: service "svc1"`)
+}
@@ -0,0 +1,58 @@
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ByteSize is an amount of bytes. Sizes are written as "512b", "4kb", "10mb" or "1gb", or as a
+// plain number of bytes
+type ByteSize int64
+
+func ParseByteSize(s string) (ByteSize, error) {
+	quantity, err := ParseQuantity(s)
+	if err != nil || (quantity.Unit != UnitBytes && quantity.Unit != UnitNone) {
+		return 0, fmt.Errorf("invalid size %q: expected a size, eg: 10mb", s)
+	}
+	if quantity.Value < 0 {
+		return 0, fmt.Errorf("invalid size %q: negative size", s)
+	}
+	return ByteSize(math.Round(quantity.Value)), nil
+}
+
+func (b ByteSize) String() string {
+	return Bytes(int(b)).String()
+}
+
+func (b ByteSize) MarshalYAML() (interface{}, error) {
+	return b.String(), nil
+}
+
+func (b *ByteSize) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseByteSize(node.Value)
+	if err != nil {
+		return fmt.Errorf("invalid size at line %d: %w", node.Line, err)
+	}
+	*b = parsed
+	return nil
+}
+
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.String())
+}
+
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return err
+	}
+	parsed, err := ParseByteSize(str)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
@@ -0,0 +1,93 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSocketPort is the port TCP and UDP calls target when their address has no port. It is
+// the port deployments generated from plans make kaller servers listen to for TCP and UDP calls
+const DefaultSocketPort = 9000
+
+// DefaultGRPCPort is the port gRPC calls target when their address has no port. It is the port
+// deployments generated from plans make kaller servers listen to for gRPC calls
+const DefaultGRPCPort = 9090
+
+// Socket represents a call to another kaller over a transport other than HTTP:
+//   - tcp: a raw TCP connection, using kaller framing (see the frame package)
+//   - udp: UDP datagrams, also using kaller framing
+//   - grpc: the generic kaller gRPC service (see the rpc package)
+//
+// This allows exercising L4 load balancers, gRPC proxies and non-HTTP mesh traffic.
+//
+// Sockets describe requests and responses the same way HTTP calls do, with status codes,
+// generated bodies, headers and compression, except there is no method. They can also be
+// written in the same compact form, eg:
+//
+//	tcp: orders:9000/reserve 200 100 1000
+//
+// gRPC calls respond with the gRPC code related to the status code, eg: 404 responds with
+// NOT_FOUND and 503 with UNAVAILABLE. See rpc.Code for the mapping
+//
+// UDP calls send the request in a single datagram and wait for a single datagram in response,
+// which limits the size of messages, including the encoded plan, to frame.MaxDatagramSize.
+// Datagrams are not retransmitted: a lost datagram fails the call once its reply timeout expires
+//
+// Sockets are always plaintext: the Protocol and TLS settings of HTTP calls are not supported
+type Socket struct {
+	HTTP
+}
+
+// Validate checks that the socket does not use the HTTP settings sockets do not support
+func (s *Socket) Validate() error {
+	if s.Protocol != "" {
+		return fmt.Errorf("invalid socket: protocol %s is not supported, sockets are not made over http", s.Protocol)
+	}
+	if s.TLS != nil {
+		return errors.New("invalid socket: tls is not supported")
+	}
+	return nil
+}
+
+func (s *Socket) String() string {
+	if s.URL.URL == nil {
+		return s.StatusCodeString()
+	}
+	return fmt.Sprintf("%s%s %s", s.URL.Host, s.URL.RequestURI(), s.StatusCodeString())
+}
+
+// Parse parses a socket call from a string. The format is the same as HTTP.Parse, without the
+// method, eg: orders:9000/reserve 200 100 1000
+func (s *Socket) Parse(str string) error {
+	parsed := HTTP{}
+	if err := parsed.Parse("SEND " + str); err != nil {
+		return fmt.Errorf("cannot parse socket definition %q", str)
+	}
+	parsed.Method = ""
+	s.HTTP = parsed
+	return nil
+}
+
+func (s Socket) MarshalYAML() (interface{}, error) {
+	return s.HTTP, nil
+}
+
+func (s *Socket) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value != "" {
+		if err := s.Parse(node.Value); err != nil {
+			return fmt.Errorf("invalid socket definition at line %d: %w", node.Line, err)
+		}
+		return nil
+	}
+	// urls are commonly given as host:port/path, which is not a valid url without a scheme
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		key, value := node.Content[idx], node.Content[idx+1]
+		if key.Value == "url" && value.Value != "" && !strings.Contains(value.Value, "://") {
+			value.Value = "http://" + value.Value
+		}
+	}
+	return node.Decode(&s.HTTP)
+}
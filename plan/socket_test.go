@@ -0,0 +1,87 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var socketPlan = `
+execution:
+- call:
+    tcp: orders:7000/reserve 201 100 lognormal(1kb, 0.5)
+    execution:
+    - call:
+      udp: stats/hit 200
+- call:
+    http: GET orders/list 200
+- call:
+    grpc:
+      url: inventory/lookup
+      status-code: 404
+`
+
+func TestSocket(t *testing.T) {
+	plan := load(t, socketPlan)
+	require.Equal(t, 3, len(plan.Execution))
+
+	tcp := plan.Execution[0].(*Call)
+	assert.Equal(t, CallKindTCP, tcp.Kind())
+	assert.Equal(t, "", tcp.TCP.Method)
+	assert.Equal(t, 201, tcp.TCP.StatusCode)
+	assert.Equal(t, 100, tcp.TCP.GenRequestBody)
+	assert.NotNil(t, tcp.TCP.GenResponseBodyDistribution)
+	assert.Equal(t, "orders:7000", tcp.Host())
+	assert.Equal(t, "orders:7000", tcp.Address())
+	assert.Equal(t, "tcp orders:7000/reserve 201", tcp.String())
+
+	udp := tcp.Execution[0].(*Call)
+	assert.Equal(t, CallKindUDP, udp.Kind())
+	assert.Equal(t, "stats:9000", udp.Address())
+	assert.Same(t, &udp.UDP.HTTP, udp.Message())
+
+	assert.Equal(t, CallKindHTTP, plan.Execution[1].(*Call).Kind())
+	grpc := plan.Execution[2].(*Call)
+	assert.Equal(t, CallKindGRPC, grpc.Kind())
+	assert.Equal(t, "inventory:9090", grpc.Address())
+	assert.Equal(t, "grpc inventory/lookup 404", grpc.String())
+
+	assert.Equal(t, []string{"orders:7000", "stats", "orders", "inventory"}, plan.Hosts())
+	assert.Equal(t, "0 call tcp orders:7000/reserve 201", plan.Estimate().CriticalPath[0])
+
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err := FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	encoded, err = plan.ToYAML()
+	require.NoError(t, err)
+	decoded, err = FromYAML(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	rewritten, err := plan.RewriteHosts(map[string]string{"stats": "127.0.0.1:9001"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"orders:7000", "127.0.0.1:9001", "orders", "inventory"}, rewritten.Hosts())
+
+	assert.Error(t, (&Socket{}).Parse("orders"))
+}
+
+func TestSocketRejectsHTTPSettings(t *testing.T) {
+	for _, kind := range []string{"tcp", "udp", "grpc"} {
+		for _, setting := range []string{"protocol: h2c", "tls: {skip-verify: true}"} {
+			yaml := "execution:\n- call:\n    " + kind + ":\n      url: orders/reserve\n      " + setting + "\n"
+			_, err := FromYAML([]byte(yaml))
+			assert.ErrorContains(t, err, "invalid plan: call "+kind+" orders/reserve 200: invalid socket", yaml)
+		}
+	}
+
+	plan := load(t, socketPlan)
+	plan.Execution[2].(*Call).GRPC.TLS = &TLS{SkipVerify: true}
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	_, err = FromJSON(encoded)
+	assert.ErrorContains(t, err, "invalid plan: call grpc inventory/lookup 404: invalid socket: tls is not supported")
+}
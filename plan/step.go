@@ -13,4 +13,6 @@ const (
 	StepTypeCompute  StepType = "compute"
 	StepTypeParallel StepType = "parallel"
 	StepTypeLoop     StepType = "loop"
+	StepTypeContend  StepType = "contend"
+	StepTypeIO       StepType = "io"
 )
@@ -0,0 +1,141 @@
+package plan
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Stream represents a long-lived WebSocket or Server-Sent Events (SSE) stream to another kaller,
+// carrying Messages messages (1 by default) with an Interval between them:
+//   - websocket: the caller sends each message, of GenRequestBody bytes, and waits for the reply
+//     message, of GenResponseBody bytes
+//   - sse: the called kaller sends each message as an event, of GenResponseBody bytes
+//
+// The called kaller runs the call Compute once, when the stream is opened, and the call
+// Execution once per message, before replying to or sending the message. PostExecution runs
+// once the stream is done. A status code other than 2xx makes the called kaller reject the
+// stream with that status code
+//
+// Streams are described like Socket calls, and can also be written in the same compact form,
+// followed by the amount of messages and the interval, eg:
+//
+//	websocket: chat/ws 200 100 1000 x10 every 50ms
+//
+// WebSocket streams are always opened over HTTP/1.1, while SSE streams honor Protocol. Stream
+// messages are not compressed. SSE events that are not valid UTF-8 text, like generated
+// binary bodies, are base64 encoded
+type Stream struct {
+	Socket
+	Messages int           `json:"messages,omitempty" yaml:"messages,omitempty"`
+	Interval time.Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+}
+
+// MessageCount returns how many messages the stream carries, 1 by default
+func (s *Stream) MessageCount() int {
+	if s.Messages <= 0 {
+		return 1
+	}
+	return s.Messages
+}
+
+func (s *Stream) String() string {
+	str := fmt.Sprintf("%s x%d", s.Socket.String(), s.MessageCount())
+	if s.Interval > 0 {
+		str += fmt.Sprintf(" every %v", s.Interval)
+	}
+	return str
+}
+
+// Parse parses a stream from a string. The format is the same as Socket.Parse, optionally
+// followed by the amount of messages and the interval, eg: chat/ws 200 100 1000 x10 every 50ms
+func (s *Stream) Parse(str string) error {
+	fields := strings.Fields(str)
+	parsed := Stream{}
+	for len(fields) > 0 {
+		last := fields[len(fields)-1]
+		if len(fields) > 1 && fields[len(fields)-2] == "every" {
+			interval, err := time.ParseDuration(last)
+			if err != nil {
+				return fmt.Errorf("cannot parse stream definition %q: bad interval: %w", str, err)
+			}
+			parsed.Interval = interval
+			fields = fields[:len(fields)-2]
+			continue
+		}
+		if messages, err := strconv.Atoi(strings.TrimPrefix(last, "x")); err == nil && strings.HasPrefix(last, "x") {
+			parsed.Messages = messages
+			fields = fields[:len(fields)-1]
+			continue
+		}
+		break
+	}
+	if err := parsed.Socket.Parse(strings.Join(fields, " ")); err != nil {
+		return fmt.Errorf("cannot parse stream definition %q", str)
+	}
+	*s = parsed
+	return nil
+}
+
+func (s Stream) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{}
+	if err := node.Encode(s.HTTP); err != nil {
+		return nil, err
+	}
+	add := func(key string, value any) error {
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(value); err != nil {
+			return err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, valueNode)
+		return nil
+	}
+	if s.Messages != 0 {
+		if err := add("messages", s.Messages); err != nil {
+			return nil, err
+		}
+	}
+	if s.Interval != 0 {
+		if err := add("interval", s.Interval.String()); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+func (s *Stream) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value != "" {
+		if err := s.Parse(node.Value); err != nil {
+			return fmt.Errorf("invalid stream definition at line %d: %w", node.Line, err)
+		}
+		return nil
+	}
+	// messages and interval are decoded here, while everything else describes the socket
+	parsed := Stream{}
+	content := []*yaml.Node{}
+	for idx := 0; idx+1 < len(node.Content); idx += 2 {
+		key, value := node.Content[idx], node.Content[idx+1]
+		var err error
+		switch key.Value {
+		case "messages":
+			err = value.Decode(&parsed.Messages)
+		case "interval":
+			err = value.Decode(&parsed.Interval)
+		default:
+			content = append(content, key, value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid stream %s at line %d: %w", key.Value, value.Line, err)
+		}
+	}
+	socketNode := *node
+	socketNode.Content = content
+	if err := parsed.Socket.UnmarshalYAML(&socketNode); err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
@@ -0,0 +1,68 @@
+package plan
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var streamPlan = `
+execution:
+- call:
+    websocket: chat/ws 200 100 1000 x10 every 50ms
+    execution:
+    - call:
+        sse:
+          url: feed:8080/events
+          gen-response-body: 500
+          messages: 3
+`
+
+func TestStream(t *testing.T) {
+	plan := load(t, streamPlan)
+	require.Equal(t, 1, len(plan.Execution))
+
+	ws := plan.Execution[0].(*Call)
+	assert.Equal(t, CallKindWebSocket, ws.Kind())
+	assert.Same(t, ws.WebSocket, ws.Stream())
+	assert.Nil(t, ws.Socket())
+	assert.Equal(t, 10, ws.WebSocket.Messages)
+	assert.Equal(t, 50*time.Millisecond, ws.WebSocket.Interval)
+	assert.Equal(t, 100, ws.WebSocket.GenRequestBody)
+	assert.Equal(t, 1000, ws.WebSocket.GenResponseBody)
+	assert.Equal(t, "chat:80", ws.Address())
+	secure := *ws.WebSocket
+	secure.URL = MustParseURL("wss://chat/room")
+	assert.Equal(t, "chat:443", (&Call{WebSocket: &secure}).Address())
+	assert.Equal(t, "secure:443", (&Call{HTTP: HTTP{URL: MustParseURL("https://secure/x")}}).Address())
+	assert.Equal(t, "websocket chat/ws 200 x10 every 50ms", ws.String())
+
+	sse := ws.Execution[0].(*Call)
+	assert.Equal(t, CallKindSSE, sse.Kind())
+	assert.Equal(t, 3, sse.SSE.MessageCount())
+	assert.Equal(t, time.Duration(0), sse.SSE.Interval)
+	assert.Equal(t, "feed:8080", sse.Host())
+	assert.Equal(t, "sse feed:8080/events 200 x3", sse.String())
+
+	estimate := plan.Estimate()
+	assert.Equal(t, 1, estimate.Requests["chat"])
+	assert.Equal(t, 10, estimate.Requests["feed:8080"])
+	assert.Equal(t, Range{Min: 450 * time.Millisecond, Max: 450 * time.Millisecond}, estimate.Latency)
+
+	encoded, err := plan.ToJSON()
+	require.NoError(t, err)
+	decoded, err := FromJSON(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	encoded, err = plan.ToYAML()
+	require.NoError(t, err)
+	decoded, err = FromYAML(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, plan, decoded)
+
+	assert.Equal(t, 1, (&Stream{}).MessageCount())
+	assert.Error(t, (&Stream{}).Parse("chat/ws 200 x2 every soon"))
+}
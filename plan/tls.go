@@ -0,0 +1,53 @@
+package plan
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TLS describes how calls to https urls set up TLS:
+//   - CA: PEM bundle file with the authorities trusted to verify the server certificate.
+//     Defaults to the system authorities
+//   - Cert and Key: PEM files with the client certificate and its key, presented to servers
+//     requiring client certificates (mutual TLS)
+//   - ServerName: overrides the server name sent in the handshake (SNI), which is also the name
+//     the server certificate is verified against
+//   - SkipVerify: skips verifying the server certificate altogether
+//
+// Files are read by the kaller making the call, so they must exist where it runs. Each distinct
+// TLS setting gets its own connections, which are reused across calls like any other
+//
+// Kaller servers running with self-signed certificates (see the certs package) keep the
+// authority and a client certificate in a directory, eg:
+//
+//	tls:
+//	  ca: /tmp/kaller-tls/ca.pem
+//	  cert: /tmp/kaller-tls/client.pem
+//	  key: /tmp/kaller-tls/client-key.pem
+type TLS struct {
+	CA         string `json:"ca,omitempty" yaml:"ca,omitempty"`
+	Cert       string `json:"cert,omitempty" yaml:"cert,omitempty"`
+	Key        string `json:"key,omitempty" yaml:"key,omitempty"`
+	ServerName string `json:"server-name,omitempty" yaml:"server-name,omitempty"`
+	SkipVerify bool   `json:"skip-verify,omitempty" yaml:"skip-verify,omitempty"`
+}
+
+func (t *TLS) Validate() error {
+	if (t.Cert == "") != (t.Key == "") {
+		return errors.New("invalid tls: cert and key must be set together")
+	}
+	return nil
+}
+
+func (t *TLS) UnmarshalYAML(node *yaml.Node) error {
+	type rawTLS TLS
+	if err := node.Decode((*rawTLS)(t)); err != nil {
+		return err
+	}
+	if err := t.Validate(); err != nil {
+		return fmt.Errorf("invalid tls at line %d: %w", node.Line, err)
+	}
+	return nil
+}
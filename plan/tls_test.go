@@ -0,0 +1,22 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestTLSParse(t *testing.T) {
+	var http HTTP
+	input := "{method: GET, url: https://a/b, tls: {ca: ca.pem, cert: c.pem, key: k.pem, server-name: a.local}}"
+	require.NoError(t, yaml.Unmarshal([]byte(input), &http))
+	assert.Equal(t, &TLS{CA: "ca.pem", Cert: "c.pem", Key: "k.pem", ServerName: "a.local"}, http.TLS)
+
+	var tls TLS
+	assert.Error(t, yaml.Unmarshal([]byte("{cert: c.pem}"), &tls))
+	var skip TLS
+	assert.NoError(t, yaml.Unmarshal([]byte("{skip-verify: true}"), &skip))
+	assert.True(t, skip.SkipVerify)
+}
@@ -8,16 +8,27 @@ import (
 )
 
 // The URL type is in essence the same as net/url.URL, but with some facilities for
-// cleaner json and yaml serialization/deserialization
+// cleaner json and yaml serialization/deserialization. Unset URLs are serialized as empty strings
 type URL struct {
 	*url.URL
 }
 
+func (u URL) String() string {
+	if u.URL == nil {
+		return ""
+	}
+	return u.URL.String()
+}
+
 func (u URL) MarshalYAML() (interface{}, error) {
-	return u.URL.String(), nil
+	return u.String(), nil
 }
 
 func (u *URL) UnmarshalYAML(node *yaml.Node) error {
+	if node.Value == "" {
+		u.URL = nil
+		return nil
+	}
 	urlStruct, err := url.Parse(node.Value)
 	if err != nil {
 		return err
@@ -27,7 +38,7 @@ func (u *URL) UnmarshalYAML(node *yaml.Node) error {
 }
 
 func (u URL) MarshalJSON() ([]byte, error) {
-	return json.Marshal(u.URL.String())
+	return json.Marshal(u.String())
 }
 
 func (u *URL) UnmarshalJSON(data []byte) error {
@@ -35,6 +46,10 @@ func (u *URL) UnmarshalJSON(data []byte) error {
 	if err := json.Unmarshal(data, &str); err != nil {
 		return err
 	}
+	if str == "" {
+		u.URL = nil
+		return nil
+	}
 	urlStruct, err := url.Parse(str)
 	if err != nil {
 		return err
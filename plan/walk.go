@@ -0,0 +1,76 @@
+package plan
+
+// Walk visits all steps in the execution depth-first, including the steps nested in
+// calls, parallel blocks, loops and contend steps. Calls have their Execution visited before their
+// PostExecution
+func Walk(execution Execution, fn func(Step)) {
+	for _, step := range execution {
+		fn(step)
+		switch v := step.(type) {
+		case *Call:
+			Walk(v.Execution, fn)
+			Walk(v.PostExecution, fn)
+		case *Parallel:
+			Walk(v.Execution, fn)
+		case *Loop:
+			Walk(v.Execution, fn)
+		case *Contend:
+			Walk(v.Execution, fn)
+		}
+	}
+}
+
+// TLSCall returns the first call of the plan that is made over TLS, or nil if there is none.
+// See Call.UsesTLS
+func (p Plan) TLSCall() *Call {
+	var found *Call
+	Walk(p.Execution, func(step Step) {
+		if call, ok := step.(*Call); ok && found == nil && call.UsesTLS() {
+			found = call
+		}
+	})
+	return found
+}
+
+// Hosts returns the distinct hosts called in the plan, in order of appearance
+func (p Plan) Hosts() []string {
+	hosts := []string{}
+	seen := map[string]bool{}
+	Walk(p.Execution, func(step Step) {
+		call, ok := step.(*Call)
+		if !ok || seen[call.Host()] {
+			return
+		}
+		seen[call.Host()] = true
+		hosts = append(hosts, call.Host())
+	})
+	return hosts
+}
+
+// RewriteHosts returns a copy of the plan where calls to the hosts present in the mapping
+// are redirected to the mapped host. Hosts not present in the mapping are kept as is
+func (p Plan) RewriteHosts(mapping map[string]string) (Plan, error) {
+	return p.RewriteCalls(func(call *Call) {
+		if host, ok := mapping[call.Host()]; ok {
+			call.Message().URL.Host = host
+		}
+	})
+}
+
+// RewriteCalls returns a copy of the plan where each call was changed by the given function
+func (p Plan) RewriteCalls(rewrite func(call *Call)) (Plan, error) {
+	encoded, err := p.ToJSON()
+	if err != nil {
+		return Plan{}, err
+	}
+	rewritten, err := FromJSON(encoded)
+	if err != nil {
+		return Plan{}, err
+	}
+	Walk(rewritten.Execution, func(step Step) {
+		if call, ok := step.(*Call); ok {
+			rewrite(call)
+		}
+	})
+	return rewritten, nil
+}
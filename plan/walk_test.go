@@ -0,0 +1,17 @@
+package plan
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostsAndRewriteHosts(t *testing.T) {
+	plan := load(t, estimatePlan)
+	assert.Equal(t, []string{"svc1", "svc2", "svc3", "svc4"}, plan.Hosts())
+
+	rewritten, err := plan.RewriteHosts(map[string]string{"svc2": "127.0.0.1:8002", "svc4": "127.0.0.1:8004"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"svc1", "127.0.0.1:8002", "svc3", "127.0.0.1:8004"}, rewritten.Hosts())
+	assert.Equal(t, []string{"svc1", "svc2", "svc3", "svc4"}, plan.Hosts())
+}
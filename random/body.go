@@ -0,0 +1,223 @@
+package random
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Words used when generating text. The vocabulary is kept small on purpose so generated
+// text compresses very well, similar to logs or html pages
+var Words = []string{
+	"the", "service", "request", "response", "kaller", "plan", "call", "compute",
+	"latency", "memory", "with", "from", "and", "status", "body", "mesh",
+}
+
+// Text generates size bytes of highly compressible text: words from the small Words
+// vocabulary separated by spaces and broken into lines
+func Text(src *rand.Rand, size int) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, size+16))
+	line := 0
+	for buf.Len() < size {
+		word := Words[src.Intn(len(Words))]
+		if line > 0 {
+			if line+len(word) >= 80 {
+				buf.WriteByte('\n')
+				line = 0
+			} else {
+				buf.WriteByte(' ')
+				line++
+			}
+		}
+		buf.WriteString(word)
+		line += len(word)
+	}
+	return buf.Bytes()[:size]
+}
+
+// Binary generates size random bytes, which are essentially incompressible
+func Binary(src *rand.Rand, size int) []byte {
+	buf := make([]byte, size)
+	for i := 0; i < size; i += 8 {
+		value := src.Uint64()
+		for j := i; j < i+8 && j < size; j++ {
+			buf[j] = byte(value)
+			value >>= 8
+		}
+	}
+	return buf
+}
+
+// JSON generates a random JSON document of exactly size bytes, made of objects nested up
+// to depth levels (a depth of 1 or less means a flat object). Leaves are strings, numbers
+// and booleans. Whitespace is used as padding to hit the exact size. Sizes smaller than
+// 2 still produce the smallest valid document: {}
+func JSON(src *rand.Rand, size int, depth int) []byte {
+	if depth < 1 {
+		depth = 1
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, size))
+	buf.WriteByte('{')
+	// each entry represents an open object, flagging whether it already has fields
+	open := []bool{false}
+
+	const keySize = 8
+	const fieldOverhead = keySize + 3 // "key":
+	for {
+		remaining := size - buf.Len() - len(open)
+		last := len(open) - 1
+		separator := 0
+		if open[last] {
+			separator = 1
+		}
+		available := remaining - separator - fieldOverhead
+		if available < 2 {
+			break
+		}
+
+		// maybe close the current nested object
+		if last > 0 && open[last] && src.Intn(5) == 0 {
+			buf.WriteByte('}')
+			open = open[:last]
+			continue
+		}
+
+		if separator > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteByte('"')
+		buf.WriteString(String(src, keySize))
+		buf.WriteString(`":`)
+		open[last] = true
+
+		// maybe open a new nested object
+		if len(open) < depth && src.Intn(3) == 0 {
+			buf.WriteByte('{')
+			open = append(open, false)
+			continue
+		}
+
+		switch src.Intn(4) {
+		case 0:
+			number := strconv.Itoa(src.Intn(1000000))
+			if len(number) <= available {
+				buf.WriteString(number)
+				continue
+			}
+		case 1:
+			boolean := strconv.FormatBool(src.Intn(2) == 0)
+			if len(boolean) <= available {
+				buf.WriteString(boolean)
+				continue
+			}
+		}
+		stringSize := 1 + src.Intn(32)
+		if stringSize > available-2 {
+			stringSize = available - 2
+		}
+		buf.WriteByte('"')
+		buf.WriteString(String(src, stringSize))
+		buf.WriteByte('"')
+	}
+
+	for padding := size - buf.Len() - len(open); padding > 0; padding-- {
+		buf.WriteByte(' ')
+	}
+	for range open {
+		buf.WriteByte('}')
+	}
+	return buf.Bytes()
+}
+
+// The regex pattern used to find fills in templates, eg: {{string 10}}
+const TemplateFillPattern = `\{\{\s*(\w+)(?:\s+(\d+))?\s*\}\}`
+
+var templateFillPattern = regexp.MustCompile(TemplateFillPattern)
+
+// Template is a fixed text with fills that are replaced by random values every time the
+// template is executed. Available fills:
+//   - {{string N}}: N random alphanumeric characters (see String)
+//   - {{int}} or {{int N}}: a random integer, lower than N when given
+//   - {{float}}: a random float between 0 and 1
+//   - {{bool}}: true or false
+//   - {{hex N}}: N random hexadecimal characters
+//   - {{uuid}}: a random uuid (version 4)
+type Template struct {
+	text  string
+	fills [][]int
+}
+
+// ParseTemplate parses the given text, validating its fills
+func ParseTemplate(text string) (*Template, error) {
+	fills := templateFillPattern.FindAllStringSubmatchIndex(text, -1)
+	for _, fill := range fills {
+		name := text[fill[2]:fill[3]]
+		hasArg := fill[4] >= 0
+		switch name {
+		case "string", "hex":
+			if !hasArg {
+				return nil, fmt.Errorf("invalid template fill %q: size is required", text[fill[0]:fill[1]])
+			}
+		case "int":
+		case "float", "bool", "uuid":
+			if hasArg {
+				return nil, fmt.Errorf("invalid template fill %q: no arguments expected", text[fill[0]:fill[1]])
+			}
+		default:
+			return nil, fmt.Errorf("invalid template fill %q: unknown fill %q", text[fill[0]:fill[1]], name)
+		}
+	}
+	return &Template{text: text, fills: fills}, nil
+}
+
+// Execute generates the template text with all its fills replaced by random values
+func (t *Template) Execute(src *rand.Rand) []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, len(t.text)))
+	last := 0
+	for _, fill := range t.fills {
+		buf.WriteString(t.text[last:fill[0]])
+		last = fill[1]
+		arg := 0
+		if fill[4] >= 0 {
+			arg, _ = strconv.Atoi(t.text[fill[4]:fill[5]])
+		}
+		switch t.text[fill[2]:fill[3]] {
+		case "string":
+			buf.WriteString(String(src, arg))
+		case "hex":
+			buf.WriteString(hex(src, arg))
+		case "int":
+			if arg > 0 {
+				buf.WriteString(strconv.Itoa(src.Intn(arg)))
+			} else {
+				buf.WriteString(strconv.FormatInt(src.Int63(), 10))
+			}
+		case "float":
+			buf.WriteString(strconv.FormatFloat(src.Float64(), 'f', -1, 64))
+		case "bool":
+			buf.WriteString(strconv.FormatBool(src.Intn(2) == 0))
+		case "uuid":
+			id := []byte(hex(src, 32))
+			id[12] = '4'
+			id[16] = "89ab"[src.Intn(4)]
+			buf.WriteString(strings.Join(
+				[]string{string(id[0:8]), string(id[8:12]), string(id[12:16]), string(id[16:20]), string(id[20:32])},
+				"-",
+			))
+		}
+	}
+	buf.WriteString(t.text[last:])
+	return buf.Bytes()
+}
+
+func hex(src *rand.Rand, size int) string {
+	const digits = "0123456789abcdef"
+	buf := make([]byte, size)
+	for i := range buf {
+		buf[i] = digits[src.Intn(len(digits))]
+	}
+	return string(buf)
+}
@@ -0,0 +1,84 @@
+package random
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	src := New(1)
+	for _, depth := range []int{0, 1, 3, 10} {
+		for size := 2; size < 2000; size += 7 {
+			doc := JSON(src, size, depth)
+			assert.Equal(t, size, len(doc))
+			var decoded map[string]any
+			require.NoError(t, json.Unmarshal(doc, &decoded), string(doc))
+		}
+	}
+	assert.Equal(t, "{}", string(JSON(src, 0, 1)))
+
+	nesting := func(value any) int {
+		var depth func(any) int
+		depth = func(value any) int {
+			object, ok := value.(map[string]any)
+			if !ok {
+				return 0
+			}
+			max := 0
+			for _, child := range object {
+				if d := depth(child); d > max {
+					max = d
+				}
+			}
+			return max + 1
+		}
+		return depth(value)
+	}
+	var flat, nested map[string]any
+	require.NoError(t, json.Unmarshal(JSON(src, 10000, 1), &flat))
+	require.NoError(t, json.Unmarshal(JSON(src, 10000, 4), &nested))
+	assert.Equal(t, 1, nesting(flat))
+	assert.Equal(t, 4, nesting(nested))
+}
+
+func TestTextAndBinaryCompression(t *testing.T) {
+	compressed := func(data []byte) int {
+		buf := bytes.Buffer{}
+		writer := gzip.NewWriter(&buf)
+		writer.Write(data)
+		writer.Close()
+		return buf.Len()
+	}
+	src := New(1)
+	text := Text(src, 100000)
+	binary := Binary(src, 100000)
+	assert.Equal(t, 100000, len(text))
+	assert.Equal(t, 100000, len(binary))
+	assert.Less(t, compressed(text), 100000/3)
+	assert.Greater(t, compressed(binary), 100000)
+	assert.Equal(t, 5, len(Binary(src, 5)))
+}
+
+func TestTemplate(t *testing.T) {
+	template, err := ParseTemplate(`{"id": "{{uuid}}", "name": "{{string 8}}", "age": {{int 100}}, "score": {{float}}, "ok": {{bool}}, "hash": "{{ hex 6 }}"}`)
+	require.NoError(t, err)
+	body := template.Execute(New(1))
+	pattern := regexp.MustCompile(
+		`^{"id": "[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}", "name": "[a-zA-Z0-9]{8}", ` +
+			`"age": \d{1,2}, "score": 0(\.\d+)?, "ok": (true|false), "hash": "[0-9a-f]{6}"}$`,
+	)
+	assert.Regexp(t, pattern, string(body))
+	assert.Equal(t, body, template.Execute(New(1)))
+	assert.NotEqual(t, body, template.Execute(New(2)))
+
+	for _, text := range []string{"{{string}}", "{{bool 1}}", "{{unknown}}"} {
+		_, err := ParseTemplate(text)
+		assert.Error(t, err, text)
+	}
+}
@@ -0,0 +1,80 @@
+package random
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Default is a concurrency safe generator seeded from the wall clock, for when
+// reproducibility is not needed
+var Default = New(time.Now().UnixNano())
+
+// New creates a random number generator with the given seed that is safe to be used
+// concurrently. Note that rand.Rand.Read is still not safe for concurrent use
+func New(seed int64) *rand.Rand {
+	return rand.New(&lockedSource{src: rand.NewSource(seed).(rand.Source64)})
+}
+
+// Purposes of derived seeds. Each kind of random decision taken for the same step uses its own
+// purpose, so the decisions are independent from each other. Otherwise a call picking a long
+// compute would also always pick, say, the same status code and body
+const (
+	PurposeCompute      = "compute"
+	PurposeIO           = "io"
+	PurposeError        = "error"
+	PurposeStatus       = "status"
+	PurposeRequestBody  = "request-body"
+	PurposeResponseBody = "response-body"
+)
+
+// Derive deterministically derives a new seed from a parent seed, the purpose of the derived
+// seed and a list of keys. The same seed, purpose and keys always produce the same derived seed
+func Derive(seed int64, purpose string, keys ...string) int64 {
+	hash := fnv.New64a()
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(seed))
+	hash.Write(buf)
+	for _, key := range append([]string{purpose}, keys...) {
+		// the separator avoids collisions such as ("ab", "c") and ("a", "bc")
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+	}
+	return int64(mix(hash.Sum64()))
+}
+
+// mix is the splitmix64 finalizer. Hashes of keys that differ only slightly are related to
+// each other, which would make the generators seeded with them draw correlated values
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+type lockedSource struct {
+	mutex sync.Mutex
+	src   rand.Source64
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Uint64() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.src.Uint64()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.src.Seed(seed)
+}
@@ -0,0 +1,78 @@
+package random
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDerive(t *testing.T) {
+	assert.Equal(t, Derive(1, PurposeCompute, "0.1", "2"), Derive(1, PurposeCompute, "0.1", "2"))
+	assert.NotEqual(t, Derive(1, PurposeCompute, "0.1", "2"), Derive(2, PurposeCompute, "0.1", "2"))
+	assert.NotEqual(t, Derive(1, PurposeCompute, "0.1", "2"), Derive(1, PurposeCompute, "0.1", "3"))
+	assert.NotEqual(t, Derive(1, PurposeCompute, "0.1", "2"), Derive(1, PurposeCompute, "0.12", ""))
+	assert.NotEqual(t, Derive(1, PurposeCompute, "0.1", "2"), Derive(1, PurposeStatus, "0.1", "2"))
+}
+
+func TestDerivePurposesAreIndependent(t *testing.T) {
+	purposes := []string{
+		PurposeCompute, PurposeIO, PurposeError, PurposeStatus, PurposeRequestBody, PurposeResponseBody,
+	}
+	// draw once per purpose for many steps, as the handler does, and check that the draws of
+	// different purposes for the same step are not correlated
+	const steps = 2000
+	draws := map[string][]float64{}
+	for _, purpose := range purposes {
+		for idx := 0; idx < steps; idx++ {
+			draws[purpose] = append(draws[purpose], New(Derive(42, purpose, strconv.Itoa(idx), "")).Float64())
+		}
+	}
+	for i, a := range purposes {
+		for _, b := range purposes[i+1:] {
+			assert.NotEqual(t, draws[a], draws[b], "%s and %s", a, b)
+			assert.InDelta(t, 0, correlation(draws[a], draws[b]), 0.1, "%s and %s", a, b)
+		}
+	}
+}
+
+func correlation(a []float64, b []float64) float64 {
+	mean := func(v []float64) float64 {
+		sum := 0.0
+		for _, x := range v {
+			sum += x
+		}
+		return sum / float64(len(v))
+	}
+	meanA, meanB := mean(a), mean(b)
+	var cov, varA, varB float64
+	for idx := range a {
+		cov += (a[idx] - meanA) * (b[idx] - meanB)
+		varA += (a[idx] - meanA) * (a[idx] - meanA)
+		varB += (b[idx] - meanB) * (b[idx] - meanB)
+	}
+	return cov / math.Sqrt(varA*varB)
+}
+
+func TestNewIsReproducible(t *testing.T) {
+	assert.Equal(t, String(New(42), 100), String(New(42), 100))
+	assert.NotEqual(t, String(New(42), 100), String(New(43), 100))
+}
+
+func TestNewIsConcurrencySafe(t *testing.T) {
+	r := New(42)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				r.Int63()
+				String(r, 10)
+			}
+		}()
+	}
+	wg.Wait()
+}
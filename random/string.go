@@ -2,7 +2,6 @@ package random
 
 import (
 	"math/rand"
-	"time"
 	"unsafe"
 )
 
@@ -13,13 +12,11 @@ const (
 	letterIdxMax  = 63 / letterIdxBits   // # of letter indices fitting in 63 bits
 )
 
-var src = rand.NewSource(time.Now().UnixNano())
-
-// Generates a random string. Characters used are regular ASCII 1 byte runes, defined
-// in the Letters constant
+// Generates a random string using the given generator. Characters used are regular ASCII
+// 1 byte runes, defined in the Letters constant
 //
 // Algorithm comes from https://stackoverflow.com/a/31832326/351295
-func String(size int) string {
+func String(src *rand.Rand, size int) string {
 	buf := make([]byte, size)
 	// A src.Int63() generates 63 random bits, enough for letterIdxMax characters!
 	for i, cache, remain := size-1, src.Int63(), letterIdxMax; i >= 0; {
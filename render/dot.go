@@ -0,0 +1,42 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// DOT writes the plan as a Graphviz digraph. Async calls are drawn with dashed edges and
+// calls done in the post execution phase are drawn in gray
+func DOT(w io.Writer, plan ptype.Plan) error {
+	graph := Build(plan)
+	lines := []string{
+		"digraph plan {",
+		"  rankdir=LR;",
+		"  node [shape=box];",
+	}
+	for _, node := range graph.Nodes {
+		lines = append(lines, fmt.Sprintf("  %s;", dotQuote(node)))
+	}
+	for _, edge := range graph.Edges {
+		attrs := []string{"label=" + dotQuote(edge.Label())}
+		if edge.Async {
+			attrs = append(attrs, "style=dashed")
+		}
+		if edge.Post {
+			attrs = append(attrs, "color=gray", "fontcolor=gray")
+		}
+		lines = append(lines, fmt.Sprintf(
+			"  %s -> %s [%s];", dotQuote(edge.From), dotQuote(edge.To), strings.Join(attrs, ", "),
+		))
+	}
+	lines = append(lines, "}")
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
@@ -0,0 +1,62 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// Mermaid writes the plan as a Mermaid flowchart. Async calls are drawn with dotted
+// edges and calls done in the post execution phase are drawn in gray, so async calls
+// done in the post execution phase get both
+func Mermaid(w io.Writer, plan ptype.Plan) error {
+	graph := Build(plan)
+	ids := mermaidIDs(graph.Nodes)
+	lines := []string{"flowchart LR"}
+	for _, node := range graph.Nodes {
+		lines = append(lines, fmt.Sprintf("  %s[%s]", ids[node], mermaidQuote(node)))
+	}
+	var styles []string
+	for idx, edge := range graph.Edges {
+		arrow := "-->"
+		if edge.Async {
+			arrow = "-.->"
+		}
+		if edge.Post {
+			styles = append(styles, fmt.Sprintf("  linkStyle %d stroke:gray,color:gray", idx))
+		}
+		lines = append(lines, fmt.Sprintf(
+			"  %s %s|%s| %s",
+			ids[edge.From], arrow, mermaidQuote(edge.Label()), ids[edge.To],
+		))
+	}
+	lines = append(lines, styles...)
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+var mermaidUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// mermaidIDs assigns a Mermaid id to each node. Ids are the node names with unsafe characters
+// replaced, so distinct names that end up with the same id get a counter suffix
+func mermaidIDs(nodes []string) map[string]string {
+	ids := make(map[string]string, len(nodes))
+	taken := map[string]bool{}
+	for _, node := range nodes {
+		base := "n_" + mermaidUnsafe.ReplaceAllString(node, "_")
+		id := base
+		for n := 2; taken[id]; n++ {
+			id = fmt.Sprintf("%s_%d", base, n)
+		}
+		taken[id] = true
+		ids[node] = id
+	}
+	return ids
+}
+
+func mermaidQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
@@ -0,0 +1,137 @@
+package render
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// ClientNode is the node name used for the origin of the plan, which is the kaller client
+const ClientNode = "client"
+
+// Graph is a flattened view of a plan, where services are nodes and calls are edges
+type Graph struct {
+	Nodes []string
+	Edges []Edge
+}
+
+// Edge represents a single call in the plan, going from the calling service to the called one
+type Edge struct {
+	From     string
+	To       string
+	Location string
+	Call     *ptype.Call
+
+	// Async is set for calls that are not waited for by the caller
+	Async bool
+	// Post is set for calls made in the post execution phase, after the caller already responded
+	Post bool
+	// Parallel is set for calls that are part of a parallel block
+	Parallel bool
+	// Times is how many times this call is made per execution of its caller, as a result
+	// of being nested in loops
+	Times int
+}
+
+// Label describes the edge in a compact form, eg: "1.2 GET /product 200 50ms to 200ms x5"
+func (e Edge) Label() string {
+	parts := []string{e.Location}
+	message := e.Call.Message()
+	if kind := e.Call.Kind(); kind != ptype.CallKindHTTP {
+		parts = append(parts, string(kind))
+	}
+	if message.Method != "" {
+		parts = append(parts, message.Method)
+	}
+	if message.URL.URL != nil {
+		path := message.URL.RequestURI()
+		parts = append(parts, path)
+	}
+	parts = append(parts, message.StatusCodeString())
+	if !e.Call.Compute.IsZero() {
+		parts = append(parts, e.Call.Compute.String())
+	}
+	if e.Times > 1 {
+		parts = append(parts, fmt.Sprintf("x%d", e.Times))
+	}
+	if e.Parallel {
+		parts = append(parts, "parallel")
+	}
+	if e.Async {
+		parts = append(parts, "async")
+	}
+	if e.Post {
+		parts = append(parts, "post")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Build flattens the plan into a Graph. Locations assigned to edges follow the same
+// scheme used by the handler package when routing calls
+func Build(plan ptype.Plan) Graph {
+	b := builder{seen: map[string]bool{}}
+	b.node(ClientNode)
+	b.execution(ClientNode, plan.Execution, 0, "", walkState{times: 1})
+	return b.graph
+}
+
+type walkState struct {
+	times    int
+	post     bool
+	parallel bool
+}
+
+type builder struct {
+	graph Graph
+	seen  map[string]bool
+}
+
+func (b *builder) node(name string) {
+	if b.seen[name] {
+		return
+	}
+	b.seen[name] = true
+	b.graph.Nodes = append(b.graph.Nodes, name)
+}
+
+func (b *builder) execution(from string, execution ptype.Execution, offset int, location string, state walkState) {
+	for idx, step := range execution {
+		stepLocation := strconv.Itoa(offset + idx)
+		if location != "" {
+			stepLocation = location + "." + stepLocation
+		}
+		b.step(from, step, stepLocation, state)
+	}
+}
+
+func (b *builder) step(from string, step ptype.Step, location string, state walkState) {
+	switch v := step.(type) {
+	case *ptype.Call:
+		to := v.Host()
+		b.node(to)
+		b.graph.Edges = append(b.graph.Edges, Edge{
+			From:     from,
+			To:       to,
+			Location: location,
+			Call:     v,
+			Async:    v.Async,
+			Post:     state.post,
+			Parallel: state.parallel,
+			Times:    state.times,
+		})
+		inner := walkState{times: 1}
+		b.execution(to, v.Execution, 0, location, inner)
+		inner.post = true
+		b.execution(to, v.PostExecution, len(v.Execution), location, inner)
+	case *ptype.Parallel:
+		state.parallel = true
+		b.execution(from, v.Execution, 0, location, state)
+	case *ptype.Loop:
+		state.times *= v.Times
+		b.execution(from, v.Execution, 0, location, state)
+	case *ptype.Contend:
+		b.execution(from, v.Execution, 0, location, state)
+	}
+}
@@ -0,0 +1,175 @@
+package render
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+var plan1 = `
+execution:
+- call:
+  http: GET svc1/listing 200
+  compute: 10ms to 20ms
+  execution:
+  - parallel:
+    concurrency: 2
+    execution:
+    - call:
+      http: GET svc2/product 200
+    - loop:
+      times: 3
+      execution:
+      - call:
+        async: true
+        http: GET svc3/profile 404
+  post-execution:
+  - call:
+    http: POST svc4/metrics 201
+`
+
+func TestBuild(t *testing.T) {
+	graph := Build(load(t, plan1))
+	assert.Equal(t, []string{"client", "svc1", "svc2", "svc3", "svc4"}, graph.Nodes)
+	require.Equal(t, 4, len(graph.Edges))
+
+	labels := []string{}
+	for _, edge := range graph.Edges {
+		labels = append(labels, edge.From+" -> "+edge.To+": "+edge.Label())
+	}
+	assert.Equal(t, []string{
+		"client -> svc1: 0 GET /listing 200 10ms to 20ms",
+		"svc1 -> svc2: 0.0.0 GET /product 200 parallel",
+		"svc1 -> svc3: 0.0.1.0 GET /profile 404 x3 parallel async",
+		"svc1 -> svc4: 0.1 POST /metrics 201 post",
+	}, labels)
+}
+
+func TestDOT(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, DOT(&buf, load(t, plan1)))
+	out := buf.String()
+	assert.Contains(t, out, `"client" -> "svc1" [label="0 GET /listing 200 10ms to 20ms"];`)
+	assert.Contains(t, out, `"svc1" -> "svc3" [label="0.0.1.0 GET /profile 404 x3 parallel async", style=dashed];`)
+	assert.Contains(t, out, `"svc1" -> "svc4" [label="0.1 POST /metrics 201 post", color=gray, fontcolor=gray];`)
+}
+
+func TestMermaid(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, Mermaid(&buf, load(t, plan1)))
+	out := buf.String()
+	assert.Contains(t, out, `n_client -->|"0 GET /listing 200 10ms to 20ms"| n_svc1`)
+	assert.Contains(t, out, `n_svc1 -.->|"0.0.1.0 GET /profile 404 x3 parallel async"| n_svc3`)
+	assert.Contains(t, out, `n_svc1 -->|"0.1 POST /metrics 201 post"| n_svc4`)
+	assert.Contains(t, out, "linkStyle 3 stroke:gray,color:gray")
+	assert.NotContains(t, out, "linkStyle 2 ")
+
+	// async calls done in the post execution phase are both dotted and gray
+	buf.Reset()
+	require.NoError(t, Mermaid(&buf, load(t, asyncPost)))
+	out = buf.String()
+	assert.Contains(t, out, `n_svc1 -.->|"0.0 GET /audit 200 async post"| n_svc2`)
+	assert.Contains(t, out, "linkStyle 1 stroke:gray,color:gray")
+}
+
+var asyncPost = `
+execution:
+- call:
+  http: GET svc1/a 200
+  post-execution:
+  - call:
+    async: true
+    http: GET svc2/audit 200
+`
+
+func TestMermaidSequence(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, MermaidSequence(&buf, load(t, plan1)))
+	assert.Equal(t, strings.Join([]string{
+		"sequenceDiagram",
+		"  participant n_client as client",
+		"  participant n_svc1 as svc1",
+		"  participant n_svc2 as svc2",
+		"  participant n_svc3 as svc3",
+		"  participant n_svc4 as svc4",
+		"  n_client->>n_svc1: 0 GET /listing 200 10ms to 20ms",
+		"  par concurrency 2",
+		"    n_svc1->>n_svc2: 0.0.0 GET /product 200",
+		"    n_svc2-->>n_svc1: 200",
+		"  and",
+		"    loop x3",
+		"      n_svc1-)n_svc3: 0.0.1.0 GET /profile 404",
+		"    end",
+		"  end",
+		"  n_svc1-->>n_client: 200",
+		"  rect rgb(235, 235, 235)",
+		"    Note over n_svc1: post execution",
+		"    n_svc1->>n_svc4: 0.1 POST /metrics 201",
+		"    n_svc4-->>n_svc1: 201",
+		"  end",
+	}, "\n")+"\n", buf.String())
+}
+
+func TestMermaidSequenceContend(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, MermaidSequence(&buf, load(t, `
+execution:
+- contend:
+    resource: db
+    kind: pool
+    permits: 10
+    execution:
+    - call:
+      http: GET db/query 200
+`)))
+	assert.Contains(t, buf.String(), strings.Join([]string{
+		"  critical pool db (10)",
+		"    n_client->>n_db: 0.0 GET /query 200",
+		"    n_db-->>n_client: 200",
+		"  end",
+	}, "\n"))
+}
+
+func TestMermaidSequenceSocket(t *testing.T) {
+	buf := bytes.Buffer{}
+	require.NoError(t, MermaidSequence(&buf, load(t, `
+execution:
+- call:
+  tcp: cache/get 404
+`)))
+	assert.Contains(t, buf.String(), "  n_client->>n_cache: 0 tcp /get 404\n  n_cache-->>n_client: 404\n")
+}
+
+func TestMermaidIDs(t *testing.T) {
+	plan := load(t, `
+execution:
+- call:
+  http: GET svc-1/a 200
+- call:
+  http: GET svc_1/b 200
+`)
+	buf := bytes.Buffer{}
+	require.NoError(t, Mermaid(&buf, plan))
+	out := buf.String()
+	assert.Contains(t, out, `n_svc_1["svc-1"]`)
+	assert.Contains(t, out, `n_svc_1_2["svc_1"]`)
+	assert.Contains(t, out, `n_client -->|"1 GET /b 200"| n_svc_1_2`)
+
+	buf.Reset()
+	require.NoError(t, MermaidSequence(&buf, plan))
+	out = buf.String()
+	assert.Contains(t, out, "participant n_svc_1 as svc-1")
+	assert.Contains(t, out, "participant n_svc_1_2 as svc_1")
+	assert.Contains(t, out, "n_client->>n_svc_1_2: 1 GET /b 200")
+}
+
+func load(t *testing.T, yaml string) ptype.Plan {
+	plan, err := ptype.FromYAML([]byte(strings.TrimSpace(yaml)))
+	require.NoError(t, err)
+	return plan
+}
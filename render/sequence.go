@@ -0,0 +1,122 @@
+package render
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// MermaidSequence writes the plan as a Mermaid sequence diagram, with calls and their
+// responses in the order they happen. Loops and parallel blocks are drawn as loop and par
+// blocks and contended resources as critical blocks. Async calls are drawn with open arrows
+// and without a response, as the caller does not wait for them. The post execution phase of
+// a service is drawn in a shaded block after its response
+func MermaidSequence(w io.Writer, plan ptype.Plan) error {
+	graph := Build(plan)
+	seq := sequence{ids: mermaidIDs(graph.Nodes)}
+	lines := []string{"sequenceDiagram"}
+	for _, node := range graph.Nodes {
+		lines = append(lines, fmt.Sprintf("  participant %s as %s", seq.ids[node], mermaidText(node)))
+	}
+	lines = append(lines, seq.execution(ClientNode, plan.Execution, 0, "", "  ")...)
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+type sequence struct {
+	ids map[string]string
+}
+
+// execution returns the diagram lines of an execution. Locations follow the same scheme used
+// by Build
+func (s sequence) execution(from string, execution ptype.Execution, offset int, location string, indent string) []string {
+	var lines []string
+	for idx, step := range execution {
+		stepLocation := strconv.Itoa(offset + idx)
+		if location != "" {
+			stepLocation = location + "." + stepLocation
+		}
+		lines = append(lines, s.step(from, step, stepLocation, indent)...)
+	}
+	return lines
+}
+
+func (s sequence) step(from string, step ptype.Step, location string, indent string) []string {
+	switch v := step.(type) {
+	case *ptype.Call:
+		to := v.Host()
+		request := "->>"
+		if v.Async {
+			request = "-)"
+		}
+		label := Edge{Location: location, Call: v}.Label()
+		lines := []string{fmt.Sprintf("%s%s%s%s: %s", indent, s.ids[from], request, s.ids[to], mermaidText(label))}
+		lines = append(lines, s.execution(to, v.Execution, 0, location, indent)...)
+		// the caller does not wait for async calls, so their responses are not drawn
+		if !v.Async {
+			lines = append(lines, fmt.Sprintf("%s%s-->>%s: %s", indent, s.ids[to], s.ids[from], responseLabel(v)))
+		}
+		post := s.execution(to, v.PostExecution, len(v.Execution), location, indent+"  ")
+		if len(post) > 0 {
+			lines = append(lines, indent+"rect rgb(235, 235, 235)")
+			lines = append(lines, fmt.Sprintf("%s  Note over %s: post execution", indent, s.ids[to]))
+			lines = append(lines, post...)
+			lines = append(lines, indent+"end")
+		}
+		return lines
+	case *ptype.Parallel:
+		var lines []string
+		for idx, step := range v.Execution {
+			branch := s.step(from, step, location+"."+strconv.Itoa(idx), indent+"  ")
+			if len(branch) == 0 {
+				continue
+			}
+			if lines == nil {
+				header := "par"
+				if v.Concurrency > 0 {
+					header += fmt.Sprintf(" concurrency %d", v.Concurrency)
+				}
+				lines = append(lines, indent+header)
+			} else {
+				lines = append(lines, indent+"and")
+			}
+			lines = append(lines, branch...)
+		}
+		if lines != nil {
+			lines = append(lines, indent+"end")
+		}
+		return lines
+	case *ptype.Loop:
+		body := s.execution(from, v.Execution, 0, location, indent+"  ")
+		if len(body) == 0 {
+			return nil
+		}
+		lines := []string{fmt.Sprintf("%sloop x%d", indent, v.Times)}
+		lines = append(lines, body...)
+		return append(lines, indent+"end")
+	case *ptype.Contend:
+		body := s.execution(from, v.Execution, 0, location, indent+"  ")
+		if len(body) == 0 {
+			return nil
+		}
+		lines := []string{fmt.Sprintf("%scritical %s", indent, mermaidText(v.String()))}
+		lines = append(lines, body...)
+		return append(lines, indent+"end")
+	}
+	return nil
+}
+
+// responseLabel describes the response of the call, which is its status code or status code
+// distribution, whatever the call kind is
+func responseLabel(call *ptype.Call) string {
+	return call.Message().StatusCodeString()
+}
+
+// mermaidText escapes characters that have a special meaning in sequence diagram texts
+func mermaidText(s string) string {
+	s = strings.ReplaceAll(s, "#", "#35;")
+	return strings.ReplaceAll(s, ";", "#59;")
+}
@@ -0,0 +1,94 @@
+// Package rpc defines the generic gRPC service kaller servers expose, so kaller calls can be made
+// over gRPC. The service has a single unary method taking and returning raw bytes:
+//
+//	service Kaller {
+//	  rpc Call(google.protobuf.BytesValue) returns (google.protobuf.BytesValue);
+//	}
+//
+// The request body and response body are carried as the message values. The encoded plan, the
+// location and all other kaller headers are carried as request metadata, with the call path in
+// the PathMetadata key. Response headers are sent back as response header metadata
+//
+// Kaller status codes are mapped to gRPC codes with Code. As many status codes map to the same
+// gRPC code, the actual status code is also sent back in the StatusMetadata response header
+package rpc
+
+import (
+	"context"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+const ServiceName = "kaller.Kaller"
+const MethodName = "Call"
+const FullMethod = "/" + ServiceName + "/" + MethodName
+
+// PathMetadata is the metadata key carrying the path of the call
+const PathMetadata = "x-kaller-path"
+
+// StatusMetadata is the response header metadata key carrying the kaller status code
+const StatusMetadata = "x-kaller-status"
+
+// Handler handles calls to the Kaller service
+type Handler func(ctx context.Context, request *wrapperspb.BytesValue) (*wrapperspb.BytesValue, error)
+
+// ServiceDesc returns the Kaller service description, with calls handled by the given handler.
+// Register it with grpc.Server.RegisterService, passing a nil implementation
+func ServiceDesc(handler Handler) *grpc.ServiceDesc {
+	return &grpc.ServiceDesc{
+		ServiceName: ServiceName,
+		HandlerType: (*any)(nil),
+		Methods: []grpc.MethodDesc{
+			{
+				MethodName: MethodName,
+				Handler: func(_ any, ctx context.Context, decode func(any) error, interceptor grpc.UnaryServerInterceptor) (any, error) {
+					request := &wrapperspb.BytesValue{}
+					if err := decode(request); err != nil {
+						return nil, err
+					}
+					if interceptor == nil {
+						return handler(ctx, request)
+					}
+					info := &grpc.UnaryServerInfo{FullMethod: FullMethod}
+					return interceptor(ctx, request, info, func(ctx context.Context, request any) (any, error) {
+						return handler(ctx, request.(*wrapperspb.BytesValue))
+					})
+				},
+			},
+		},
+		Metadata: "kaller.proto",
+	}
+}
+
+// statusToCode maps kaller status codes to gRPC codes, following the mapping gRPC gateways use
+var statusToCode = map[int]codes.Code{
+	http.StatusOK:                           codes.OK,
+	http.StatusBadRequest:                   codes.InvalidArgument,
+	http.StatusUnauthorized:                 codes.Unauthenticated,
+	http.StatusForbidden:                    codes.PermissionDenied,
+	http.StatusNotFound:                     codes.NotFound,
+	http.StatusConflict:                     codes.Aborted,
+	http.StatusPreconditionFailed:           codes.FailedPrecondition,
+	http.StatusRequestedRangeNotSatisfiable: codes.OutOfRange,
+	http.StatusTooManyRequests:              codes.ResourceExhausted,
+	499:                                     codes.Canceled,
+	http.StatusInternalServerError:          codes.Internal,
+	http.StatusNotImplemented:               codes.Unimplemented,
+	http.StatusServiceUnavailable:           codes.Unavailable,
+	http.StatusGatewayTimeout:               codes.DeadlineExceeded,
+}
+
+// Code returns the gRPC code for the kaller status code. Successful status codes (2xx) map to
+// OK, while status codes with no related gRPC code map to Unknown
+func Code(statusCode int) codes.Code {
+	if code, ok := statusToCode[statusCode]; ok {
+		return code
+	}
+	if statusCode >= 200 && statusCode < 300 {
+		return codes.OK
+	}
+	return codes.Unknown
+}
@@ -0,0 +1,18 @@
+package rpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCode(t *testing.T) {
+	assert.Equal(t, codes.OK, Code(200))
+	assert.Equal(t, codes.OK, Code(204))
+	assert.Equal(t, codes.NotFound, Code(404))
+	assert.Equal(t, codes.ResourceExhausted, Code(429))
+	assert.Equal(t, codes.Unavailable, Code(503))
+	assert.Equal(t, codes.Unknown, Code(302))
+	assert.Equal(t, codes.Unknown, Code(418))
+}
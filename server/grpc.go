@@ -0,0 +1,139 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	"github.com/bcap/kaller/frame"
+	"github.com/bcap/kaller/rpc"
+)
+
+// grpcServer serves the Kaller gRPC service, adapting its calls to an http.Handler. See the rpc
+// package for the service definition
+type grpcServer struct {
+	listener *net.TCPListener
+	server   *grpc.Server
+	inflight sync.WaitGroup
+}
+
+// ListenGRPC starts listening for kaller calls over gRPC. Calls are only served once ServeGRPC
+// is called
+func (s *Server) ListenGRPC(ctx context.Context, listenAddress string) (*net.TCPAddr, error) {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+	s.setContext(ctx)
+	s.grpc.listener = listener.(*net.TCPListener)
+	s.grpc.server = grpc.NewServer()
+	addr := *s.grpc.listener.Addr().(*net.TCPAddr)
+	return &addr, nil
+}
+
+// ServeGRPC serves kaller calls over gRPC with the given handler
+func (s *Server) ServeGRPC(handler http.Handler) error {
+	if s.grpc.listener == nil {
+		return errors.New("server must be listening on grpc first")
+	}
+	s.grpc.server.RegisterService(rpc.ServiceDesc(s.grpcHandler(handler)), nil)
+	err := s.grpc.server.Serve(s.grpc.listener)
+	if errors.Is(err, grpc.ErrServerStopped) {
+		return http.ErrServerClosed
+	}
+	return err
+}
+
+// grpcHandler handles gRPC calls with the given handler. The gRPC call is responded as soon as
+// the handler writes its response, while the handler keeps running any post execution steps
+func (s *Server) grpcHandler(handler http.Handler) rpc.Handler {
+	return func(ctx context.Context, request *wrapperspb.BytesValue) (*wrapperspb.BytesValue, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		path := "/"
+		if values := md.Get(rpc.PathMetadata); len(values) > 0 {
+			path = values[0]
+		}
+		req, err := http.NewRequestWithContext(s.context, "GRPC", path, bytes.NewReader(request.Value))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "bad path %q: %v", path, err)
+		}
+		for key, values := range md {
+			for _, value := range values {
+				req.Header.Add(key, value)
+			}
+		}
+		req.RequestURI = path
+		if p, ok := peer.FromContext(ctx); ok {
+			req.RemoteAddr = p.Addr.String()
+		}
+
+		responses := make(chan frame.Message, 1)
+		writer := &frameResponseWriter{
+			header: http.Header{},
+			send: func(msg frame.Message) error {
+				responses <- msg
+				return nil
+			},
+		}
+		s.grpc.inflight.Add(1)
+		go func() {
+			defer s.grpc.inflight.Done()
+			handler.ServeHTTP(writer, req)
+			writer.finish()
+		}()
+
+		var response frame.Message
+		select {
+		case response = <-responses:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		statusCode, _ := strconv.Atoi(response.Start)
+		header := metadata.Pairs(rpc.StatusMetadata, response.Start)
+		for key, values := range response.Header {
+			header.Append(strings.ToLower(key), values...)
+		}
+		if err := grpc.SetHeader(ctx, header); err != nil {
+			return nil, err
+		}
+		if code := rpc.Code(statusCode); code != codes.OK {
+			return nil, status.Error(code, fmt.Sprintf("kaller status %d", statusCode))
+		}
+		return &wrapperspb.BytesValue{Value: response.Payload}, nil
+	}
+}
+
+// shutdown stops accepting calls and waits for the calls being handled to finish or for the
+// context to be done, whatever happens first
+func (s *grpcServer) shutdown(ctx context.Context) error {
+	if s.server == nil {
+		return nil
+	}
+	done := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -2,16 +2,36 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"net"
 	"net/http"
+	"sync"
 	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
+// Server serves kaller calls over HTTP/1.1 and HTTP/2 over cleartext (h2c), and optionally
+// over raw TCP connections, UDP datagrams and gRPC (see ListenTCP, ListenUDP and ListenGRPC)
 type Server struct {
+	// TLSConfig, when set, makes Serve serve HTTPS instead, with HTTP/2 negotiated over TLS.
+	// See TLSConfig and SelfSignedTLSConfig for creating one
+	TLSConfig *tls.Config
+
 	context  context.Context
-	server   http.Server
 	listener *net.TCPListener
+
+	// mutex guards server and handler, which are set by Serve, against a concurrent Shutdown.
+	// closed records a Shutdown happening before Serve
+	mutex   sync.Mutex
+	server  *http.Server
+	handler http.Handler
+	closed  bool
+
+	sockets socketServer
+	grpc    grpcServer
 }
 
 func (s *Server) Listen(ctx context.Context, listenAddress string) (*net.TCPAddr, error) {
@@ -25,16 +45,44 @@ func (s *Server) Listen(ctx context.Context, listenAddress string) (*net.TCPAddr
 	return s.Address(), nil
 }
 
+func (s *Server) setContext(ctx context.Context) {
+	if s.context == nil {
+		s.context = ctx
+	}
+}
+
 func (s *Server) Serve(handler http.Handler) error {
 	if s.listener == nil {
 		return errors.New("server must be listening first")
 	}
-	s.server = http.Server{
+	h2Server := &http2.Server{}
+	server := &http.Server{
 		Addr:        s.listener.Addr().String(),
-		Handler:     handler,
+		Handler:     h2c.NewHandler(handler, h2Server),
 		BaseContext: func(net.Listener) context.Context { return s.context },
 	}
-	return s.server.Serve(s.listener)
+	if s.TLSConfig != nil {
+		server.TLSConfig = s.TLSConfig.Clone()
+	}
+	// also makes HTTP/2 connections shut down gracefully with the server
+	if err := http2.ConfigureServer(server, h2Server); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		s.listener.Close()
+		return http.ErrServerClosed
+	}
+	s.server = server
+	s.handler = handler
+	s.mutex.Unlock()
+
+	if s.TLSConfig != nil {
+		return server.ServeTLS(s.listener, "", "")
+	}
+	return server.Serve(s.listener)
 }
 
 func IsClosedError(err error) bool {
@@ -60,7 +108,9 @@ func (s *Server) AddressString() string {
 }
 
 func (s *Server) Handler() http.Handler {
-	return s.server.Handler
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.handler
 }
 
 func (s *Server) ShutdownWithTimeout(timeout time.Duration) error {
@@ -70,5 +120,23 @@ func (s *Server) ShutdownWithTimeout(timeout time.Duration) error {
 }
 
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+	s.mutex.Lock()
+	s.closed = true
+	server := s.server
+	s.mutex.Unlock()
+
+	var err error
+	if server != nil {
+		err = server.Shutdown(ctx)
+	} else if s.listener != nil {
+		// Serve was not called yet, and will not serve once it is
+		s.listener.Close()
+	}
+	if socketsErr := s.sockets.shutdown(ctx); err == nil {
+		err = socketsErr
+	}
+	if grpcErr := s.grpc.shutdown(ctx); err == nil {
+		err = grpcErr
+	}
+	return err
 }
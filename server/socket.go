@@ -0,0 +1,269 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/bcap/kaller/frame"
+)
+
+// socketServer serves kaller framed messages over raw TCP connections and UDP datagrams,
+// adapting them to an http.Handler. See the frame package for the framing format
+type socketServer struct {
+	tcpListener *net.TCPListener
+	udpConn     *net.UDPConn
+
+	mutex    sync.Mutex
+	closed   bool
+	conns    map[net.Conn]struct{}
+	inflight sync.WaitGroup
+}
+
+var errResponseSent = errors.New("response already sent")
+
+// ListenTCP starts listening for kaller framed calls over TCP. Calls are only served once
+// ServeTCP is called
+func (s *Server) ListenTCP(ctx context.Context, listenAddress string) (*net.TCPAddr, error) {
+	var lc net.ListenConfig
+	listener, err := lc.Listen(ctx, "tcp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+	s.setContext(ctx)
+	s.sockets.tcpListener = listener.(*net.TCPListener)
+	addr := *s.sockets.tcpListener.Addr().(*net.TCPAddr)
+	return &addr, nil
+}
+
+// ListenUDP starts listening for kaller framed calls over UDP. Calls are only served once
+// ServeUDP is called
+func (s *Server) ListenUDP(ctx context.Context, listenAddress string) (*net.UDPAddr, error) {
+	var lc net.ListenConfig
+	conn, err := lc.ListenPacket(ctx, "udp", listenAddress)
+	if err != nil {
+		return nil, err
+	}
+	s.setContext(ctx)
+	s.sockets.udpConn = conn.(*net.UDPConn)
+	addr := *s.sockets.udpConn.LocalAddr().(*net.UDPAddr)
+	return &addr, nil
+}
+
+// ServeTCP serves kaller framed calls over TCP connections with the given handler. Each
+// connection can carry multiple calls, which are handled one after the other
+func (s *Server) ServeTCP(handler http.Handler) error {
+	if s.sockets.tcpListener == nil {
+		return errors.New("server must be listening on tcp first")
+	}
+	for {
+		conn, err := s.sockets.tcpListener.Accept()
+		if err != nil {
+			if s.sockets.isClosed() {
+				return http.ErrServerClosed
+			}
+			return err
+		}
+		if !s.sockets.track(conn) {
+			conn.Close()
+			return http.ErrServerClosed
+		}
+		go s.serveConn(handler, conn)
+	}
+}
+
+func (s *Server) serveConn(handler http.Handler, conn net.Conn) {
+	defer s.sockets.untrack(conn)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		msg, err := frame.Read(reader)
+		if err != nil {
+			return
+		}
+		if !s.sockets.begin() {
+			return
+		}
+		writer := &frameResponseWriter{
+			header: http.Header{},
+			send:   func(msg frame.Message) error { return frame.Write(conn, msg) },
+		}
+		err = s.serveMessage(handler, "TCP", conn.RemoteAddr(), msg, writer)
+		s.sockets.inflight.Done()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// ServeUDP serves kaller framed calls over UDP with the given handler. Each datagram carries a
+// single call, which is replied with a single datagram
+func (s *Server) ServeUDP(handler http.Handler) error {
+	conn := s.sockets.udpConn
+	if conn == nil {
+		return errors.New("server must be listening on udp first")
+	}
+	buf := make([]byte, frame.MaxDatagramSize)
+	for {
+		n, addr, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if s.sockets.isClosed() {
+				return http.ErrServerClosed
+			}
+			return err
+		}
+		msg, err := frame.Decode(buf[:n])
+		if err != nil {
+			continue
+		}
+		if !s.sockets.begin() {
+			return http.ErrServerClosed
+		}
+		go func() {
+			defer s.sockets.inflight.Done()
+			writer := &frameResponseWriter{
+				header: http.Header{},
+				send: func(msg frame.Message) error {
+					encoded := frame.Encode(msg)
+					if len(encoded) > frame.MaxDatagramSize {
+						return errors.New("udp response too large")
+					}
+					_, err := conn.WriteToUDP(encoded, addr)
+					return err
+				},
+			}
+			s.serveMessage(handler, "UDP", addr, msg, writer)
+		}()
+	}
+}
+
+// serveMessage handles a framed request with the given handler, using the given method
+// to identify the transport in the request
+func (s *Server) serveMessage(handler http.Handler, method string, remote net.Addr, msg frame.Message, writer *frameResponseWriter) error {
+	req, err := http.NewRequestWithContext(s.context, method, msg.Start, bytes.NewReader(msg.Payload))
+	if err != nil {
+		return writer.send(frame.Message{Start: "400", Header: http.Header{}, Payload: []byte(err.Error())})
+	}
+	req.Header = msg.Header
+	req.RemoteAddr = remote.String()
+	req.RequestURI = msg.Start
+	handler.ServeHTTP(writer, req)
+	return writer.finish()
+}
+
+func (s *socketServer) track(conn net.Conn) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.conns == nil {
+		s.conns = map[net.Conn]struct{}{}
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *socketServer) untrack(conn net.Conn) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.conns, conn)
+}
+
+// begin registers a call being handled, returning false if the server is shutting down
+func (s *socketServer) begin() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+func (s *socketServer) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// shutdown stops accepting calls and waits for the calls being handled to finish or for the
+// context to be done, whatever happens first. Connections are then closed
+func (s *socketServer) shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	if s.tcpListener != nil {
+		s.tcpListener.Close()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	var err error
+	select {
+	case <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	if s.udpConn != nil {
+		s.udpConn.Close()
+	}
+	s.mutex.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mutex.Unlock()
+	return err
+}
+
+// frameResponseWriter is an http.ResponseWriter that sends the response as a framed message.
+// The whole response is sent on the first Write, or once the handler is done if there was no
+// Write. Writes after the response was sent fail
+type frameResponseWriter struct {
+	header     http.Header
+	statusCode int
+	sent       bool
+	send       func(frame.Message) error
+}
+
+func (w *frameResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *frameResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *frameResponseWriter) Write(body []byte) (int, error) {
+	if w.sent {
+		return 0, errResponseSent
+	}
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	w.sent = true
+	msg := frame.Message{Start: strconv.Itoa(w.statusCode), Header: w.header.Clone(), Payload: body}
+	if err := w.send(msg); err != nil {
+		return 0, err
+	}
+	return len(body), nil
+}
+
+func (w *frameResponseWriter) finish() error {
+	if w.sent {
+		return nil
+	}
+	_, err := w.Write(nil)
+	return err
+}
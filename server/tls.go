@@ -0,0 +1,60 @@
+package server
+
+import (
+	"crypto/tls"
+	"os"
+
+	"github.com/bcap/kaller/certs"
+)
+
+// TLSConfig creates the TLS configuration of a server using the certificate and key in the
+// given PEM files. If clientCAFile is set, clients are required to present a certificate
+// signed by one of the authorities in that PEM bundle (mutual TLS)
+func TLSConfig(certFile string, keyFile string, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if err := requireClientCerts(config, clientCAFile); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// SelfSignedTLSConfig creates the TLS configuration of a server using a certificate issued by
+// the self-signed authority kept in dir (see certs.LoadOrCreateAuthority). The certificate is
+// valid for localhost, the machine host name and the given hosts. clientCAFile works the same
+// as in TLSConfig
+func SelfSignedTLSConfig(dir string, clientCAFile string, hosts ...string) (*tls.Config, error) {
+	authority, err := certs.LoadOrCreateAuthority(dir)
+	if err != nil {
+		return nil, err
+	}
+	hosts = append([]string{"localhost", "127.0.0.1", "::1"}, hosts...)
+	if hostname, err := os.Hostname(); err == nil {
+		hosts = append(hosts, hostname)
+	}
+	cert, err := authority.Issue(hosts...)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if err := requireClientCerts(config, clientCAFile); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func requireClientCerts(config *tls.Config, clientCAFile string) error {
+	if clientCAFile == "" {
+		return nil
+	}
+	pool, err := certs.LoadPool(clientCAFile)
+	if err != nil {
+		return err
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return nil
+}
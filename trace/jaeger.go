@@ -0,0 +1,87 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type jaegerExport struct {
+	Data []jaegerTrace `json:"data"`
+}
+
+type jaegerTrace struct {
+	TraceID   string                   `json:"traceID"`
+	Spans     []jaegerSpan             `json:"spans"`
+	Processes map[string]jaegerProcess `json:"processes"`
+}
+
+type jaegerSpan struct {
+	SpanID        string            `json:"spanID"`
+	OperationName string            `json:"operationName"`
+	References    []jaegerReference `json:"references"`
+	StartTime     int64             `json:"startTime"`
+	Duration      int64             `json:"duration"`
+	Tags          []jaegerTag       `json:"tags"`
+	ProcessID     string            `json:"processID"`
+}
+
+type jaegerReference struct {
+	RefType string `json:"refType"`
+	SpanID  string `json:"spanID"`
+}
+
+type jaegerTag struct {
+	Key   string `json:"key"`
+	Value any    `json:"value"`
+}
+
+type jaegerProcess struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// FromJaeger reads spans from a Jaeger JSON export, as produced by the Jaeger UI or the
+// /api/traces endpoint. If the export contains multiple traces, only the first one is used
+func FromJaeger(data []byte) ([]Span, error) {
+	var export jaegerExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, fmt.Errorf("invalid jaeger trace: %w", err)
+	}
+	if len(export.Data) == 0 {
+		return nil, fmt.Errorf("invalid jaeger trace: no traces found")
+	}
+	trace := export.Data[0]
+	spans := make([]Span, len(trace.Spans))
+	for idx, s := range trace.Spans {
+		span := Span{
+			ID:       s.SpanID,
+			Service:  trace.Processes[s.ProcessID].ServiceName,
+			Name:     s.OperationName,
+			Start:    time.UnixMicro(s.StartTime),
+			Duration: time.Duration(s.Duration) * time.Microsecond,
+		}
+		for _, ref := range s.References {
+			span.ParentID = ref.SpanID
+			span.FollowsFrom = ref.RefType == "FOLLOWS_FROM"
+			if !span.FollowsFrom {
+				break
+			}
+		}
+		for _, tag := range s.Tags {
+			switch tag.Key {
+			case "http.method", "http.request.method":
+				span.Method, _ = tag.Value.(string)
+			case "http.status_code", "http.response.status_code":
+				span.StatusCode = parseStatusCode(tag.Value)
+			case "http.url", "url.full":
+				if url, ok := tag.Value.(string); ok && span.Path == "" {
+					span.Path = pathFromURL(url)
+				}
+			case "http.target", "http.path", "url.path":
+				span.Path, _ = tag.Value.(string)
+			}
+		}
+		spans[idx] = span
+	}
+	return spans, nil
+}
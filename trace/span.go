@@ -0,0 +1,254 @@
+package trace
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+// Span is the tracing-system agnostic representation of a single span. Importers for
+// specific formats (see FromJaeger and FromZipkin) produce lists of spans, which can then
+// be converted to a plan with ToPlan
+type Span struct {
+	ID       string
+	ParentID string
+	Service  string
+	Name     string
+	Start    time.Time
+	Duration time.Duration
+
+	// FollowsFrom is set when the span is explicitly marked as not being waited by its parent
+	FollowsFrom bool
+
+	// HTTP related details, extracted from the span tags when available
+	Method     string
+	Path       string
+	StatusCode int
+}
+
+func (s *Span) end() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// ToPlan converts a trace into a plan that replays its shape:
+//   - Spans of a different service than their parent become calls to http://<service>/<path>.
+//     Spans of the same service as their parent are folded into it
+//   - The self-time of a call (time not covered by its children) becomes Compute
+//   - Children that overlap in time are grouped into parallel blocks
+//   - Children that finish after their parent or that are marked as follows-from become async calls
+func ToPlan(spans []Span) (ptype.Plan, error) {
+	if len(spans) == 0 {
+		return ptype.Plan{}, fmt.Errorf("no spans in trace")
+	}
+	byID := make(map[string]*node, len(spans))
+	for idx := range spans {
+		byID[spans[idx].ID] = &node{span: &spans[idx]}
+	}
+	roots := []*node{}
+	for idx := range spans {
+		n := byID[spans[idx].ID]
+		parent, ok := byID[n.span.ParentID]
+		if n.span.ParentID == "" || !ok {
+			roots = append(roots, n)
+			continue
+		}
+		parent.children = append(parent.children, n)
+	}
+
+	calls := []*node{}
+	for _, root := range roots {
+		calls = append(calls, root.calls("")...)
+	}
+	var start, end time.Time
+	for idx, call := range calls {
+		if idx == 0 || call.span.Start.Before(start) {
+			start = call.span.Start
+		}
+		if idx == 0 || call.span.end().After(end) {
+			end = call.span.end()
+		}
+	}
+	_, execution, err := buildExecution(start, end, calls)
+	if err != nil {
+		return ptype.Plan{}, err
+	}
+	return ptype.Plan{Execution: execution}, nil
+}
+
+type node struct {
+	span     *Span
+	children []*node
+}
+
+// calls returns the nodes that should become calls under a call to the given service,
+// folding spans of that same service
+func (n *node) calls(service string) []*node {
+	if n.span.Service != service {
+		return []*node{n}
+	}
+	result := []*node{}
+	for _, child := range n.children {
+		result = append(result, child.calls(service)...)
+	}
+	return result
+}
+
+func (n *node) toCall(async bool) (*ptype.Call, error) {
+	url, err := n.url()
+	if err != nil {
+		return nil, err
+	}
+	method := n.span.Method
+	if method == "" {
+		method = "GET"
+	}
+	statusCode := n.span.StatusCode
+	if statusCode == 0 {
+		statusCode = 200
+	}
+	children := []*node{}
+	for _, child := range n.children {
+		children = append(children, child.calls(n.span.Service)...)
+	}
+	compute, execution, err := buildExecution(n.span.Start, n.span.end(), children)
+	if err != nil {
+		return nil, err
+	}
+	return &ptype.Call{
+		Async: async,
+		HTTP: ptype.HTTP{
+			Method:     method,
+			URL:        url,
+			StatusCode: statusCode,
+		},
+		Compute:   compute,
+		Execution: execution,
+	}, nil
+}
+
+var unsafePathChars = regexp.MustCompile(`[^a-zA-Z0-9_\-./]+`)
+
+func (n *node) url() (ptype.URL, error) {
+	path := n.span.Path
+	if path == "" {
+		path = "/" + strings.Trim(unsafePathChars.ReplaceAllString(n.span.Name, "-"), "-/")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	host := unsafePathChars.ReplaceAllString(strings.ToLower(n.span.Service), "-")
+	u, err := url.Parse("http://" + host + path)
+	if err != nil {
+		return ptype.URL{}, fmt.Errorf("cannot build url for span %s: %w", n.span.ID, err)
+	}
+	return ptype.URL{URL: u}, nil
+}
+
+// buildExecution lays out the given children calls in time order over the interval from
+// start to end. Gaps not covered by children become compute steps, with the leading gap
+// being returned separately so it can be used as the call Compute
+func buildExecution(start time.Time, end time.Time, children []*node) (ptype.Compute, ptype.Execution, error) {
+	sort.SliceStable(children, func(i, j int) bool {
+		return children[i].span.Start.Before(children[j].span.Start)
+	})
+
+	var leading ptype.Compute
+	var execution ptype.Execution
+	cursor := start
+	addGap := func(until time.Time) {
+		gap := until.Sub(cursor)
+		if gap <= 0 {
+			return
+		}
+		compute := ptype.Compute{Min: gap, Max: gap}
+		if len(execution) == 0 && leading.IsZero() {
+			leading = compute
+		} else {
+			execution = append(execution, &compute)
+		}
+	}
+
+	var group []*node
+	var groupEnd time.Time
+	flush := func() error {
+		if len(group) == 0 {
+			return nil
+		}
+		addGap(group[0].span.Start)
+		steps := make(ptype.Execution, len(group))
+		for idx, child := range group {
+			call, err := child.toCall(false)
+			if err != nil {
+				return err
+			}
+			steps[idx] = call
+		}
+		if len(steps) == 1 {
+			execution = append(execution, steps[0])
+		} else {
+			execution = append(execution, &ptype.Parallel{Execution: steps})
+		}
+		if groupEnd.After(cursor) {
+			cursor = groupEnd
+		}
+		group = nil
+		return nil
+	}
+
+	for _, child := range children {
+		if child.span.FollowsFrom || child.span.end().After(end) {
+			if err := flush(); err != nil {
+				return leading, nil, err
+			}
+			addGap(child.span.Start)
+			if child.span.Start.After(cursor) {
+				cursor = child.span.Start
+			}
+			call, err := child.toCall(true)
+			if err != nil {
+				return leading, nil, err
+			}
+			execution = append(execution, call)
+			continue
+		}
+		if len(group) > 0 && !child.span.Start.Before(groupEnd) {
+			if err := flush(); err != nil {
+				return leading, nil, err
+			}
+		}
+		if len(group) == 0 || child.span.end().After(groupEnd) {
+			groupEnd = child.span.end()
+		}
+		group = append(group, child)
+	}
+	if err := flush(); err != nil {
+		return leading, nil, err
+	}
+	addGap(end)
+	return leading, execution, nil
+}
+
+func parseStatusCode(value any) int {
+	switch v := value.(type) {
+	case float64:
+		return int(v)
+	case string:
+		code, _ := strconv.Atoi(v)
+		return code
+	}
+	return 0
+}
+
+func pathFromURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return ""
+	}
+	return u.RequestURI()
+}
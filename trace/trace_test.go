@@ -0,0 +1,167 @@
+package trace
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ptype "github.com/bcap/kaller/plan"
+)
+
+var jaegerExample = `
+{"data": [{
+  "traceID": "t1",
+  "processes": {
+    "p1": {"serviceName": "frontend"},
+    "p2": {"serviceName": "svc1"},
+    "p3": {"serviceName": "svc2"},
+    "p4": {"serviceName": "svc3"},
+    "p5": {"serviceName": "svc4"},
+    "p6": {"serviceName": "svc5"}
+  },
+  "spans": [
+    {"spanID": "a", "operationName": "home", "processID": "p1", "startTime": 1000000, "duration": 100000,
+     "tags": [{"key": "http.method", "value": "GET"}, {"key": "http.target", "value": "/home"}]},
+    {"spanID": "b", "operationName": "listing", "processID": "p2", "startTime": 1010000, "duration": 80000,
+     "references": [{"refType": "CHILD_OF", "spanID": "a"}],
+     "tags": [{"key": "http.target", "value": "/listing"}, {"key": "http.status_code", "value": 200}]},
+    {"spanID": "c", "operationName": "product", "processID": "p3", "startTime": 1020000, "duration": 20000,
+     "references": [{"refType": "CHILD_OF", "spanID": "b"}],
+     "tags": [{"key": "http.url", "value": "http://svc2:8080/product?id=1"}]},
+    {"spanID": "d", "operationName": "get profile", "processID": "p4", "startTime": 1030000, "duration": 20000,
+     "references": [{"refType": "CHILD_OF", "spanID": "b"}],
+     "tags": [{"key": "http.status_code", "value": "404"}]},
+    {"spanID": "e", "operationName": "cache lookup", "processID": "p2", "startTime": 1055000, "duration": 5000,
+     "references": [{"refType": "CHILD_OF", "spanID": "b"}]},
+    {"spanID": "f", "operationName": "fill", "processID": "p6", "startTime": 1056000, "duration": 2000,
+     "references": [{"refType": "CHILD_OF", "spanID": "e"}],
+     "tags": [{"key": "http.method", "value": "PUT"}]},
+    {"spanID": "g", "operationName": "viewed", "processID": "p5", "startTime": 1070000, "duration": 80000,
+     "references": [{"refType": "CHILD_OF", "spanID": "b"}],
+     "tags": [{"key": "http.method", "value": "POST"}]}
+  ]
+}]}
+`
+
+var jaegerExamplePlan = `
+execution:
+- call:
+  http: GET frontend/home 200
+  compute: 10ms
+  execution:
+  - call:
+    http: GET svc1/listing 200
+    compute: 10ms
+    execution:
+    - parallel:
+      concurrency: 0
+      execution:
+      - call:
+        http: GET svc2/product?id=1 200
+        compute: 20ms
+      - call:
+        http: GET svc3/get-profile 404
+        compute: 20ms
+    - compute: 6ms
+    - call:
+      http: PUT svc5/fill 200
+      compute: 2ms
+    - compute: 12ms
+    - call:
+      async: true
+      http: POST svc4/viewed 200
+      compute: 80ms
+    - compute: 20ms
+  - compute: 10ms
+`
+
+func TestFromJaeger(t *testing.T) {
+	spans, err := FromJaeger([]byte(jaegerExample))
+	require.NoError(t, err)
+	plan, err := ToPlan(spans)
+	require.NoError(t, err)
+	assert.Equal(t, load(t, jaegerExamplePlan), plan)
+}
+
+var zipkinExample = `
+[
+  {"traceId": "t1", "id": "a", "name": "get /", "kind": "SERVER", "timestamp": 1000000, "duration": 50000,
+   "localEndpoint": {"serviceName": "svc1"}, "tags": {"http.method": "GET", "http.path": "/"}},
+  {"traceId": "t1", "id": "b", "parentId": "a", "name": "get", "kind": "CLIENT", "timestamp": 1010000, "duration": 30000,
+   "localEndpoint": {"serviceName": "svc1"}, "tags": {"http.method": "GET", "http.path": "/product"}},
+  {"traceId": "t1", "id": "b", "parentId": "a", "name": "get /product", "kind": "SERVER", "shared": true,
+   "timestamp": 1015000, "duration": 20000,
+   "localEndpoint": {"serviceName": "svc2"}, "tags": {"http.method": "GET", "http.path": "/product", "http.status_code": "503"}}
+]
+`
+
+var zipkinExamplePlan = `
+execution:
+- call:
+  http: GET svc1/ 200
+  compute: 15ms
+  execution:
+  - call:
+    http: GET svc2/product 503
+    compute: 20ms
+  - compute: 15ms
+`
+
+func TestFromZipkin(t *testing.T) {
+	spans, err := FromZipkin([]byte(zipkinExample))
+	require.NoError(t, err)
+	plan, err := ToPlan(spans)
+	require.NoError(t, err)
+	assert.Equal(t, load(t, zipkinExamplePlan), plan)
+}
+
+var zipkinNestedExample = `
+[
+  {"traceId": "t1", "id": "a", "name": "get /", "kind": "SERVER", "timestamp": 1000000, "duration": 50000,
+   "localEndpoint": {"serviceName": "svc1"}, "tags": {"http.method": "GET", "http.path": "/"}},
+  {"traceId": "t1", "id": "b", "parentId": "a", "name": "get", "kind": "CLIENT", "timestamp": 1010000, "duration": 30000,
+   "localEndpoint": {"serviceName": "svc1"}, "tags": {"http.method": "GET", "http.path": "/q"}},
+  {"traceId": "t1", "id": "b", "parentId": "a", "name": "get /q", "kind": "SERVER", "shared": true,
+   "timestamp": 1012000, "duration": 26000,
+   "localEndpoint": {"serviceName": "svc2"}, "tags": {"http.method": "GET", "http.path": "/q"}},
+  {"traceId": "t1", "id": "c", "parentId": "b", "name": "get", "kind": "CLIENT", "timestamp": 1016000, "duration": 12000,
+   "localEndpoint": {"serviceName": "svc2"}, "tags": {"http.method": "GET", "http.path": "/rows"}},
+  {"traceId": "t1", "id": "c", "parentId": "b", "name": "get /rows", "kind": "SERVER", "shared": true,
+   "timestamp": 1018000, "duration": 8000,
+   "localEndpoint": {"serviceName": "db"}, "tags": {"http.method": "GET", "http.path": "/rows"}}
+]
+`
+
+// db is called by svc2 while serving svc1, not by svc1 itself
+var zipkinNestedExamplePlan = `
+execution:
+- call:
+  http: GET svc1/ 200
+  compute: 12ms
+  execution:
+  - call:
+    http: GET svc2/q 200
+    compute: 6ms
+    execution:
+    - call:
+      http: GET db/rows 200
+      compute: 8ms
+    - compute: 12ms
+  - compute: 12ms
+`
+
+func TestFromZipkinNested(t *testing.T) {
+	spans, err := FromZipkin([]byte(zipkinNestedExample))
+	require.NoError(t, err)
+	plan, err := ToPlan(spans)
+	require.NoError(t, err)
+	assert.Equal(t, load(t, zipkinNestedExamplePlan), plan)
+}
+
+func load(t *testing.T, yaml string) ptype.Plan {
+	plan, err := ptype.FromYAML([]byte(strings.TrimSpace(yaml)))
+	require.NoError(t, err)
+	return plan
+}
@@ -0,0 +1,68 @@
+package trace
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type zipkinSpan struct {
+	TraceID       string            `json:"traceId"`
+	ID            string            `json:"id"`
+	ParentID      string            `json:"parentId"`
+	Name          string            `json:"name"`
+	Kind          string            `json:"kind"`
+	Timestamp     int64             `json:"timestamp"`
+	Duration      int64             `json:"duration"`
+	Shared        bool              `json:"shared"`
+	LocalEndpoint zipkinEndpoint    `json:"localEndpoint"`
+	Tags          map[string]string `json:"tags"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName"`
+}
+
+// FromZipkin reads spans from a Zipkin v2 JSON trace, which is a list of spans
+//
+// Server spans that share the id of their client span (Zipkin "shared" spans) are
+// re-parented to the client span, so that both ends of a call are kept in the trace tree. Spans
+// started while serving such a call, like downstream calls, are re-parented to the server span
+func FromZipkin(data []byte) ([]Span, error) {
+	var zipkinSpans []zipkinSpan
+	if err := json.Unmarshal(data, &zipkinSpans); err != nil {
+		return nil, fmt.Errorf("invalid zipkin trace: %w", err)
+	}
+	shared := map[string]bool{}
+	for _, s := range zipkinSpans {
+		if s.Shared && s.Kind == "SERVER" {
+			shared[s.ID] = true
+		}
+	}
+	spans := make([]Span, len(zipkinSpans))
+	for idx, s := range zipkinSpans {
+		span := Span{
+			ID:       s.ID,
+			ParentID: s.ParentID,
+			Service:  s.LocalEndpoint.ServiceName,
+			Name:     s.Name,
+			Start:    time.UnixMicro(s.Timestamp),
+			Duration: time.Duration(s.Duration) * time.Microsecond,
+		}
+		if s.Shared && s.Kind == "SERVER" {
+			span.ParentID = s.ID
+			span.ID = s.ID + "/server"
+		} else if shared[s.ParentID] {
+			span.ParentID = s.ParentID + "/server"
+		}
+		span.Method = s.Tags["http.method"]
+		span.StatusCode = parseStatusCode(s.Tags["http.status_code"])
+		if path, ok := s.Tags["http.path"]; ok {
+			span.Path = path
+		} else if url, ok := s.Tags["http.url"]; ok {
+			span.Path = pathFromURL(url)
+		}
+		spans[idx] = span
+	}
+	return spans, nil
+}